@@ -0,0 +1,369 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPath is the config file read when no path is given, it is optional
+const DefaultPath = "config.yaml"
+
+// Config is the configuration of the server: defaults are overridden by the yaml file,
+// which is overridden by the environment, e.g. the .env file
+type Config struct {
+	Server     ServerConfig     `yaml:"server"`
+	Database   DatabaseConfig   `yaml:"database"`
+	Amadeus    AmadeusConfig    `yaml:"amadeus"`
+	GoogleMaps GoogleMapsConfig `yaml:"google_maps"`
+	MockApis   MockApisConfig   `yaml:"mock_apis"`
+	Auth       AuthConfig       `yaml:"auth"`
+	Emission   EmissionConfig   `yaml:"emission"`
+	Timeouts   TimeoutsConfig   `yaml:"timeouts"`
+}
+
+type ServerConfig struct {
+	Port        string `yaml:"port"`
+	TLSCertFile string `yaml:"tls_cert_file"`
+	TLSKeyFile  string `yaml:"tls_key_file"`
+}
+
+type DatabaseConfig struct {
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	// databases used in real and test mode
+	Name     string `yaml:"name"`
+	TestName string `yaml:"test_name"`
+	SSLMode  string `yaml:"ssl_mode"`
+}
+
+type AmadeusConfig struct {
+	BaseURL   string `yaml:"base_url"`
+	APIKey    string `yaml:"api_key"`
+	APISecret string `yaml:"api_secret"`
+}
+
+type GoogleMapsConfig struct {
+	BaseURL string `yaml:"base_url"`
+	APIKey  string `yaml:"api_key"`
+}
+
+// MockApiConfig is an api the server calls, which can be served by a local mock server
+type MockApiConfig struct {
+	// URL of the api endpoint
+	URL string `yaml:"url"`
+	// address of the local mock server, if empty the mock server is not started
+	ListenAddr string `yaml:"listen_addr"`
+}
+
+type MockApisConfig struct {
+	Toll         MockApiConfig `yaml:"toll"`
+	TransitCost  MockApiConfig `yaml:"transit_cost"`
+	FuelCost     MockApiConfig `yaml:"fuel_cost"`
+	ExchangeRate MockApiConfig `yaml:"exchange_rate"`
+}
+
+type AuthConfig struct {
+	FirebaseCredentialsFile string `yaml:"firebase_credentials_file"`
+	// if set, tokens are verified with the keys of the jwks file instead of Firebase
+	JWKSFile  string   `yaml:"jwks_file"`
+	Issuer    string   `yaml:"issuer"`
+	Audience  string   `yaml:"audience"`
+	AdminUIDs []string `yaml:"admin_uids"`
+}
+
+type EmissionConfig struct {
+	// if empty, the bundled factors are used
+	FactorsFile     string `yaml:"factors_file"`
+	RailFactorsFile string `yaml:"rail_factors_file"`
+}
+
+// TimeoutsConfig contains the deadlines of the travel search providers
+type TimeoutsConfig struct {
+	// deadline of the providers not listed in Providers
+	Default time.Duration `yaml:"default"`
+	// deadline of every provider, by provider name
+	Providers map[string]time.Duration `yaml:"providers"`
+}
+
+// Default returns the configuration of a local development environment
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Port:        "443",
+			TLSCertFile: "GreenJourneyServerCertificate.crt",
+			TLSKeyFile:  "GreenJourneyServerKey.key",
+		},
+		Database: DatabaseConfig{
+			Host:     "localhost",
+			Port:     "5432",
+			Name:     "green_journey_db",
+			TestName: "green_journey_db_test",
+			SSLMode:  "disable",
+		},
+		Amadeus: AmadeusConfig{
+			BaseURL: "https://test.api.amadeus.com",
+		},
+		GoogleMaps: GoogleMapsConfig{
+			BaseURL: "https://maps.googleapis.com",
+		},
+		MockApis: MockApisConfig{
+			Toll:         MockApiConfig{URL: "http://localhost:8081/tollapi", ListenAddr: ":8081"},
+			TransitCost:  MockApiConfig{URL: "http://localhost:8082/transitcostapi", ListenAddr: ":8082"},
+			FuelCost:     MockApiConfig{URL: "http://localhost:8083/fuelcostapi", ListenAddr: ":8083"},
+			ExchangeRate: MockApiConfig{URL: "http://localhost:8084/exchangerateapi", ListenAddr: ":8084"},
+		},
+		Auth: AuthConfig{
+			FirebaseCredentialsFile: "firebaseServiceAccountKey.json",
+		},
+		Timeouts: TimeoutsConfig{
+			Default: 10 * time.Second,
+			Providers: map[string]time.Duration{
+				// amadeus is slower than google maps, don't wait too long for it
+				"amadeus":           5 * time.Second,
+				"google_maps_bike":  10 * time.Second,
+				"google_maps_car":   10 * time.Second,
+				"google_maps_train": 10 * time.Second,
+				"google_maps_bus":   10 * time.Second,
+				// intermodal searches chain google maps and amadeus calls
+				"intermodal": 15 * time.Second,
+			},
+		},
+	}
+}
+
+// Load reads the configuration: the yaml file at path, or DefaultPath if it exists when path is empty,
+// and then the environment, loading the .env file if present. The configuration is not validated
+func Load(path string) (Config, error) {
+	config := Default()
+
+	if path == "" {
+		path = DefaultPath
+		_, err := os.Stat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			path = ""
+		}
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, err
+		}
+		err = yaml.Unmarshal(data, &config)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	}
+
+	// the .env file is optional, the variables can be set in the environment
+	err := godotenv.Load()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return Config{}, fmt.Errorf("invalid .env file: %w", err)
+	}
+	err = config.applyEnvironment()
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid environment: %w", err)
+	}
+
+	return config, nil
+}
+
+// applyEnvironment overrides the configuration with the variables set in the environment,
+// variables set to an empty value override as well, e.g. to disable a mock server
+func (config *Config) applyEnvironment() error {
+	variables := map[string]*string{
+		"SERVER_PORT":               &config.Server.Port,
+		"TLS_CERT_FILE":             &config.Server.TLSCertFile,
+		"TLS_KEY_FILE":              &config.Server.TLSKeyFile,
+		"DB_HOST":                   &config.Database.Host,
+		"DB_PORT":                   &config.Database.Port,
+		"DB_USERNAME":               &config.Database.User,
+		"DB_PASSWORD":               &config.Database.Password,
+		"DB_NAME":                   &config.Database.Name,
+		"DB_TEST_NAME":              &config.Database.TestName,
+		"DB_SSL_MODE":               &config.Database.SSLMode,
+		"AMADEUS_BASE_URL":          &config.Amadeus.BaseURL,
+		"AMADEUS_API_KEY":           &config.Amadeus.APIKey,
+		"AMADEUS_API_SECRET":        &config.Amadeus.APISecret,
+		"GOOGLE_MAPS_BASE_URL":      &config.GoogleMaps.BaseURL,
+		"GOOGLE_MAPS_API_KEY":       &config.GoogleMaps.APIKey,
+		"TOLL_API_URL":              &config.MockApis.Toll.URL,
+		"TOLL_MOCK_ADDR":            &config.MockApis.Toll.ListenAddr,
+		"TRANSIT_COST_API_URL":      &config.MockApis.TransitCost.URL,
+		"TRANSIT_COST_MOCK_ADDR":    &config.MockApis.TransitCost.ListenAddr,
+		"FUEL_COST_API_URL":         &config.MockApis.FuelCost.URL,
+		"FUEL_COST_MOCK_ADDR":       &config.MockApis.FuelCost.ListenAddr,
+		"EXCHANGE_RATE_API_URL":     &config.MockApis.ExchangeRate.URL,
+		"EXCHANGE_RATE_MOCK_ADDR":   &config.MockApis.ExchangeRate.ListenAddr,
+		"FIREBASE_CREDENTIALS_FILE": &config.Auth.FirebaseCredentialsFile,
+		"AUTH_JWKS_FILE":            &config.Auth.JWKSFile,
+		"AUTH_ISSUER":               &config.Auth.Issuer,
+		"AUTH_AUDIENCE":             &config.Auth.Audience,
+		"EMISSION_FACTORS_FILE":     &config.Emission.FactorsFile,
+		"RAIL_FACTORS_FILE":         &config.Emission.RailFactorsFile,
+	}
+	for variable, field := range variables {
+		value, ok := os.LookupEnv(variable)
+		if ok {
+			*field = value
+		}
+	}
+
+	// admins are comma separated firebase uids
+	adminUIDs, ok := os.LookupEnv("ADMIN_FIREBASE_UIDS")
+	if ok {
+		config.Auth.AdminUIDs = nil
+		for _, adminUID := range strings.Split(adminUIDs, ",") {
+			adminUID = strings.TrimSpace(adminUID)
+			if adminUID != "" {
+				config.Auth.AdminUIDs = append(config.Auth.AdminUIDs, adminUID)
+			}
+		}
+	}
+
+	// the default timeout is DEFAULT_TIMEOUT, provider timeouts are the upper case provider name
+	// followed by _TIMEOUT, e.g. AMADEUS_TIMEOUT=5s
+	var errs []error
+	lookupTimeout := func(variable string) (time.Duration, bool) {
+		value, ok := os.LookupEnv(variable)
+		if !ok {
+			return 0, false
+		}
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid duration %q", variable, value))
+			return 0, false
+		}
+		return timeout, true
+	}
+	if timeout, ok := lookupTimeout("DEFAULT_TIMEOUT"); ok {
+		config.Timeouts.Default = timeout
+	}
+	for name := range config.Timeouts.Providers {
+		if timeout, ok := lookupTimeout(strings.ToUpper(name) + "_TIMEOUT"); ok {
+			config.Timeouts.Providers[name] = timeout
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks the whole configuration, needed to start the server
+func (config Config) Validate() error {
+	var errs []error
+	if !isValidPort(config.Server.Port) {
+		errs = append(errs, fmt.Errorf("server.port: invalid port %q", config.Server.Port))
+	}
+	if config.Server.TLSCertFile == "" || config.Server.TLSKeyFile == "" {
+		errs = append(errs, fmt.Errorf("server: missing tls certificate or key file"))
+	}
+	errs = append(errs, config.Database.Validate())
+
+	errs = append(errs, validateURL("amadeus.base_url", config.Amadeus.BaseURL))
+	if config.Amadeus.APIKey == "" || config.Amadeus.APISecret == "" {
+		errs = append(errs, fmt.Errorf("amadeus: missing api key or secret"))
+	}
+	errs = append(errs, validateURL("google_maps.base_url", config.GoogleMaps.BaseURL))
+	if config.GoogleMaps.APIKey == "" {
+		errs = append(errs, fmt.Errorf("google_maps: missing api key"))
+	}
+
+	mockApis := map[string]MockApiConfig{
+		"toll":          config.MockApis.Toll,
+		"transit_cost":  config.MockApis.TransitCost,
+		"fuel_cost":     config.MockApis.FuelCost,
+		"exchange_rate": config.MockApis.ExchangeRate,
+	}
+	for name, mockApi := range mockApis {
+		errs = append(errs, validateURL("mock_apis."+name+".url", mockApi.URL))
+	}
+
+	if config.Auth.JWKSFile == "" && config.Auth.FirebaseCredentialsFile == "" {
+		errs = append(errs, fmt.Errorf("auth: missing firebase credentials file or jwks file"))
+	}
+
+	if config.Timeouts.Default <= 0 {
+		errs = append(errs, fmt.Errorf("timeouts.default: timeout must be positive"))
+	}
+	for name, timeout := range config.Timeouts.Providers {
+		if timeout <= 0 {
+			errs = append(errs, fmt.Errorf("timeouts.providers.%s: timeout must be positive", name))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Validate checks the database configuration, the only one needed by the migrate and import subcommands
+func (databaseConfig DatabaseConfig) Validate() error {
+	var errs []error
+	if databaseConfig.Host == "" {
+		errs = append(errs, fmt.Errorf("database.host: missing host"))
+	}
+	if !isValidPort(databaseConfig.Port) {
+		errs = append(errs, fmt.Errorf("database.port: invalid port %q", databaseConfig.Port))
+	}
+	if databaseConfig.User == "" {
+		errs = append(errs, fmt.Errorf("database.user: missing user"))
+	}
+	if databaseConfig.Name == "" || databaseConfig.TestName == "" {
+		errs = append(errs, fmt.Errorf("database: missing database name or test database name"))
+	}
+	switch databaseConfig.SSLMode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		errs = append(errs, fmt.Errorf("database.ssl_mode: invalid ssl mode %q", databaseConfig.SSLMode))
+	}
+	return errors.Join(errs...)
+}
+
+// DSN returns the connection string of the database used in the test mode, "real" or "test"
+func (databaseConfig DatabaseConfig) DSN(testMode string) (string, error) {
+	var name string
+	switch testMode {
+	case "real":
+		name = databaseConfig.Name
+	case "test":
+		name = databaseConfig.TestName
+	default:
+		return "", fmt.Errorf("invalid test mode %s", testMode)
+	}
+
+	// values are quoted, so that passwords can contain spaces and quotes
+	parameters := []string{
+		"host=" + quoteDSNValue(databaseConfig.Host),
+		"user=" + quoteDSNValue(databaseConfig.User),
+		"password=" + quoteDSNValue(databaseConfig.Password),
+		"dbname=" + quoteDSNValue(name),
+		"port=" + quoteDSNValue(databaseConfig.Port),
+		"sslmode=" + quoteDSNValue(databaseConfig.SSLMode),
+	}
+	return strings.Join(parameters, " "), nil
+}
+
+func quoteDSNValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+func isValidPort(port string) bool {
+	value, err := strconv.Atoi(port)
+	return err == nil && value > 0 && value < 65536
+}
+
+func validateURL(name, value string) error {
+	parsedURL, err := url.Parse(value)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+		return fmt.Errorf("%s: invalid url %q", name, value)
+	}
+	return nil
+}
@@ -3,7 +3,9 @@ package db
 import (
 	"fmt"
 	"gorm.io/gorm"
+	"green-journey-server/internals"
 	"green-journey-server/model"
+	"sort"
 )
 
 type CityDAO struct {
@@ -32,6 +34,27 @@ func (cityDAO *CityDAO) GetCities() ([]model.City, error) {
 	return cities, result.Error
 }
 
+// GetNumAirportsByCity returns the number of airports of every city having at least one
+func (cityDAO *CityDAO) GetNumAirportsByCity() (map[int]int, error) {
+	var rows []struct {
+		CityID      int `gorm:"column:id_city"`
+		NumAirports int `gorm:"column:num_airports"`
+	}
+	result := cityDAO.db.Model(&model.Airport{}).
+		Select("id_city, COUNT(*) AS num_airports").
+		Group("id_city").
+		Scan(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	numAirports := make(map[int]int)
+	for _, row := range rows {
+		numAirports[row.CityID] = row.NumAirports
+	}
+	return numAirports, nil
+}
+
 func (cityDAO *CityDAO) GetCityById(cityID int) (model.City, error) {
 	var city model.City
 	result := cityDAO.db.First(&city, cityID)
@@ -70,6 +93,12 @@ func (cityDAO *CityDAO) GetAirportByAirportIata(airportIata string) (model.Airpo
 	return airport, result.Error
 }
 
+func (cityDAO *CityDAO) GetAirportsByCityId(cityID int) ([]model.Airport, error) {
+	var airports []model.Airport
+	result := cityDAO.db.Where("id_city = ?", cityID).Find(&airports)
+	return airports, result.Error
+}
+
 func (cityDAO *CityDAO) GetCityByAirportIata(airportIata string) (model.City, error) {
 	var city model.City
 
@@ -102,3 +131,59 @@ func (cityDAO *CityDAO) UpdateCityById(cityID int, fields map[string]interface{}
 
 	return city, nil
 }
+
+// GetNearbyCities returns the cities within radius [km] from a point, sorted by distance:
+// cities without coordinates are not considered
+func (cityDAO *CityDAO) GetNearbyCities(latitude, longitude, radius float64) ([]model.NearbyCity, error) {
+	// prefilter with the bounding box, so that the query can use the coordinates index
+	minLat, maxLat, minLon, maxLon := internals.ComputeBoundingBox(latitude, longitude, radius)
+	var cities []model.City
+	result := cityDAO.db.
+		Where("latitude BETWEEN ? AND ? AND longitude BETWEEN ? AND ?", minLat, maxLat, minLon, maxLon).
+		Find(&cities)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	nearbyCities := []model.NearbyCity{}
+	for _, city := range cities {
+		if city.Latitude == nil || city.Longitude == nil {
+			continue
+		}
+		distance := internals.ComputeHaversineDistance(latitude, longitude, *city.Latitude, *city.Longitude)
+		if distance <= radius {
+			nearbyCities = append(nearbyCities, model.NearbyCity{City: city, Distance: distance})
+		}
+	}
+	sort.Slice(nearbyCities, func(i, j int) bool {
+		return nearbyCities[i].Distance < nearbyCities[j].Distance
+	})
+
+	return nearbyCities, nil
+}
+
+// GetNearbyAirports returns the airports within radius [km] from a point, sorted by distance
+func (cityDAO *CityDAO) GetNearbyAirports(latitude, longitude, radius float64) ([]model.NearbyAirport, error) {
+	// prefilter with the bounding box, so that the query can use the coordinates index
+	minLat, maxLat, minLon, maxLon := internals.ComputeBoundingBox(latitude, longitude, radius)
+	var airports []model.Airport
+	result := cityDAO.db.
+		Where("latitude BETWEEN ? AND ? AND longitude BETWEEN ? AND ?", minLat, maxLat, minLon, maxLon).
+		Find(&airports)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	nearbyAirports := []model.NearbyAirport{}
+	for _, airport := range airports {
+		distance := internals.ComputeHaversineDistance(latitude, longitude, airport.Latitude, airport.Longitude)
+		if distance <= radius {
+			nearbyAirports = append(nearbyAirports, model.NearbyAirport{Airport: airport, Distance: distance})
+		}
+	}
+	sort.Slice(nearbyAirports, func(i, j int) bool {
+		return nearbyAirports[i].Distance < nearbyAirports[j].Distance
+	})
+
+	return nearbyAirports, nil
+}
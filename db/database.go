@@ -2,35 +2,22 @@ package db
 
 import (
 	"fmt"
-	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+	"green-journey-server/config"
 	"log"
-	"os"
 )
 
 var db *gorm.DB
 var testMode string
 
-func InitDB(testModeArg string) (*gorm.DB, error) {
+func InitDB(databaseConfig config.DatabaseConfig, testModeArg string) (*gorm.DB, error) {
 	// save testMode
 	testMode = testModeArg
 
-	err := godotenv.Load()
+	dsn, err := databaseConfig.DSN(testMode)
 	if err != nil {
-		log.Fatal("Error loading .env file")
-	}
-
-	user := os.Getenv("DB_USERNAME")
-	password := os.Getenv("DB_PASSWORD")
-
-	var dsn string
-	if testMode == "real" {
-		dsn = "host=localhost user=" + user + " password=" + password + " dbname=green_journey_db port=5432 sslmode=disable"
-	} else if testMode == "test" {
-		dsn = "host=localhost user=" + user + " password=" + password + " dbname=green_journey_db_test port=5432 sslmode=disable"
-	} else {
 		log.Fatal("Invalid test mode")
 	}
 
@@ -70,7 +57,7 @@ func ResetTestDatabase() error {
 	}
 
 	// "user" because it is a reserved word in PostgreSQL
-	// don't delete cities in the city table, loaded from dataset
+	// don't delete cities and airports, loaded from datasets with the import command
 	err := db.Exec(`TRUNCATE TABLE review, reviews_aggregated, segment, travel, "user" CASCADE;`)
 
 	if err.Error != nil {
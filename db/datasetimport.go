@@ -0,0 +1,355 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"green-journey-server/internals"
+	"green-journey-server/model"
+	"math"
+	"strings"
+)
+
+// ImportReport lists what a dataset import changed, or would change in a dry run:
+// changes are a diff, "+" for created rows and "~" for updated ones, conflicts are rows left untouched
+type ImportReport struct {
+	CitiesCreated     int
+	CitiesUpdated     int
+	CitiesUnchanged   int
+	AirportsCreated   int
+	AirportsUpdated   int
+	AirportsUnchanged int
+	Changes           []string
+	Conflicts         []string
+}
+
+// returned by the import transaction to roll back a dry run
+var errDryRun = errors.New("dry run")
+
+// ImportDataset upserts the cities of a dataset, matched on iata and country code, and then its airports,
+// matched on airport iata. Everything is done in a transaction: with dryRun it is rolled back,
+// so the report shows the diff without changing the database
+func (cityDAO *CityDAO) ImportDataset(cities []internals.DatasetCity, airports []internals.DatasetAirport, dryRun bool) (ImportReport, error) {
+	var report ImportReport
+	err := cityDAO.db.Transaction(func(transaction *gorm.DB) error {
+		cityIndex, err := newDatasetCityIndex(transaction)
+		if err != nil {
+			return err
+		}
+
+		err = importCities(transaction, cityIndex, cities, &report)
+		if err != nil {
+			return err
+		}
+		err = importAirports(transaction, cityIndex, airports, &report)
+		if err != nil {
+			return err
+		}
+
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return ImportReport{}, err
+	}
+	return report, nil
+}
+
+// datasetCityIndex indexes the cities of the database, and the ones created by the import,
+// by iata and country code and by name and country code
+type datasetCityIndex struct {
+	byIata map[string][]model.City
+	byName map[string][]model.City
+}
+
+func newDatasetCityIndex(transaction *gorm.DB) (*datasetCityIndex, error) {
+	var cities []model.City
+	result := transaction.Find(&cities)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	cityIndex := &datasetCityIndex{byIata: map[string][]model.City{}, byName: map[string][]model.City{}}
+	for _, city := range cities {
+		cityIndex.add(city)
+	}
+	return cityIndex, nil
+}
+
+func (cityIndex *datasetCityIndex) add(city model.City) {
+	if city.CountryCode == nil {
+		return
+	}
+	if city.CityIata != nil {
+		key := cityIataKey(*city.CityIata, *city.CountryCode)
+		cityIndex.byIata[key] = append(cityIndex.byIata[key], city)
+	}
+	key := cityNameKey(city.CityName, *city.CountryCode)
+	cityIndex.byName[key] = append(cityIndex.byName[key], city)
+}
+
+// update replaces an indexed city, whose name may have changed
+func (cityIndex *datasetCityIndex) update(city model.City) {
+	for _, index := range []map[string][]model.City{cityIndex.byIata, cityIndex.byName} {
+		for key, indexedCities := range index {
+			var keptCities []model.City
+			for _, indexedCity := range indexedCities {
+				if indexedCity.CityID != city.CityID {
+					keptCities = append(keptCities, indexedCity)
+				}
+			}
+			index[key] = keptCities
+		}
+	}
+	cityIndex.add(city)
+}
+
+func importCities(transaction *gorm.DB, cityIndex *datasetCityIndex, cities []internals.DatasetCity, report *ImportReport) error {
+	// line of the first occurrence of every city of the dataset
+	importedLines := map[string]int{}
+	importedCities := map[string]model.City{}
+
+	for _, datasetCity := range cities {
+		city := datasetCity.City
+		key := cityIataKey(*city.CityIata, *city.CountryCode)
+		description := fmt.Sprintf("city %s %s", *city.CityIata, *city.CountryCode)
+
+		// a city can appear once in the dataset
+		if firstLine, ok := importedLines[key]; ok {
+			if len(cityChanges(importedCities[key], city)) > 0 {
+				report.Conflicts = append(report.Conflicts, fmt.Sprintf("line %d: %s differs from line %d, ignored", datasetCity.Line, description, firstLine))
+			}
+			continue
+		}
+		importedLines[key] = datasetCity.Line
+		importedCities[key] = city
+
+		existingCities := cityIndex.byIata[key]
+		if len(existingCities) > 1 {
+			report.Conflicts = append(report.Conflicts, fmt.Sprintf("line %d: %s matches %d cities, ignored", datasetCity.Line, description, len(existingCities)))
+			continue
+		}
+
+		// new city
+		if len(existingCities) == 0 {
+			result := transaction.Create(&city)
+			if result.Error != nil {
+				return result.Error
+			}
+			cityIndex.add(city)
+			report.CitiesCreated++
+			report.Changes = append(report.Changes, fmt.Sprintf("+ %s %s", description, city.CityName))
+			continue
+		}
+
+		// existing city, only the fields given by the dataset are updated
+		existingCity := existingCities[0]
+		changes := cityChanges(existingCity, city)
+		if len(changes) == 0 {
+			report.CitiesUnchanged++
+			continue
+		}
+		fields := map[string]interface{}{}
+		for _, change := range changes {
+			fields[change.column] = change.newValue
+		}
+		result := transaction.Model(&model.City{}).Where("id_city = ?", existingCity.CityID).Updates(fields)
+		if result.Error != nil {
+			return result.Error
+		}
+		city.CityID = existingCity.CityID
+		cityIndex.update(mergeCity(existingCity, city))
+		report.CitiesUpdated++
+		report.Changes = append(report.Changes, fmt.Sprintf("~ %s: %s", description, formatChanges(changes)))
+	}
+
+	return nil
+}
+
+func importAirports(transaction *gorm.DB, cityIndex *datasetCityIndex, airports []internals.DatasetAirport, report *ImportReport) error {
+	var existingAirports []model.Airport
+	result := transaction.Find(&existingAirports)
+	if result.Error != nil {
+		return result.Error
+	}
+	airportsByIata := map[string][]model.Airport{}
+	for _, airport := range existingAirports {
+		airportsByIata[airport.AirportIata] = append(airportsByIata[airport.AirportIata], airport)
+	}
+	citiesByID := map[int]model.City{}
+	for _, indexedCities := range cityIndex.byName {
+		for _, city := range indexedCities {
+			citiesByID[city.CityID] = city
+		}
+	}
+
+	importedLines := map[string]int{}
+	for _, datasetAirport := range airports {
+		airport := datasetAirport.Airport
+		description := fmt.Sprintf("airport %s %s", airport.AirportIata, datasetAirport.CountryCode)
+
+		// an airport can appear once in the dataset
+		if firstLine, ok := importedLines[airport.AirportIata]; ok {
+			report.Conflicts = append(report.Conflicts, fmt.Sprintf("line %d: %s already at line %d, ignored", datasetAirport.Line, description, firstLine))
+			continue
+		}
+		importedLines[airport.AirportIata] = datasetAirport.Line
+
+		// city of the airport
+		var candidateCities []model.City
+		var cityDescription string
+		if datasetAirport.CityIata != "" {
+			candidateCities = cityIndex.byIata[cityIataKey(datasetAirport.CityIata, datasetAirport.CountryCode)]
+			cityDescription = "city " + datasetAirport.CityIata
+		} else {
+			candidateCities = cityIndex.byName[cityNameKey(datasetAirport.Municipality, datasetAirport.CountryCode)]
+			cityDescription = "city " + datasetAirport.Municipality
+		}
+		if len(candidateCities) != 1 {
+			report.Conflicts = append(report.Conflicts, fmt.Sprintf("line %d: %s, %s %s matches %d cities, ignored",
+				datasetAirport.Line, description, cityDescription, datasetAirport.CountryCode, len(candidateCities)))
+			continue
+		}
+		airport.CityID = candidateCities[0].CityID
+
+		existing := airportsByIata[airport.AirportIata]
+		if len(existing) > 1 {
+			report.Conflicts = append(report.Conflicts, fmt.Sprintf("line %d: %s matches %d airports, ignored", datasetAirport.Line, description, len(existing)))
+			continue
+		}
+
+		// new airport
+		if len(existing) == 0 {
+			result = transaction.Create(&airport)
+			if result.Error != nil {
+				return result.Error
+			}
+			report.AirportsCreated++
+			report.Changes = append(report.Changes, fmt.Sprintf("+ %s %s, %s", description, airport.AirportName, cityDescription))
+			continue
+		}
+
+		// existing airport, it can't be moved to another country
+		existingAirport := existing[0]
+		existingCity, ok := citiesByID[existingAirport.CityID]
+		if ok && existingCity.CountryCode != nil && *existingCity.CountryCode != datasetAirport.CountryCode {
+			report.Conflicts = append(report.Conflicts, fmt.Sprintf("line %d: %s belongs to a city in %s, ignored", datasetAirport.Line, description, *existingCity.CountryCode))
+			continue
+		}
+		changes := airportChanges(existingAirport, airport)
+		if len(changes) == 0 {
+			report.AirportsUnchanged++
+			continue
+		}
+		fields := map[string]interface{}{}
+		for _, change := range changes {
+			fields[change.column] = change.newValue
+		}
+		result = transaction.Model(&model.Airport{}).Where("id_airport = ?", existingAirport.AirportID).Updates(fields)
+		if result.Error != nil {
+			return result.Error
+		}
+		report.AirportsUpdated++
+		report.Changes = append(report.Changes, fmt.Sprintf("~ %s: %s", description, formatChanges(changes)))
+	}
+
+	return nil
+}
+
+// fieldChange is the change of a column made by the import
+type fieldChange struct {
+	column   string
+	oldValue interface{}
+	newValue interface{}
+}
+
+// cityChanges compares a city with a dataset city: missing dataset values don't change the city
+func cityChanges(existingCity, city model.City) []fieldChange {
+	var changes []fieldChange
+	if city.CityName != existingCity.CityName {
+		changes = append(changes, fieldChange{"city_name", existingCity.CityName, city.CityName})
+	}
+	if city.CountryName != nil && (existingCity.CountryName == nil || *existingCity.CountryName != *city.CountryName) {
+		changes = append(changes, fieldChange{"country_name", derefString(existingCity.CountryName), *city.CountryName})
+	}
+	if city.Continent != nil && (existingCity.Continent == nil || *existingCity.Continent != *city.Continent) {
+		changes = append(changes, fieldChange{"continent", derefString(existingCity.Continent), *city.Continent})
+	}
+	if city.Latitude != nil && (existingCity.Latitude == nil || !sameCoordinate(*existingCity.Latitude, *city.Latitude)) {
+		changes = append(changes, fieldChange{"latitude", derefFloat(existingCity.Latitude), *city.Latitude})
+	}
+	if city.Longitude != nil && (existingCity.Longitude == nil || !sameCoordinate(*existingCity.Longitude, *city.Longitude)) {
+		changes = append(changes, fieldChange{"longitude", derefFloat(existingCity.Longitude), *city.Longitude})
+	}
+	return changes
+}
+
+// mergeCity returns the existing city with the values given by the dataset
+func mergeCity(existingCity, city model.City) model.City {
+	existingCity.CityName = city.CityName
+	if city.CountryName != nil {
+		existingCity.CountryName = city.CountryName
+	}
+	if city.Continent != nil {
+		existingCity.Continent = city.Continent
+	}
+	if city.Latitude != nil && city.Longitude != nil {
+		existingCity.Latitude = city.Latitude
+		existingCity.Longitude = city.Longitude
+	}
+	return existingCity
+}
+
+func airportChanges(existingAirport, airport model.Airport) []fieldChange {
+	var changes []fieldChange
+	if airport.AirportName != existingAirport.AirportName {
+		changes = append(changes, fieldChange{"airport_name", existingAirport.AirportName, airport.AirportName})
+	}
+	if !sameCoordinate(airport.Latitude, existingAirport.Latitude) {
+		changes = append(changes, fieldChange{"latitude", existingAirport.Latitude, airport.Latitude})
+	}
+	if !sameCoordinate(airport.Longitude, existingAirport.Longitude) {
+		changes = append(changes, fieldChange{"longitude", existingAirport.Longitude, airport.Longitude})
+	}
+	if airport.CityID != existingAirport.CityID {
+		changes = append(changes, fieldChange{"id_city", existingAirport.CityID, airport.CityID})
+	}
+	return changes
+}
+
+func formatChanges(changes []fieldChange) string {
+	var formattedChanges []string
+	for _, change := range changes {
+		formattedChanges = append(formattedChanges, fmt.Sprintf("%s %v -> %v", change.column, change.oldValue, change.newValue))
+	}
+	return strings.Join(formattedChanges, ", ")
+}
+
+// sameCoordinate ignores the rounding of the numeric columns
+func sameCoordinate(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func cityIataKey(cityIata, countryCode string) string {
+	return strings.ToUpper(cityIata) + "|" + strings.ToUpper(countryCode)
+}
+
+func cityNameKey(cityName, countryCode string) string {
+	return strings.ToLower(cityName) + "|" + strings.ToUpper(countryCode)
+}
+
+func derefString(value *string) interface{} {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
+
+func derefFloat(value *float64) interface{} {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"green-journey-server/model"
+	"time"
+)
+
+type IdempotencyKeyDAO struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyKeyDAO(db *gorm.DB) *IdempotencyKeyDAO {
+	return &IdempotencyKeyDAO{db: db}
+}
+
+// ReserveIdempotencyKey inserts the key, if the user has no valid key with the same value:
+// in that case the existing key is returned and the param is not inserted
+func (idempotencyKeyDAO *IdempotencyKeyDAO) ReserveIdempotencyKey(idempotencyKey *model.IdempotencyKey) (*model.IdempotencyKey, error) {
+	// expired keys of the user are removed, so that they can be reused
+	result := idempotencyKeyDAO.db.
+		Where("id_user = ? AND expires_at < ?", idempotencyKey.UserID, time.Now().UTC()).
+		Delete(&model.IdempotencyKey{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	// the unique index makes concurrent retries insert only one key
+	result = idempotencyKeyDAO.db.Clauses(clause.OnConflict{DoNothing: true}).Create(idempotencyKey)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return nil, nil
+	}
+
+	var existingKey model.IdempotencyKey
+	result = idempotencyKeyDAO.db.Where("id_user = ? AND key = ?", idempotencyKey.UserID, idempotencyKey.Key).First(&existingKey)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &existingKey, nil
+}
+
+// SetIdempotencyKeyResponse stores the response of the request, returned to the retries
+func (idempotencyKeyDAO *IdempotencyKeyDAO) SetIdempotencyKeyResponse(idempotencyKeyID int, statusCode int, responseBody []byte) error {
+	result := idempotencyKeyDAO.db.Model(&model.IdempotencyKey{}).
+		Where("id_idempotency_key = ?", idempotencyKeyID).
+		Updates(map[string]interface{}{
+			"status_code":   statusCode,
+			"response_body": responseBody,
+		})
+	return result.Error
+}
+
+// DeleteIdempotencyKey releases a key, e.g. if the request failed and can be retried
+func (idempotencyKeyDAO *IdempotencyKeyDAO) DeleteIdempotencyKey(idempotencyKeyID int) error {
+	result := idempotencyKeyDAO.db.Delete(&model.IdempotencyKey{}, idempotencyKeyID)
+	return result.Error
+}
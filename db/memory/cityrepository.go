@@ -0,0 +1,183 @@
+package memory
+
+import (
+	"green-journey-server/db"
+	"green-journey-server/internals"
+	"green-journey-server/model"
+	"sort"
+)
+
+type CityRepository struct {
+	store *Store
+}
+
+var _ db.CityRepository = (*CityRepository)(nil)
+
+func NewCityRepository(store *Store) *CityRepository {
+	return &CityRepository{store: store}
+}
+
+func (cityRepository *CityRepository) CreateCity(city *model.City) error {
+	cityRepository.store.mutex.Lock()
+	defer cityRepository.store.mutex.Unlock()
+
+	city.CityID = cityRepository.store.nextID("city", city.CityID)
+	cityRepository.store.cities[city.CityID] = *city
+	return nil
+}
+
+func (cityRepository *CityRepository) CreateAirport(airport *model.Airport) error {
+	cityRepository.store.mutex.Lock()
+	defer cityRepository.store.mutex.Unlock()
+
+	if _, ok := cityRepository.store.cities[airport.CityID]; !ok {
+		return errForeignKey("airport", "id_city", airport.CityID)
+	}
+	airport.AirportID = cityRepository.store.nextID("airport", airport.AirportID)
+	cityRepository.store.airports[airport.AirportID] = *airport
+	return nil
+}
+
+func (cityRepository *CityRepository) GetCities() ([]model.City, error) {
+	cityRepository.store.mutex.Lock()
+	defer cityRepository.store.mutex.Unlock()
+
+	cities := []model.City{}
+	for _, cityID := range sortedIDs(cityRepository.store.cities) {
+		cities = append(cities, cityRepository.store.cities[cityID])
+	}
+	return cities, nil
+}
+
+func (cityRepository *CityRepository) GetNumAirportsByCity() (map[int]int, error) {
+	cityRepository.store.mutex.Lock()
+	defer cityRepository.store.mutex.Unlock()
+
+	numAirports := make(map[int]int)
+	for _, airport := range cityRepository.store.airports {
+		numAirports[airport.CityID]++
+	}
+	return numAirports, nil
+}
+
+func (cityRepository *CityRepository) GetCityById(cityID int) (model.City, error) {
+	cityRepository.store.mutex.Lock()
+	defer cityRepository.store.mutex.Unlock()
+
+	city, ok := cityRepository.store.cities[cityID]
+	if !ok {
+		return model.City{}, db.ErrRecordNotFound
+	}
+	return city, nil
+}
+
+func (cityRepository *CityRepository) GetCityByIataAndCountryCode(cityIata, countryCode string) (model.City, error) {
+	return cityRepository.findCity(func(city model.City) bool {
+		return city.CityIata != nil && *city.CityIata == cityIata &&
+			city.CountryCode != nil && *city.CountryCode == countryCode
+	})
+}
+
+func (cityRepository *CityRepository) GetCityByNameAndCountry(cityName, countryName string) (model.City, error) {
+	return cityRepository.findCity(func(city model.City) bool {
+		return city.CityName == cityName && city.CountryName != nil && *city.CountryName == countryName
+	})
+}
+
+func (cityRepository *CityRepository) GetAirportByAirportIata(airportIata string) (model.Airport, error) {
+	cityRepository.store.mutex.Lock()
+	defer cityRepository.store.mutex.Unlock()
+
+	for _, airportID := range sortedIDs(cityRepository.store.airports) {
+		airport := cityRepository.store.airports[airportID]
+		if airport.AirportIata == airportIata {
+			return airport, nil
+		}
+	}
+	return model.Airport{}, db.ErrRecordNotFound
+}
+
+func (cityRepository *CityRepository) GetAirportsByCityId(cityID int) ([]model.Airport, error) {
+	cityRepository.store.mutex.Lock()
+	defer cityRepository.store.mutex.Unlock()
+
+	airports := []model.Airport{}
+	for _, airportID := range sortedIDs(cityRepository.store.airports) {
+		airport := cityRepository.store.airports[airportID]
+		if airport.CityID == cityID {
+			airports = append(airports, airport)
+		}
+	}
+	return airports, nil
+}
+
+func (cityRepository *CityRepository) GetCityByAirportIata(airportIata string) (model.City, error) {
+	cityRepository.store.mutex.Lock()
+	defer cityRepository.store.mutex.Unlock()
+
+	cityIDs := map[int]bool{}
+	for _, airport := range cityRepository.store.airports {
+		if airport.AirportIata == airportIata {
+			cityIDs[airport.CityID] = true
+		}
+	}
+	for _, cityID := range sortedIDs(cityRepository.store.cities) {
+		if cityIDs[cityID] {
+			return cityRepository.store.cities[cityID], nil
+		}
+	}
+	return model.City{}, db.ErrRecordNotFound
+}
+
+func (cityRepository *CityRepository) GetNearbyCities(latitude, longitude, radius float64) ([]model.NearbyCity, error) {
+	cityRepository.store.mutex.Lock()
+	defer cityRepository.store.mutex.Unlock()
+
+	nearbyCities := []model.NearbyCity{}
+	for _, cityID := range sortedIDs(cityRepository.store.cities) {
+		city := cityRepository.store.cities[cityID]
+		if city.Latitude == nil || city.Longitude == nil {
+			continue
+		}
+		distance := internals.ComputeHaversineDistance(latitude, longitude, *city.Latitude, *city.Longitude)
+		if distance <= radius {
+			nearbyCities = append(nearbyCities, model.NearbyCity{City: city, Distance: distance})
+		}
+	}
+	sort.SliceStable(nearbyCities, func(i, j int) bool {
+		return nearbyCities[i].Distance < nearbyCities[j].Distance
+	})
+	return nearbyCities, nil
+}
+
+func (cityRepository *CityRepository) GetNearbyAirports(latitude, longitude, radius float64) ([]model.NearbyAirport, error) {
+	cityRepository.store.mutex.Lock()
+	defer cityRepository.store.mutex.Unlock()
+
+	nearbyAirports := []model.NearbyAirport{}
+	for _, airportID := range sortedIDs(cityRepository.store.airports) {
+		airport := cityRepository.store.airports[airportID]
+		distance := internals.ComputeHaversineDistance(latitude, longitude, airport.Latitude, airport.Longitude)
+		if distance <= radius {
+			nearbyAirports = append(nearbyAirports, model.NearbyAirport{Airport: airport, Distance: distance})
+		}
+	}
+	sort.SliceStable(nearbyAirports, func(i, j int) bool {
+		return nearbyAirports[i].Distance < nearbyAirports[j].Distance
+	})
+	return nearbyAirports, nil
+}
+
+// findCity returns the city with the lowest id among the matching ones
+func (cityRepository *CityRepository) findCity(match func(city model.City) bool) (model.City, error) {
+	cityRepository.store.mutex.Lock()
+	defer cityRepository.store.mutex.Unlock()
+
+	for _, cityID := range sortedIDs(cityRepository.store.cities) {
+		city := cityRepository.store.cities[cityID]
+		if match(city) {
+			return city, nil
+		}
+	}
+	return model.City{}, db.ErrRecordNotFound
+}
@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"green-journey-server/db"
+	"green-journey-server/model"
+	"time"
+)
+
+type IdempotencyKeyRepository struct {
+	store *Store
+}
+
+var _ db.IdempotencyKeyRepository = (*IdempotencyKeyRepository)(nil)
+
+func NewIdempotencyKeyRepository(store *Store) *IdempotencyKeyRepository {
+	return &IdempotencyKeyRepository{store: store}
+}
+
+func (idempotencyKeyRepository *IdempotencyKeyRepository) ReserveIdempotencyKey(idempotencyKey *model.IdempotencyKey) (*model.IdempotencyKey, error) {
+	idempotencyKeyRepository.store.mutex.Lock()
+	defer idempotencyKeyRepository.store.mutex.Unlock()
+
+	store := idempotencyKeyRepository.store
+	if _, ok := store.users[idempotencyKey.UserID]; !ok {
+		return nil, errForeignKey("idempotency_key", "id_user", idempotencyKey.UserID)
+	}
+
+	// expired keys of the user are removed, so that they can be reused
+	now := time.Now().UTC()
+	for idempotencyKeyID, existingKey := range store.idempotencyKeys {
+		if existingKey.UserID == idempotencyKey.UserID && existingKey.ExpiresAt.Before(now) {
+			delete(store.idempotencyKeys, idempotencyKeyID)
+		}
+	}
+
+	// the key of a user is unique
+	for _, existingKey := range store.idempotencyKeys {
+		if existingKey.UserID == idempotencyKey.UserID && existingKey.Key == idempotencyKey.Key {
+			return &existingKey, nil
+		}
+	}
+
+	idempotencyKey.IdempotencyKeyID = store.nextID("idempotency_key", idempotencyKey.IdempotencyKeyID)
+	store.idempotencyKeys[idempotencyKey.IdempotencyKeyID] = *idempotencyKey
+	return nil, nil
+}
+
+func (idempotencyKeyRepository *IdempotencyKeyRepository) SetIdempotencyKeyResponse(idempotencyKeyID int, statusCode int, responseBody []byte) error {
+	idempotencyKeyRepository.store.mutex.Lock()
+	defer idempotencyKeyRepository.store.mutex.Unlock()
+
+	idempotencyKey, ok := idempotencyKeyRepository.store.idempotencyKeys[idempotencyKeyID]
+	if !ok {
+		return nil
+	}
+	idempotencyKey.StatusCode = statusCode
+	idempotencyKey.ResponseBody = append([]byte(nil), responseBody...)
+	idempotencyKeyRepository.store.idempotencyKeys[idempotencyKeyID] = idempotencyKey
+	return nil
+}
+
+func (idempotencyKeyRepository *IdempotencyKeyRepository) DeleteIdempotencyKey(idempotencyKeyID int) error {
+	idempotencyKeyRepository.store.mutex.Lock()
+	defer idempotencyKeyRepository.store.mutex.Unlock()
+
+	delete(idempotencyKeyRepository.store.idempotencyKeys, idempotencyKeyID)
+	return nil
+}
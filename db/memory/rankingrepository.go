@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"green-journey-server/db"
+	"green-journey-server/internals"
+	"green-journey-server/model"
+	"sort"
+)
+
+// same length of the database ranking
+const rankingSize = 10
+
+type RankingRepository struct {
+	store *Store
+}
+
+var _ db.RankingRepository = (*RankingRepository)(nil)
+
+func NewRankingRepository(store *Store) *RankingRepository {
+	return &RankingRepository{store: store}
+}
+
+func (rankingRepository *RankingRepository) ComputeShortDistanceRanking(userID int) ([]model.RankingElement, error) {
+	return rankingRepository.computeRanking(userID, func(user model.User) float64 {
+		return user.ScoreShortDistance
+	})
+}
+
+func (rankingRepository *RankingRepository) ComputeLongDistanceRanking(userID int) ([]model.RankingElement, error) {
+	return rankingRepository.computeRanking(userID, func(user model.User) float64 {
+		return user.ScoreLongDistance
+	})
+}
+
+// computeRanking returns the top users by score, followed by the requesting user if not among them
+func (rankingRepository *RankingRepository) computeRanking(userID int, score func(user model.User) float64) ([]model.RankingElement, error) {
+	rankingRepository.store.mutex.Lock()
+	defer rankingRepository.store.mutex.Unlock()
+
+	store := rankingRepository.store
+	var topUsers []model.User
+	for _, id := range sortedIDs(store.users) {
+		topUsers = append(topUsers, store.users[id])
+	}
+	sort.SliceStable(topUsers, func(i, j int) bool {
+		return score(topUsers[i]) > score(topUsers[j])
+	})
+	if len(topUsers) > rankingSize {
+		topUsers = topUsers[:rankingSize]
+	}
+
+	// add requesting user if not present
+	found := false
+	for _, user := range topUsers {
+		if user.UserID == userID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		user, err := store.getUser(userID)
+		if err != nil {
+			return nil, err
+		}
+		topUsers = append(topUsers, user)
+	}
+
+	topRankingElements := []model.RankingElement{}
+	for _, topUser := range topUsers {
+		travels, err := store.getTravelsByUser(topUser.UserID)
+		if err != nil {
+			return nil, err
+		}
+		topUser.Badges = internals.ComputeUserBadges(travels)
+		topRankingElements = append(topRankingElements, internals.ComputeRankingElement(topUser, travels))
+	}
+	return topRankingElements, nil
+}
@@ -0,0 +1,374 @@
+package memory
+
+import (
+	"fmt"
+	"green-journey-server/db"
+	"green-journey-server/model"
+	"sort"
+)
+
+// same paging of the database repository
+const bestReviewsNumber = 5
+const reviewsPageSize = 10
+
+type ReviewRepository struct {
+	store *Store
+}
+
+var _ db.ReviewRepository = (*ReviewRepository)(nil)
+
+func NewReviewRepository(store *Store) *ReviewRepository {
+	return &ReviewRepository{store: store}
+}
+
+func (reviewRepository *ReviewRepository) GetReviewById(reviewID int) (model.Review, error) {
+	reviewRepository.store.mutex.Lock()
+	defer reviewRepository.store.mutex.Unlock()
+
+	return reviewRepository.store.getReview(reviewID)
+}
+
+func (reviewRepository *ReviewRepository) GetReviewByUserIDAndCityID(userID int, cityID int) (*model.Review, error) {
+	reviewRepository.store.mutex.Lock()
+	defer reviewRepository.store.mutex.Unlock()
+
+	return reviewRepository.store.getReviewByUserAndCity(userID, cityID)
+}
+
+func (reviewRepository *ReviewRepository) GetReviewsByCity(cityID int) ([]model.Review, error) {
+	reviewRepository.store.mutex.Lock()
+	defer reviewRepository.store.mutex.Unlock()
+
+	reviews := []model.Review{}
+	for _, reviewID := range sortedIDs(reviewRepository.store.reviews) {
+		review := reviewRepository.store.reviews[reviewID]
+		if review.CityID != cityID {
+			continue
+		}
+		err := reviewRepository.store.injectReviewData(&review)
+		if err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, review)
+	}
+	return reviews, nil
+}
+
+func (reviewRepository *ReviewRepository) GetNextReviews(cityID int, reviewID int) (model.CityReviewElement, error) {
+	reviewRepository.store.mutex.Lock()
+	defer reviewRepository.store.mutex.Unlock()
+
+	store := reviewRepository.store
+	review, err := store.getReview(reviewID)
+	if err != nil {
+		return model.CityReviewElement{}, err
+	}
+	if review.CityID != cityID {
+		return model.CityReviewElement{}, fmt.Errorf("wrong city id and review id")
+	}
+
+	// reviews after the given one, newest first
+	reviews := []model.Review{}
+	for _, cityReview := range store.getCityReviews(cityID) {
+		if len(reviews) == reviewsPageSize+1 {
+			break
+		}
+		if comesAfter(review, cityReview) {
+			reviews = append(reviews, cityReview)
+		}
+	}
+	for i := range reviews {
+		err = store.injectReviewData(&reviews[i])
+		if err != nil {
+			return model.CityReviewElement{}, err
+		}
+	}
+
+	hasNext := len(reviews) == reviewsPageSize+1
+	if hasNext {
+		reviews = reviews[:reviewsPageSize]
+	}
+
+	cityReviewElement := store.newCityReviewElement(cityID, reviews)
+	cityReviewElement.HasPrevious = true
+	cityReviewElement.HasNext = hasNext
+	return cityReviewElement, nil
+}
+
+func (reviewRepository *ReviewRepository) GetPreviousReviews(cityID int, reviewID int) (model.CityReviewElement, error) {
+	reviewRepository.store.mutex.Lock()
+	defer reviewRepository.store.mutex.Unlock()
+
+	store := reviewRepository.store
+	review, err := store.getReview(reviewID)
+	if err != nil {
+		return model.CityReviewElement{}, err
+	}
+	if review.CityID != cityID {
+		return model.CityReviewElement{}, fmt.Errorf("wrong city id and review id")
+	}
+
+	// reviews before the given one, oldest first and then inverted like the database repository
+	reviews := []model.Review{}
+	cityReviews := store.getCityReviews(cityID)
+	for i := len(cityReviews) - 1; i >= 0 && len(reviews) < reviewsPageSize+1; i-- {
+		if comesAfter(cityReviews[i], review) {
+			reviews = append(reviews, cityReviews[i])
+		}
+	}
+	for i, j := 0, len(reviews)-1; i < j; i, j = i+1, j-1 {
+		reviews[i], reviews[j] = reviews[j], reviews[i]
+	}
+
+	hasPrevious := len(reviews) == reviewsPageSize+1
+	if hasPrevious {
+		reviews = reviews[:reviewsPageSize]
+	}
+
+	cityReviewElement := store.newCityReviewElement(cityID, reviews)
+	cityReviewElement.HasPrevious = hasPrevious
+	cityReviewElement.HasNext = true
+	return cityReviewElement, nil
+}
+
+func (reviewRepository *ReviewRepository) GetFirstReviewsByCityID(cityID int) (model.CityReviewElement, error) {
+	reviewRepository.store.mutex.Lock()
+	defer reviewRepository.store.mutex.Unlock()
+
+	return reviewRepository.store.getFirstReviews(cityID)
+}
+
+func (reviewRepository *ReviewRepository) GetLastReviewsByCityID(cityID int) (model.CityReviewElement, error) {
+	reviewRepository.store.mutex.Lock()
+	defer reviewRepository.store.mutex.Unlock()
+
+	store := reviewRepository.store
+	cityReviews := store.getCityReviews(cityID)
+	numReviews := len(cityReviews)
+
+	// compute offset, the last page is never empty
+	offset := numReviews - (numReviews % reviewsPageSize)
+	if offset == numReviews {
+		offset = numReviews - reviewsPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	reviews := cityReviews[offset:]
+	for i := range reviews {
+		err := store.injectReviewData(&reviews[i])
+		if err != nil {
+			return model.CityReviewElement{}, err
+		}
+	}
+
+	cityReviewElement := store.newCityReviewElement(cityID, reviews)
+	cityReviewElement.HasPrevious = offset > 0
+	cityReviewElement.HasNext = false
+	return cityReviewElement, nil
+}
+
+func (reviewRepository *ReviewRepository) CreateReview(review *model.Review) error {
+	reviewRepository.store.mutex.Lock()
+	defer reviewRepository.store.mutex.Unlock()
+
+	store := reviewRepository.store
+	if _, ok := store.cities[review.CityID]; !ok {
+		return errForeignKey("review", "id_city", review.CityID)
+	}
+	if _, ok := store.users[review.UserID]; !ok {
+		return errForeignKey("review", "id_user", review.UserID)
+	}
+
+	review.ReviewID = store.nextID("review", review.ReviewID)
+	store.reviews[review.ReviewID] = storedReview(*review)
+
+	// update the aggregated ratings, created with the first review of the city
+	reviewsAggregated := store.reviewsAggregated[review.CityID]
+	reviewsAggregated.CityID = review.CityID
+	reviewsAggregated.NumberRatings += 1
+	reviewsAggregated.SumLocalTransportRating += review.LocalTransportRating
+	reviewsAggregated.SumGreenSpacesRating += review.GreenSpacesRating
+	reviewsAggregated.SumWasteBinsRating += review.WasteBinsRating
+	store.reviewsAggregated[review.CityID] = reviewsAggregated
+	return nil
+}
+
+func (reviewRepository *ReviewRepository) UpdateReview(review model.Review) error {
+	reviewRepository.store.mutex.Lock()
+	defer reviewRepository.store.mutex.Unlock()
+
+	store := reviewRepository.store
+
+	// a tuple must be present
+	reviewsAggregated, ok := store.reviewsAggregated[review.CityID]
+	if !ok {
+		return db.ErrRecordNotFound
+	}
+	oldReview, ok := store.reviews[review.ReviewID]
+	if !ok {
+		return db.ErrRecordNotFound
+	}
+
+	// replace the old values with the new ones
+	reviewsAggregated.SumLocalTransportRating += review.LocalTransportRating - oldReview.LocalTransportRating
+	reviewsAggregated.SumGreenSpacesRating += review.GreenSpacesRating - oldReview.GreenSpacesRating
+	reviewsAggregated.SumWasteBinsRating += review.WasteBinsRating - oldReview.WasteBinsRating
+
+	store.reviews[review.ReviewID] = storedReview(review)
+	store.reviewsAggregated[review.CityID] = reviewsAggregated
+	return nil
+}
+
+func (reviewRepository *ReviewRepository) DeleteReview(reviewID int) error {
+	reviewRepository.store.mutex.Lock()
+	defer reviewRepository.store.mutex.Unlock()
+
+	store := reviewRepository.store
+	review, ok := store.reviews[reviewID]
+	if !ok {
+		return db.ErrRecordNotFound
+	}
+	// a tuple must be present
+	reviewsAggregated, ok := store.reviewsAggregated[review.CityID]
+	if !ok {
+		return db.ErrRecordNotFound
+	}
+
+	delete(store.reviews, reviewID)
+
+	if reviewsAggregated.NumberRatings > 1 {
+		// there are other reviews
+		reviewsAggregated.NumberRatings -= 1
+		reviewsAggregated.SumLocalTransportRating -= review.LocalTransportRating
+		reviewsAggregated.SumGreenSpacesRating -= review.GreenSpacesRating
+		reviewsAggregated.SumWasteBinsRating -= review.WasteBinsRating
+		store.reviewsAggregated[review.CityID] = reviewsAggregated
+	} else {
+		delete(store.reviewsAggregated, review.CityID)
+	}
+	return nil
+}
+
+func (reviewRepository *ReviewRepository) GetBestReviews() ([]model.CityReviewElement, error) {
+	reviewRepository.store.mutex.Lock()
+	defer reviewRepository.store.mutex.Unlock()
+
+	store := reviewRepository.store
+
+	// cities sorted by the sum of the average ratings
+	var reviewsAggregatedList []model.ReviewsAggregated
+	for _, cityID := range sortedIDs(store.reviewsAggregated) {
+		reviewsAggregatedList = append(reviewsAggregatedList, store.reviewsAggregated[cityID])
+	}
+	sort.SliceStable(reviewsAggregatedList, func(i, j int) bool {
+		return totalAverage(reviewsAggregatedList[i]) > totalAverage(reviewsAggregatedList[j])
+	})
+
+	var bestReviewsElements []model.CityReviewElement
+	for i := 0; i < len(reviewsAggregatedList) && i < bestReviewsNumber; i++ {
+		reviewElement, err := store.getFirstReviews(reviewsAggregatedList[i].CityID)
+		if err != nil {
+			return nil, err
+		}
+		bestReviewsElements = append(bestReviewsElements, reviewElement)
+	}
+	return bestReviewsElements, nil
+}
+
+// the following methods are called with the lock held
+
+func (store *Store) getReview(reviewID int) (model.Review, error) {
+	review, ok := store.reviews[reviewID]
+	if !ok {
+		return model.Review{}, db.ErrRecordNotFound
+	}
+	err := store.injectReviewData(&review)
+	if err != nil {
+		return model.Review{}, err
+	}
+	return review, nil
+}
+
+// getCityReviews returns the reviews of the city sorted by date time and id, newest first
+func (store *Store) getCityReviews(cityID int) []model.Review {
+	reviews := []model.Review{}
+	for _, review := range store.reviews {
+		if review.CityID == cityID {
+			reviews = append(reviews, review)
+		}
+	}
+	sort.Slice(reviews, func(i, j int) bool {
+		return comesAfter(reviews[i], reviews[j])
+	})
+	return reviews
+}
+
+func (store *Store) getFirstReviews(cityID int) (model.CityReviewElement, error) {
+	reviews := store.getCityReviews(cityID)
+	if len(reviews) > reviewsPageSize+1 {
+		reviews = reviews[:reviewsPageSize+1]
+	}
+	for i := range reviews {
+		err := store.injectReviewData(&reviews[i])
+		if err != nil {
+			return model.CityReviewElement{}, err
+		}
+	}
+
+	hasNext := len(reviews) == reviewsPageSize+1
+	if hasNext {
+		reviews = reviews[:reviewsPageSize]
+	}
+
+	cityReviewElement := store.newCityReviewElement(cityID, reviews)
+	cityReviewElement.HasPrevious = false
+	cityReviewElement.HasNext = hasNext
+	return cityReviewElement, nil
+}
+
+// newCityReviewElement returns the page of reviews with the averages and the number of reviews of the city
+func (store *Store) newCityReviewElement(cityID int, reviews []model.Review) model.CityReviewElement {
+	cityReviewElement := model.CityReviewElement{Reviews: reviews}
+	if len(reviews) != 0 {
+		reviewsAggregated, ok := store.reviewsAggregated[cityID]
+		if ok && reviewsAggregated.NumberRatings != 0 {
+			numberRatings := float64(reviewsAggregated.NumberRatings)
+			cityReviewElement.AverageLocalTransportRating = float64(reviewsAggregated.SumLocalTransportRating) / numberRatings
+			cityReviewElement.AverageGreenSpacesRating = float64(reviewsAggregated.SumGreenSpacesRating) / numberRatings
+			cityReviewElement.AverageWasteBinsRating = float64(reviewsAggregated.SumWasteBinsRating) / numberRatings
+		}
+	}
+	for _, review := range store.reviews {
+		if review.CityID == cityID {
+			cityReviewElement.NumReviews++
+		}
+	}
+	return cityReviewElement
+}
+
+// comesAfter reports if other comes after review in the newest first order, i.e. it's older
+func comesAfter(review, other model.Review) bool {
+	if review.DateTime.Equal(other.DateTime) {
+		return other.ReviewID < review.ReviewID
+	}
+	return other.DateTime.Before(review.DateTime)
+}
+
+func totalAverage(reviewsAggregated model.ReviewsAggregated) float64 {
+	if reviewsAggregated.NumberRatings == 0 {
+		return 0
+	}
+	sum := reviewsAggregated.SumLocalTransportRating + reviewsAggregated.SumGreenSpacesRating + reviewsAggregated.SumWasteBinsRating
+	return float64(sum) / float64(reviewsAggregated.NumberRatings)
+}
+
+// storedReview removes the fields that are not stored, injected when the review is read
+func storedReview(review model.Review) model.Review {
+	review.CityIata = ""
+	review.CountryCode = ""
+	review.FirstName = ""
+	review.LastName = ""
+	return review
+}
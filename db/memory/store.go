@@ -0,0 +1,245 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"green-journey-server/db"
+	"green-journey-server/internals"
+	"green-journey-server/model"
+	"sort"
+	"sync"
+)
+
+var errNoDestinationSegment = errors.New("no destination segment")
+
+// Store holds the tables of the in-memory repositories, shared by all of them like a database:
+// it is safe for concurrent use, every repository call holds the lock for its whole duration
+type Store struct {
+	mutex             sync.Mutex
+	users             map[int]model.User
+	travels           map[int]model.Travel
+	segments          map[int]model.Segment
+	reviews           map[int]model.Review
+	reviewsAggregated map[int]model.ReviewsAggregated
+	cities            map[int]model.City
+	airports          map[int]model.Airport
+	vehicleProfiles   map[int]model.VehicleProfile
+	idempotencyKeys   map[int]model.IdempotencyKey
+	// last id generated for every table, like a serial column
+	lastIDs map[string]int
+}
+
+func NewStore() *Store {
+	return &Store{
+		users:             map[int]model.User{},
+		travels:           map[int]model.Travel{},
+		segments:          map[int]model.Segment{},
+		reviews:           map[int]model.Review{},
+		reviewsAggregated: map[int]model.ReviewsAggregated{},
+		cities:            map[int]model.City{},
+		airports:          map[int]model.Airport{},
+		vehicleProfiles:   map[int]model.VehicleProfile{},
+		idempotencyKeys:   map[int]model.IdempotencyKey{},
+		lastIDs:           map[string]int{},
+	}
+}
+
+// NewRepositories returns repositories backed by the store, with the same behavior as the database ones
+func NewRepositories(store *Store) db.Repositories {
+	return db.Repositories{
+		Users:           NewUserRepository(store),
+		Travels:         NewTravelRepository(store),
+		Reviews:         NewReviewRepository(store),
+		Cities:          NewCityRepository(store),
+		Ranking:         NewRankingRepository(store),
+		VehicleProfiles: NewVehicleProfileRepository(store),
+		IdempotencyKeys: NewIdempotencyKeyRepository(store),
+	}
+}
+
+// nextID returns a new id of a table, if id is not 0 it is used and the sequence is moved past it
+func (store *Store) nextID(table string, id int) int {
+	if id != 0 {
+		if id > store.lastIDs[table] {
+			store.lastIDs[table] = id
+		}
+		return id
+	}
+	store.lastIDs[table]++
+	return store.lastIDs[table]
+}
+
+// sortedIDs returns the keys of a table in ascending order, the order of the primary key
+func sortedIDs[T any](table map[int]T) []int {
+	ids := make([]int, 0, len(table))
+	for id := range table {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// the following methods are called with the lock held
+
+func (store *Store) getUser(userID int) (model.User, error) {
+	user, ok := store.users[userID]
+	if !ok {
+		return model.User{}, db.ErrRecordNotFound
+	}
+	return user, nil
+}
+
+// saveUser inserts or updates the user, like the Save of gorm
+func (store *Store) saveUser(user model.User) error {
+	user.UserID = store.nextID("user", user.UserID)
+	user.Badges = nil
+	store.users[user.UserID] = user
+	return nil
+}
+
+func (store *Store) injectBadges(user *model.User) error {
+	travels, err := store.getTravelsByUser(user.UserID)
+	if err != nil {
+		return err
+	}
+	user.Badges = internals.ComputeUserBadges(travels)
+	return nil
+}
+
+func (store *Store) getTravelsByUser(userID int) ([]model.TravelDetails, error) {
+	var travelDetailsList []model.TravelDetails
+	for _, travelID := range sortedIDs(store.travels) {
+		travel := store.travels[travelID]
+		if travel.UserID != userID {
+			continue
+		}
+		travelDetails, err := store.getTravelDetails(travel)
+		if err != nil {
+			return []model.TravelDetails{}, err
+		}
+		travelDetailsList = append(travelDetailsList, travelDetails)
+	}
+	return travelDetailsList, nil
+}
+
+// getTravelDetails returns the travel with its segments, the cities of the segments and the reviews of the user
+func (store *Store) getTravelDetails(travel model.Travel) (model.TravelDetails, error) {
+	var segments []model.Segment
+	for _, segmentID := range sortedIDs(store.segments) {
+		segment := store.segments[segmentID]
+		if segment.TravelID == travel.TravelID {
+			segments = append(segments, segment)
+		}
+	}
+
+	err := store.injectCityInSegments(segments)
+	if err != nil {
+		return model.TravelDetails{}, err
+	}
+
+	travelDetails := model.TravelDetails{Travel: travel, Segments: segments}
+	travelDetails.SetLegacyLegIndexes()
+
+	err = store.injectReviewInTravel(&travelDetails)
+	if err != nil {
+		return model.TravelDetails{}, err
+	}
+	return travelDetails, nil
+}
+
+// deleteTravel deletes the travel and its segments
+func (store *Store) deleteTravel(travelID int) {
+	delete(store.travels, travelID)
+	for segmentID, segment := range store.segments {
+		if segment.TravelID == travelID {
+			delete(store.segments, segmentID)
+		}
+	}
+}
+
+func (store *Store) injectCityInSegments(segments []model.Segment) error {
+	for i := range segments {
+		originCity, ok := store.cities[segments[i].DepartureId]
+		if !ok {
+			return db.ErrRecordNotFound
+		}
+		destinationCity, ok := store.cities[segments[i].DestinationId]
+		if !ok {
+			return db.ErrRecordNotFound
+		}
+		segments[i].DepartureCity = originCity.CityName
+		segments[i].DepartureCountry = derefString(originCity.CountryName)
+		segments[i].DestinationCity = destinationCity.CityName
+		segments[i].DestinationCountry = derefString(destinationCity.CountryName)
+	}
+	return nil
+}
+
+func (store *Store) injectReviewInTravel(travelDetails *model.TravelDetails) error {
+	visitedCityIDs := travelDetails.GetVisitedCityIDs()
+	if len(visitedCityIDs) == 0 {
+		return errNoDestinationSegment
+	}
+
+	travelDetails.Travel.UserReview = nil
+	travelDetails.Travel.UserReviews = []model.Review{}
+	for i, cityID := range visitedCityIDs {
+		review, err := store.getReviewByUserAndCity(travelDetails.Travel.UserID, cityID)
+		if err != nil {
+			return err
+		}
+		if review == nil {
+			continue
+		}
+
+		// the first destination is the main one
+		if i == 0 {
+			travelDetails.Travel.UserReview = review
+		}
+		travelDetails.Travel.UserReviews = append(travelDetails.Travel.UserReviews, *review)
+	}
+	return nil
+}
+
+func (store *Store) getReviewByUserAndCity(userID, cityID int) (*model.Review, error) {
+	for _, reviewID := range sortedIDs(store.reviews) {
+		review := store.reviews[reviewID]
+		if review.UserID == userID && review.CityID == cityID {
+			err := store.injectReviewData(&review)
+			if err != nil {
+				return nil, err
+			}
+			return &review, nil
+		}
+	}
+	return nil, nil
+}
+
+func (store *Store) injectReviewData(review *model.Review) error {
+	city, ok := store.cities[review.CityID]
+	if !ok {
+		return db.ErrRecordNotFound
+	}
+	user, ok := store.users[review.UserID]
+	if !ok {
+		return db.ErrRecordNotFound
+	}
+
+	review.CityIata = derefString(city.CityIata)
+	review.CountryCode = derefString(city.CountryCode)
+	review.FirstName = user.FirstName
+	review.LastName = user.LastName
+	return nil
+}
+
+func derefString(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+// errForeignKey is returned when a record references a missing one, as the database constraints do
+func errForeignKey(table, column string, id int) error {
+	return fmt.Errorf("%s.%s references missing record %d", table, column, id)
+}
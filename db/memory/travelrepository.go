@@ -0,0 +1,156 @@
+package memory
+
+import (
+	"green-journey-server/db"
+	"green-journey-server/model"
+)
+
+type TravelRepository struct {
+	store *Store
+}
+
+var _ db.TravelRepository = (*TravelRepository)(nil)
+
+func NewTravelRepository(store *Store) *TravelRepository {
+	return &TravelRepository{store: store}
+}
+
+func (travelRepository *TravelRepository) CreateTravel(travelDetails model.TravelDetails) (model.TravelDetails, error) {
+	travelRepository.store.mutex.Lock()
+	defer travelRepository.store.mutex.Unlock()
+
+	store := travelRepository.store
+
+	// check the references first, so that nothing is stored if one is missing
+	if _, ok := store.users[travelDetails.Travel.UserID]; !ok {
+		return model.TravelDetails{}, errForeignKey("travel", "id_user", travelDetails.Travel.UserID)
+	}
+	for _, segment := range travelDetails.Segments {
+		if _, ok := store.cities[segment.DepartureId]; !ok {
+			return model.TravelDetails{}, errForeignKey("segment", "id_departure", segment.DepartureId)
+		}
+		if _, ok := store.cities[segment.DestinationId]; !ok {
+			return model.TravelDetails{}, errForeignKey("segment", "id_destination", segment.DestinationId)
+		}
+	}
+
+	// create travel and segments
+	travelDetails.Travel.TravelID = store.nextID("travel", travelDetails.Travel.TravelID)
+	store.travels[travelDetails.Travel.TravelID] = storedTravel(travelDetails.Travel)
+	for i := range travelDetails.Segments {
+		travelDetails.Segments[i].TravelID = travelDetails.Travel.TravelID
+		travelDetails.Segments[i].SegmentID = store.nextID("segment", travelDetails.Segments[i].SegmentID)
+		store.segments[travelDetails.Segments[i].SegmentID] = storedSegment(travelDetails.Segments[i])
+	}
+
+	// inject review
+	err := store.injectReviewInTravel(&travelDetails)
+	if err != nil {
+		return model.TravelDetails{}, err
+	}
+
+	return travelDetails, nil
+}
+
+func (travelRepository *TravelRepository) GetTravelRequestsByUserId(userID int) ([]model.TravelDetails, error) {
+	travelRepository.store.mutex.Lock()
+	defer travelRepository.store.mutex.Unlock()
+
+	return travelRepository.store.getTravelsByUser(userID)
+}
+
+func (travelRepository *TravelRepository) GetTravelById(travelID int) (model.Travel, error) {
+	travelRepository.store.mutex.Lock()
+	defer travelRepository.store.mutex.Unlock()
+
+	travel, ok := travelRepository.store.travels[travelID]
+	if !ok {
+		return model.Travel{}, db.ErrRecordNotFound
+	}
+	return travel, nil
+}
+
+func (travelRepository *TravelRepository) GetTravelDetailsByTravelID(travelID int) (model.TravelDetails, error) {
+	travelRepository.store.mutex.Lock()
+	defer travelRepository.store.mutex.Unlock()
+
+	travel, ok := travelRepository.store.travels[travelID]
+	if !ok {
+		return model.TravelDetails{}, db.ErrRecordNotFound
+	}
+	return travelRepository.store.getTravelDetails(travel)
+}
+
+// UpdateTravel saves the travel and adds deltaScore to the user score, as in the database repository
+// a negative delta is not applied and leaves the travel unchanged
+func (travelRepository *TravelRepository) UpdateTravel(travel model.Travel, deltaScore float64, isShortDistance bool) error {
+	travelRepository.store.mutex.Lock()
+	defer travelRepository.store.mutex.Unlock()
+
+	store := travelRepository.store
+	user, err := store.getUser(travel.UserID)
+	if err != nil {
+		return err
+	}
+	if deltaScore < 0.0 {
+		return nil
+	}
+
+	travel.TravelID = store.nextID("travel", travel.TravelID)
+	store.travels[travel.TravelID] = storedTravel(travel)
+
+	if isShortDistance {
+		user.ScoreShortDistance += deltaScore
+	} else {
+		user.ScoreLongDistance += deltaScore
+	}
+	return store.saveUser(user)
+}
+
+func (travelRepository *TravelRepository) DeleteTravel(travelID int, deltaScore float64, isShortDistance bool) error {
+	travelRepository.store.mutex.Lock()
+	defer travelRepository.store.mutex.Unlock()
+
+	store := travelRepository.store
+	travel, ok := store.travels[travelID]
+	if !ok {
+		return db.ErrRecordNotFound
+	}
+	user, err := store.getUser(travel.UserID)
+	if err != nil {
+		return err
+	}
+
+	store.deleteTravel(travelID)
+
+	// update user score, never below zero
+	if isShortDistance {
+		user.ScoreShortDistance -= deltaScore
+		if user.ScoreShortDistance < 0 {
+			user.ScoreShortDistance = 0
+		}
+	} else {
+		user.ScoreLongDistance -= deltaScore
+		if user.ScoreLongDistance < 0 {
+			user.ScoreLongDistance = 0
+		}
+	}
+	return store.saveUser(user)
+}
+
+// storedTravel removes the fields that are not stored, injected when the travel is read
+func storedTravel(travel model.Travel) model.Travel {
+	travel.UserReview = nil
+	travel.UserReviews = nil
+	return travel
+}
+
+// storedSegment removes the fields that are not stored, injected when the segment is read
+func storedSegment(segment model.Segment) model.Segment {
+	segment.DepartureCity = ""
+	segment.DepartureCountry = ""
+	segment.DestinationCity = ""
+	segment.DestinationCountry = ""
+	segment.EmissionBreakdown = nil
+	return segment
+}
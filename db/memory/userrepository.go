@@ -0,0 +1,146 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"green-journey-server/db"
+	"green-journey-server/model"
+)
+
+type UserRepository struct {
+	store *Store
+}
+
+var _ db.UserRepository = (*UserRepository)(nil)
+
+func NewUserRepository(store *Store) *UserRepository {
+	return &UserRepository{store: store}
+}
+
+func (userRepository *UserRepository) GetUserById(id int) (model.User, error) {
+	userRepository.store.mutex.Lock()
+	defer userRepository.store.mutex.Unlock()
+
+	user, err := userRepository.store.getUser(id)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	// inject badges, not stored
+	err = userRepository.store.injectBadges(&user)
+	if err != nil {
+		return model.User{}, err
+	}
+	return user, nil
+}
+
+func (userRepository *UserRepository) GetUserByIdNoBadges(id int) (model.User, error) {
+	userRepository.store.mutex.Lock()
+	defer userRepository.store.mutex.Unlock()
+
+	return userRepository.store.getUser(id)
+}
+
+func (userRepository *UserRepository) GetUserByFirebaseUID(firebaseUID string) (model.User, error) {
+	userRepository.store.mutex.Lock()
+	defer userRepository.store.mutex.Unlock()
+
+	user, err := userRepository.store.getUserByFirebaseUID(firebaseUID)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	// inject badges, not stored
+	err = userRepository.store.injectBadges(&user)
+	if err != nil {
+		return model.User{}, err
+	}
+	return user, nil
+}
+
+func (userRepository *UserRepository) GetUserByFirebaseUIDNoBadges(firebaseUID string) (model.User, error) {
+	userRepository.store.mutex.Lock()
+	defer userRepository.store.mutex.Unlock()
+
+	return userRepository.store.getUserByFirebaseUID(firebaseUID)
+}
+
+func (userRepository *UserRepository) InjectBadges(user *model.User) error {
+	userRepository.store.mutex.Lock()
+	defer userRepository.store.mutex.Unlock()
+
+	return userRepository.store.injectBadges(user)
+}
+
+func (userRepository *UserRepository) AddUser(user model.User) (model.User, error) {
+	userRepository.store.mutex.Lock()
+	defer userRepository.store.mutex.Unlock()
+
+	if _, ok := userRepository.store.users[user.UserID]; ok {
+		return model.User{}, fmt.Errorf("user %d already exists", user.UserID)
+	}
+	// firebase uid is unique
+	for _, otherUser := range userRepository.store.users {
+		if otherUser.FirebaseUID == user.FirebaseUID {
+			return model.User{}, errors.New("firebase uid already used")
+		}
+	}
+
+	user.UserID = userRepository.store.nextID("user", user.UserID)
+	user.Badges = nil
+	userRepository.store.users[user.UserID] = user
+	return user, nil
+}
+
+func (userRepository *UserRepository) UpdateUser(user model.User) error {
+	userRepository.store.mutex.Lock()
+	defer userRepository.store.mutex.Unlock()
+
+	return userRepository.store.saveUser(user)
+}
+
+// DeleteUser deletes the user with their travels, reviews, vehicle profiles and idempotency keys,
+// as the database cascades do: like there, the aggregated ratings of the reviews are not updated
+func (userRepository *UserRepository) DeleteUser(id int) error {
+	userRepository.store.mutex.Lock()
+	defer userRepository.store.mutex.Unlock()
+
+	store := userRepository.store
+	if _, ok := store.users[id]; !ok {
+		return errors.New("user not found")
+	}
+	delete(store.users, id)
+
+	for travelID, travel := range store.travels {
+		if travel.UserID == id {
+			store.deleteTravel(travelID)
+		}
+	}
+	for reviewID, review := range store.reviews {
+		if review.UserID == id {
+			delete(store.reviews, reviewID)
+		}
+	}
+	for vehicleProfileID, vehicleProfile := range store.vehicleProfiles {
+		if vehicleProfile.UserID == id {
+			delete(store.vehicleProfiles, vehicleProfileID)
+		}
+	}
+	for idempotencyKeyID, idempotencyKey := range store.idempotencyKeys {
+		if idempotencyKey.UserID == id {
+			delete(store.idempotencyKeys, idempotencyKeyID)
+		}
+	}
+	return nil
+}
+
+// getUserByFirebaseUID returns the user without badges, called with the lock held
+func (store *Store) getUserByFirebaseUID(firebaseUID string) (model.User, error) {
+	for _, userID := range sortedIDs(store.users) {
+		user := store.users[userID]
+		if user.FirebaseUID == firebaseUID {
+			return user, nil
+		}
+	}
+	return model.User{}, db.ErrRecordNotFound
+}
@@ -0,0 +1,77 @@
+package memory
+
+import (
+	"errors"
+	"green-journey-server/db"
+	"green-journey-server/model"
+)
+
+type VehicleProfileRepository struct {
+	store *Store
+}
+
+var _ db.VehicleProfileRepository = (*VehicleProfileRepository)(nil)
+
+func NewVehicleProfileRepository(store *Store) *VehicleProfileRepository {
+	return &VehicleProfileRepository{store: store}
+}
+
+func (vehicleProfileRepository *VehicleProfileRepository) CreateVehicleProfile(vehicleProfile *model.VehicleProfile) error {
+	vehicleProfileRepository.store.mutex.Lock()
+	defer vehicleProfileRepository.store.mutex.Unlock()
+
+	return vehicleProfileRepository.store.saveVehicleProfile(vehicleProfile)
+}
+
+func (vehicleProfileRepository *VehicleProfileRepository) GetVehicleProfileById(vehicleProfileID int) (model.VehicleProfile, error) {
+	vehicleProfileRepository.store.mutex.Lock()
+	defer vehicleProfileRepository.store.mutex.Unlock()
+
+	vehicleProfile, ok := vehicleProfileRepository.store.vehicleProfiles[vehicleProfileID]
+	if !ok {
+		return model.VehicleProfile{}, db.ErrRecordNotFound
+	}
+	return vehicleProfile, nil
+}
+
+func (vehicleProfileRepository *VehicleProfileRepository) GetVehicleProfilesByUserId(userID int) ([]model.VehicleProfile, error) {
+	vehicleProfileRepository.store.mutex.Lock()
+	defer vehicleProfileRepository.store.mutex.Unlock()
+
+	vehicleProfiles := []model.VehicleProfile{}
+	for _, vehicleProfileID := range sortedIDs(vehicleProfileRepository.store.vehicleProfiles) {
+		vehicleProfile := vehicleProfileRepository.store.vehicleProfiles[vehicleProfileID]
+		if vehicleProfile.UserID == userID {
+			vehicleProfiles = append(vehicleProfiles, vehicleProfile)
+		}
+	}
+	return vehicleProfiles, nil
+}
+
+func (vehicleProfileRepository *VehicleProfileRepository) UpdateVehicleProfile(vehicleProfile model.VehicleProfile) error {
+	vehicleProfileRepository.store.mutex.Lock()
+	defer vehicleProfileRepository.store.mutex.Unlock()
+
+	return vehicleProfileRepository.store.saveVehicleProfile(&vehicleProfile)
+}
+
+func (vehicleProfileRepository *VehicleProfileRepository) DeleteVehicleProfile(vehicleProfileID int) error {
+	vehicleProfileRepository.store.mutex.Lock()
+	defer vehicleProfileRepository.store.mutex.Unlock()
+
+	if _, ok := vehicleProfileRepository.store.vehicleProfiles[vehicleProfileID]; !ok {
+		return errors.New("vehicle profile not found")
+	}
+	delete(vehicleProfileRepository.store.vehicleProfiles, vehicleProfileID)
+	return nil
+}
+
+// saveVehicleProfile inserts or updates the vehicle profile, called with the lock held
+func (store *Store) saveVehicleProfile(vehicleProfile *model.VehicleProfile) error {
+	if _, ok := store.users[vehicleProfile.UserID]; !ok {
+		return errForeignKey("vehicle_profile", "id_user", vehicleProfile.UserID)
+	}
+	vehicleProfile.VehicleProfileID = store.nextID("vehicle_profile", vehicleProfile.VehicleProfileID)
+	store.vehicleProfiles[vehicleProfile.VehicleProfileID] = *vehicleProfile
+	return nil
+}
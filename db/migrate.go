@@ -0,0 +1,263 @@
+package db
+
+import (
+	"embed"
+	"fmt"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrations are named <version>_<name>.up.sql and <version>_<name>.down.sql,
+// they are applied in version order
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// key of the advisory lock held while migrating, so that two servers don't migrate together
+const migrationLockKey = 715517
+
+// Migration is a versioned change of the schema, with the sql to apply and to revert it
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// SchemaMigration is a row of the schema_migrations table, one for every applied migration
+type SchemaMigration struct {
+	Version   int       `gorm:"column:version;primaryKey"`
+	Name      string    `gorm:"column:name;type:text;not null"`
+	AppliedAt time.Time `gorm:"column:applied_at;type:timestamptz;not null"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// LoadMigrations returns the embedded migrations sorted by version
+func LoadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := map[int]*Migration{}
+	for _, entry := range entries {
+		fileName := entry.Name()
+		var direction string
+		if strings.HasSuffix(fileName, ".up.sql") {
+			direction = "up"
+		} else if strings.HasSuffix(fileName, ".down.sql") {
+			direction = "down"
+		} else {
+			return nil, fmt.Errorf("invalid migration file name %s", fileName)
+		}
+
+		// version and name
+		baseName := strings.TrimSuffix(fileName, "."+direction+".sql")
+		versionStr, name, found := strings.Cut(baseName, "_")
+		if !found {
+			return nil, fmt.Errorf("invalid migration file name %s", fileName)
+		}
+		version, err := strconv.Atoi(versionStr)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %s", fileName)
+		}
+
+		content, err := migrationFiles.ReadFile("migrations/" + fileName)
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := migrations[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			migrations[version] = migration
+		} else if migration.Name != name {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", migration.Name, name)
+		}
+		if direction == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	var sortedMigrations []Migration
+	for _, migration := range migrations {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both up and down steps", migration.Version, migration.Name)
+		}
+		sortedMigrations = append(sortedMigrations, *migration)
+	}
+	sort.Slice(sortedMigrations, func(i, j int) bool {
+		return sortedMigrations[i].Version < sortedMigrations[j].Version
+	})
+	return sortedMigrations, nil
+}
+
+// GetAppliedMigrations returns the migrations recorded in schema_migrations, sorted by version
+func GetAppliedMigrations(db *gorm.DB) ([]SchemaMigration, error) {
+	err := createSchemaMigrationsTable(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var appliedMigrations []SchemaMigration
+	result := db.Order("version").Find(&appliedMigrations)
+	return appliedMigrations, result.Error
+}
+
+// MigrateUp applies the pending migrations in version order, each one in its own transaction,
+// and returns the applied ones
+func MigrateUp(db *gorm.DB) ([]Migration, error) {
+	db, err := migrationDB(db)
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	err = createSchemaMigrationsTable(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var appliedMigrations []Migration
+	for _, migration := range migrations {
+		applied := false
+		err = db.Transaction(func(transaction *gorm.DB) error {
+			// the lock is released with the transaction, the version is checked again while holding it
+			result := transaction.Exec("SELECT pg_advisory_xact_lock(?)", migrationLockKey)
+			if result.Error != nil {
+				return result.Error
+			}
+			var count int64
+			result = transaction.Model(&SchemaMigration{}).Where("version = ?", migration.Version).Count(&count)
+			if result.Error != nil {
+				return result.Error
+			}
+			if count > 0 {
+				return nil
+			}
+
+			result = transaction.Exec(migration.Up)
+			if result.Error != nil {
+				return result.Error
+			}
+			result = transaction.Create(&SchemaMigration{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				AppliedAt: time.Now().UTC(),
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+			applied = true
+			return nil
+		})
+		if err != nil {
+			return appliedMigrations, fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		if applied {
+			appliedMigrations = append(appliedMigrations, migration)
+		}
+	}
+
+	return appliedMigrations, nil
+}
+
+// MigrateDown reverts the last steps applied migrations, in reverse version order,
+// and returns the reverted ones
+func MigrateDown(db *gorm.DB, steps int) ([]Migration, error) {
+	db, err := migrationDB(db)
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	err = createSchemaMigrationsTable(db)
+	if err != nil {
+		return nil, err
+	}
+	migrationsByVersion := map[int]Migration{}
+	for _, migration := range migrations {
+		migrationsByVersion[migration.Version] = migration
+	}
+
+	var revertedMigrations []Migration
+	for i := 0; i < steps; i++ {
+		var reverted *Migration
+		err = db.Transaction(func(transaction *gorm.DB) error {
+			result := transaction.Exec("SELECT pg_advisory_xact_lock(?)", migrationLockKey)
+			if result.Error != nil {
+				return result.Error
+			}
+
+			// last applied migration
+			var lastMigration SchemaMigration
+			result = transaction.Order("version DESC").Limit(1).Find(&lastMigration)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return nil
+			}
+			migration, ok := migrationsByVersion[lastMigration.Version]
+			if !ok {
+				return fmt.Errorf("applied migration %d_%s is unknown", lastMigration.Version, lastMigration.Name)
+			}
+
+			result = transaction.Exec(migration.Down)
+			if result.Error != nil {
+				return result.Error
+			}
+			result = transaction.Delete(&SchemaMigration{}, migration.Version)
+			if result.Error != nil {
+				return result.Error
+			}
+			reverted = &migration
+			return nil
+		})
+		if err != nil {
+			return revertedMigrations, err
+		}
+		if reverted == nil {
+			// nothing left to revert
+			break
+		}
+		revertedMigrations = append(revertedMigrations, *reverted)
+	}
+
+	return revertedMigrations, nil
+}
+
+// migrationDB returns a connection to the same database that doesn't prepare statements,
+// migrations contain several statements and can't be prepared
+func migrationDB(db *gorm.DB) (*gorm.DB, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	return gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: db.Logger,
+	})
+}
+
+func createSchemaMigrationsTable(db *gorm.DB) error {
+	result := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    integer PRIMARY KEY,
+		name       text NOT NULL,
+		applied_at timestamptz NOT NULL
+	)`)
+	return result.Error
+}
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS reviews_aggregated;
+DROP TABLE IF EXISTS review;
+DROP TABLE IF EXISTS segment;
+DROP TABLE IF EXISTS travel;
+DROP TABLE IF EXISTS airport;
+DROP TABLE IF EXISTS city;
+DROP TABLE IF EXISTS "user";
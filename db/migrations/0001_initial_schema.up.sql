@@ -0,0 +1,76 @@
+-- tables of the first version of the server, they may already exist in databases created by hand
+
+CREATE TABLE IF NOT EXISTS "user" (
+    id_user              serial PRIMARY KEY,
+    first_name           text    NOT NULL,
+    last_name            text    NOT NULL,
+    birth_date           date,
+    gender               text,
+    firebase_uid         text    NOT NULL,
+    zip_code             integer,
+    street_name          text,
+    house_number         integer,
+    city                 text,
+    score_short_distance numeric NOT NULL DEFAULT 0,
+    score_long_distance  numeric NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS city (
+    id_city      serial PRIMARY KEY,
+    city_iata    text,
+    city_name    text NOT NULL,
+    country_name text,
+    country_code text,
+    continent    text
+);
+
+CREATE TABLE IF NOT EXISTS airport (
+    id_airport   serial PRIMARY KEY,
+    airport_name text    NOT NULL,
+    airport_iata text    NOT NULL,
+    latitude     numeric NOT NULL,
+    longitude    numeric NOT NULL,
+    id_city      integer NOT NULL REFERENCES city (id_city) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS travel (
+    id_travel       serial PRIMARY KEY,
+    co2_compensated numeric NOT NULL DEFAULT 0,
+    confirmed       boolean NOT NULL DEFAULT false,
+    id_user         integer NOT NULL REFERENCES "user" (id_user) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS segment (
+    id_segment     serial PRIMARY KEY,
+    id_departure   integer     NOT NULL REFERENCES city (id_city),
+    id_destination integer     NOT NULL REFERENCES city (id_city),
+    date_time      timestamptz NOT NULL,
+    duration       interval    NOT NULL,
+    vehicle        text        NOT NULL,
+    description    text,
+    price          numeric     NOT NULL,
+    co2_emitted    numeric     NOT NULL,
+    distance       numeric     NOT NULL,
+    num_segment    integer     NOT NULL,
+    is_outward     boolean     NOT NULL,
+    id_travel      integer     NOT NULL REFERENCES travel (id_travel) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS review (
+    id_review              serial PRIMARY KEY,
+    id_city                integer     NOT NULL REFERENCES city (id_city) ON UPDATE CASCADE ON DELETE CASCADE,
+    id_user                integer     NOT NULL REFERENCES "user" (id_user) ON UPDATE CASCADE ON DELETE CASCADE,
+    review_text            text        NOT NULL,
+    local_transport_rating integer     NOT NULL,
+    green_spaces_rating    integer     NOT NULL,
+    waste_bins_rating      integer     NOT NULL,
+    date_time              timestamptz NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS reviews_aggregated (
+    id_city                    integer PRIMARY KEY REFERENCES city (id_city),
+    sum_local_transport_rating integer NOT NULL DEFAULT 0,
+    sum_green_spaces_rating    integer NOT NULL DEFAULT 0,
+    sum_waste_bins_rating      integer NOT NULL DEFAULT 0,
+    number_ratings             integer NOT NULL DEFAULT 0
+);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_review_id_user;
+DROP INDEX IF EXISTS idx_review_id_city;
+DROP INDEX IF EXISTS idx_segment_id_travel;
+DROP INDEX IF EXISTS idx_travel_id_user;
+DROP INDEX IF EXISTS idx_airport_id_city;
+DROP INDEX IF EXISTS idx_city_iata_country_code;
+DROP INDEX IF EXISTS idx_user_firebase_uid;
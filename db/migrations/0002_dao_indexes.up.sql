@@ -0,0 +1,35 @@
+-- indexes of the lookups done by the DAOs
+
+-- users are found by firebase uid at every authenticated request, the uid identifies the user.
+-- Databases created before the index may have duplicate uids: they are reported instead of failing
+-- on the index, and must be merged or deleted by hand before the migration is run again
+DO $$
+DECLARE
+    duplicates TEXT;
+BEGIN
+    SELECT string_agg(format('%s (users %s)', firebase_uid, user_ids), ', ')
+    INTO duplicates
+    FROM (
+        SELECT firebase_uid, string_agg(id_user::TEXT, ', ' ORDER BY id_user) AS user_ids
+        FROM "user"
+        GROUP BY firebase_uid
+        HAVING count(*) > 1
+    ) AS duplicate_users;
+
+    IF duplicates IS NOT NULL THEN
+        RAISE EXCEPTION 'duplicate firebase_uid in table "user": %', duplicates
+            USING HINT = 'merge or delete the duplicate users, then restart the server or run the migrate subcommand';
+    END IF;
+END
+$$;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_firebase_uid ON "user" (firebase_uid);
+
+-- cities are found by iata and country code in every search
+CREATE INDEX IF NOT EXISTS idx_city_iata_country_code ON city (city_iata, country_code);
+
+CREATE INDEX IF NOT EXISTS idx_airport_id_city ON airport (id_city);
+CREATE INDEX IF NOT EXISTS idx_travel_id_user ON travel (id_user);
+CREATE INDEX IF NOT EXISTS idx_segment_id_travel ON segment (id_travel);
+CREATE INDEX IF NOT EXISTS idx_review_id_city ON review (id_city);
+CREATE INDEX IF NOT EXISTS idx_review_id_user ON review (id_user);
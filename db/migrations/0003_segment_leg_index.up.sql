@@ -0,0 +1,2 @@
+-- multi-city travels, segments of old travels are read as the legacy outward and return legs
+ALTER TABLE segment ADD COLUMN IF NOT EXISTS leg_index integer NOT NULL DEFAULT 0;
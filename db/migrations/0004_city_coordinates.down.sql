@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_airport_latitude_longitude;
+DROP INDEX IF EXISTS idx_city_latitude_longitude;
+ALTER TABLE city DROP COLUMN IF EXISTS longitude;
+ALTER TABLE city DROP COLUMN IF EXISTS latitude;
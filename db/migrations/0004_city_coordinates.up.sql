@@ -0,0 +1,7 @@
+-- coordinates of the cities, used by the radius searches
+ALTER TABLE city ADD COLUMN IF NOT EXISTS latitude numeric;
+ALTER TABLE city ADD COLUMN IF NOT EXISTS longitude numeric;
+
+-- radius searches prefilter cities and airports with a bounding box
+CREATE INDEX IF NOT EXISTS idx_city_latitude_longitude ON city (latitude, longitude);
+CREATE INDEX IF NOT EXISTS idx_airport_latitude_longitude ON airport (latitude, longitude);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS vehicle_profile (
+    id_vehicle_profile serial PRIMARY KEY,
+    id_user            integer NOT NULL REFERENCES "user" (id_user) ON UPDATE CASCADE ON DELETE CASCADE,
+    name               text    NOT NULL,
+    fuel_type          text    NOT NULL,
+    consumption        numeric NOT NULL,
+    seats              integer NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_vehicle_profile_id_user ON vehicle_profile (id_user);
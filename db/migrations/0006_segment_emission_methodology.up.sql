@@ -0,0 +1,2 @@
+-- version of the emission factors used to compute co2_emitted
+ALTER TABLE segment ADD COLUMN IF NOT EXISTS emission_methodology text;
@@ -0,0 +1 @@
+ALTER TABLE segment DROP COLUMN IF EXISTS currency;
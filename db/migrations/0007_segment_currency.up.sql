@@ -0,0 +1,2 @@
+-- currency of the price of the segment, prices computed by the server are in euros
+ALTER TABLE segment ADD COLUMN IF NOT EXISTS currency text NOT NULL DEFAULT 'EUR';
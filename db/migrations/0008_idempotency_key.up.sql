@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS idempotency_key (
+    id_idempotency_key serial PRIMARY KEY,
+    id_user            integer     NOT NULL REFERENCES "user" (id_user) ON UPDATE CASCADE ON DELETE CASCADE,
+    key                text        NOT NULL,
+    request_hash       text        NOT NULL,
+    status_code        integer     NOT NULL DEFAULT 0,
+    response_body      bytea,
+    expires_at         timestamptz NOT NULL
+);
+
+-- a key can be reserved only once by a user
+CREATE UNIQUE INDEX IF NOT EXISTS idx_idempotency_key_user_key ON idempotency_key (id_user, key);
@@ -0,0 +1,44 @@
+package db_test
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"sync/atomic"
+	"time"
+)
+
+// queryCounter counts the statements executed by a gorm handle: gorm traces every statement
+// to the logger, so the counter wraps the logger of a session
+type queryCounter struct {
+	logger.Interface
+	count *atomic.Int64
+}
+
+func newQueryCounter(wrappedLogger logger.Interface) *queryCounter {
+	return &queryCounter{Interface: wrappedLogger, count: &atomic.Int64{}}
+}
+
+// Session returns a handle of database whose statements are counted, database itself is not affected
+func (counter *queryCounter) Session(database *gorm.DB) *gorm.DB {
+	return database.Session(&gorm.Session{Logger: counter})
+}
+
+// Count returns the number of statements executed since the last reset
+func (counter *queryCounter) Count() int64 {
+	return counter.count.Load()
+}
+
+func (counter *queryCounter) Reset() {
+	counter.count.Store(0)
+}
+
+// LogMode returns a counter sharing the count, with the log level of the wrapped logger changed
+func (counter *queryCounter) LogMode(level logger.LogLevel) logger.Interface {
+	return &queryCounter{Interface: counter.Interface.LogMode(level), count: counter.count}
+}
+
+func (counter *queryCounter) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	counter.count.Add(1)
+	counter.Interface.Trace(ctx, begin, fc, err)
+}
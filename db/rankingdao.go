@@ -2,8 +2,8 @@ package db
 
 import (
 	"gorm.io/gorm"
+	"green-journey-server/internals"
 	"green-journey-server/model"
-	"time"
 )
 
 type RankingDao struct {
@@ -21,28 +21,12 @@ func (rankingDAO *RankingDao) ComputeShortDistanceRanking(userID int) ([]model.R
 	err := rankingDAO.db.Order("score_short_distance DESC").Limit(10).Find(&topUsers).Error
 
 	// add requesting user if not present
-	topUsers, err = addCurrentUser(topUsers, userID)
+	topUsers, err = rankingDAO.addCurrentUser(topUsers, userID)
 	if err != nil {
 		return nil, err
 	}
 
-	// inject badges
-	userDAO := NewUserDAO(GetDB())
-	for i, _ := range topUsers {
-		err = userDAO.InjectBadges(&topUsers[i])
-	}
-
-	topRankingElements := []model.RankingElement{}
-	for _, topUser := range topUsers {
-		rankingElement, err1 := computeRankingElement(topUser)
-		if err1 != nil {
-			return nil, err1
-		}
-
-		topRankingElements = append(topRankingElements, rankingElement)
-	}
-
-	return topRankingElements, nil
+	return rankingDAO.computeRankingElements(topUsers)
 }
 
 func (rankingDAO *RankingDao) ComputeLongDistanceRanking(userID int) ([]model.RankingElement, error) {
@@ -52,31 +36,15 @@ func (rankingDAO *RankingDao) ComputeLongDistanceRanking(userID int) ([]model.Ra
 	err := rankingDAO.db.Order("score_long_distance DESC").Limit(10).Find(&topUsers).Error
 
 	// add requesting user if not present
-	topUsers, err = addCurrentUser(topUsers, userID)
+	topUsers, err = rankingDAO.addCurrentUser(topUsers, userID)
 	if err != nil {
 		return nil, err
 	}
 
-	// inject badges
-	userDAO := NewUserDAO(GetDB())
-	for i, _ := range topUsers {
-		err = userDAO.InjectBadges(&topUsers[i])
-	}
-
-	topRankingElements := []model.RankingElement{}
-	for _, topUser := range topUsers {
-		rankingElement, err1 := computeRankingElement(topUser)
-		if err1 != nil {
-			return nil, err1
-		}
-
-		topRankingElements = append(topRankingElements, rankingElement)
-	}
-
-	return topRankingElements, nil
+	return rankingDAO.computeRankingElements(topUsers)
 }
 
-func addCurrentUser(topUsers []model.User, userID int) ([]model.User, error) {
+func (rankingDAO *RankingDao) addCurrentUser(topUsers []model.User, userID int) ([]model.User, error) {
 	// check if requesting user present
 	found := false
 	for _, user := range topUsers {
@@ -88,9 +56,9 @@ func addCurrentUser(topUsers []model.User, userID int) ([]model.User, error) {
 
 	// add if not present
 	if !found {
-		// get user
-		userDAO := NewUserDAO(GetDB())
-		user, err := userDAO.GetUserById(userID)
+		// get user, badges are computed with the ranking element
+		userDAO := NewUserDAO(rankingDAO.db)
+		user, err := userDAO.GetUserByIdNoBadges(userID)
 		if err != nil {
 			return nil, err
 		}
@@ -102,42 +70,40 @@ func addCurrentUser(topUsers []model.User, userID int) ([]model.User, error) {
 	return topUsers, nil
 }
 
-func computeRankingElement(user model.User) (model.RankingElement, error) {
-	// compute values based on user travels
-	totalDistance := 0.0
-	totalDuration := time.Duration(0)
-	totalCO2Emitted := 0.0
-	totalCO2Compensated := 0.0
-
-	travelDAO := NewTravelDAO(GetDB())
-	travels, err := travelDAO.GetTravelRequestsByUserId(user.UserID)
-	if err != nil {
-		return model.RankingElement{}, err
+// computeRankingElements computes the badges and the values of the users: the travels of all the users
+// are loaded together, with the same number of queries whatever the number of users and travels
+func (rankingDAO *RankingDao) computeRankingElements(users []model.User) ([]model.RankingElement, error) {
+	userIDs := make([]int, len(users))
+	for i, user := range users {
+		userIDs[i] = user.UserID
 	}
 
-	for _, travelDetails := range travels {
-		if travelDetails.Travel.Confirmed {
-			totalCO2Compensated += travelDetails.Travel.CO2Compensated
+	// get travels of all users
+	var travels []model.Travel
+	result := rankingDAO.db.Where("id_user IN ?", userIDs).Order("id_travel").Find(&travels)
+	if result.Error != nil {
+		return nil, result.Error
+	}
 
-			for _, segment := range travelDetails.Segments {
-				totalDistance += segment.Distance
-				totalDuration += segment.Duration
-				totalCO2Emitted += segment.CO2Emitted
-			}
+	travelsByUser := map[int][]model.TravelDetails{}
+	if len(travels) > 0 {
+		travelDAO := NewTravelDAO(rankingDAO.db)
+		travelDetailsList, err := travelDAO.loadTravelDetails(travels)
+		if err != nil {
+			return nil, err
+		}
+		for _, travelDetails := range travelDetailsList {
+			userID := travelDetails.Travel.UserID
+			travelsByUser[userID] = append(travelsByUser[userID], travelDetails)
 		}
 	}
 
-	// create and return ranking element
-	return model.RankingElement{
-		UserID:              user.UserID,
-		FirstName:           user.FirstName,
-		LastName:            user.LastName,
-		ScoreShortDistance:  user.ScoreShortDistance,
-		ScoreLongDistance:   user.ScoreLongDistance,
-		TotalDistance:       totalDistance,
-		TotalDuration:       totalDuration,
-		TotalCO2Emitted:     totalCO2Emitted,
-		TotalCO2Compensated: totalCO2Compensated,
-		Badges:              user.Badges,
-	}, nil
+	topRankingElements := []model.RankingElement{}
+	for _, user := range users {
+		userTravels := travelsByUser[user.UserID]
+		user.Badges = internals.ComputeUserBadges(userTravels)
+		topRankingElements = append(topRankingElements, internals.ComputeRankingElement(user, userTravels))
+	}
+
+	return topRankingElements, nil
 }
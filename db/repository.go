@@ -0,0 +1,105 @@
+package db
+
+import (
+	"gorm.io/gorm"
+	"green-journey-server/model"
+)
+
+// ErrRecordNotFound is returned by every repository when the requested record doesn't exist
+var ErrRecordNotFound = gorm.ErrRecordNotFound
+
+// repositories of the server: the DAOs implement them with gorm, the memory package in memory
+
+type UserRepository interface {
+	GetUserById(id int) (model.User, error)
+	GetUserByIdNoBadges(id int) (model.User, error)
+	GetUserByFirebaseUID(firebaseUID string) (model.User, error)
+	GetUserByFirebaseUIDNoBadges(firebaseUID string) (model.User, error)
+	InjectBadges(user *model.User) error
+	AddUser(user model.User) (model.User, error)
+	UpdateUser(user model.User) error
+	DeleteUser(id int) error
+}
+
+type TravelRepository interface {
+	CreateTravel(travelDetails model.TravelDetails) (model.TravelDetails, error)
+	GetTravelRequestsByUserId(userID int) ([]model.TravelDetails, error)
+	GetTravelById(travelID int) (model.Travel, error)
+	GetTravelDetailsByTravelID(travelID int) (model.TravelDetails, error)
+	UpdateTravel(travel model.Travel, deltaScore float64, isShortDistance bool) error
+	DeleteTravel(travelID int, deltaScore float64, isShortDistance bool) error
+}
+
+type ReviewRepository interface {
+	GetReviewById(reviewID int) (model.Review, error)
+	// GetReviewByUserIDAndCityID returns nil if the user didn't review the city
+	GetReviewByUserIDAndCityID(userID int, cityID int) (*model.Review, error)
+	GetReviewsByCity(cityID int) ([]model.Review, error)
+	GetNextReviews(cityID int, reviewID int) (model.CityReviewElement, error)
+	GetPreviousReviews(cityID int, reviewID int) (model.CityReviewElement, error)
+	GetFirstReviewsByCityID(cityID int) (model.CityReviewElement, error)
+	GetLastReviewsByCityID(cityID int) (model.CityReviewElement, error)
+	CreateReview(review *model.Review) error
+	UpdateReview(review model.Review) error
+	DeleteReview(reviewID int) error
+	GetBestReviews() ([]model.CityReviewElement, error)
+}
+
+type CityRepository interface {
+	CreateCity(city *model.City) error
+	CreateAirport(airport *model.Airport) error
+	GetCities() ([]model.City, error)
+	GetNumAirportsByCity() (map[int]int, error)
+	GetCityById(cityID int) (model.City, error)
+	GetCityByIataAndCountryCode(cityIata, countryCode string) (model.City, error)
+	GetCityByNameAndCountry(cityName, countryName string) (model.City, error)
+	GetAirportByAirportIata(airportIata string) (model.Airport, error)
+	GetAirportsByCityId(cityID int) ([]model.Airport, error)
+	GetCityByAirportIata(airportIata string) (model.City, error)
+	GetNearbyCities(latitude, longitude, radius float64) ([]model.NearbyCity, error)
+	GetNearbyAirports(latitude, longitude, radius float64) ([]model.NearbyAirport, error)
+}
+
+type RankingRepository interface {
+	ComputeShortDistanceRanking(userID int) ([]model.RankingElement, error)
+	ComputeLongDistanceRanking(userID int) ([]model.RankingElement, error)
+}
+
+type VehicleProfileRepository interface {
+	CreateVehicleProfile(vehicleProfile *model.VehicleProfile) error
+	GetVehicleProfileById(vehicleProfileID int) (model.VehicleProfile, error)
+	GetVehicleProfilesByUserId(userID int) ([]model.VehicleProfile, error)
+	UpdateVehicleProfile(vehicleProfile model.VehicleProfile) error
+	DeleteVehicleProfile(vehicleProfileID int) error
+}
+
+type IdempotencyKeyRepository interface {
+	// ReserveIdempotencyKey returns the existing key if the user already has a valid one with the same value
+	ReserveIdempotencyKey(idempotencyKey *model.IdempotencyKey) (*model.IdempotencyKey, error)
+	SetIdempotencyKeyResponse(idempotencyKeyID int, statusCode int, responseBody []byte) error
+	DeleteIdempotencyKey(idempotencyKeyID int) error
+}
+
+// Repositories groups the repositories, they are created once and passed to the handlers
+type Repositories struct {
+	Users           UserRepository
+	Travels         TravelRepository
+	Reviews         ReviewRepository
+	Cities          CityRepository
+	Ranking         RankingRepository
+	VehicleProfiles VehicleProfileRepository
+	IdempotencyKeys IdempotencyKeyRepository
+}
+
+// NewRepositories returns the repositories backed by the database
+func NewRepositories(database *gorm.DB) Repositories {
+	return Repositories{
+		Users:           NewUserDAO(database),
+		Travels:         NewTravelDAO(database),
+		Reviews:         NewReviewDAO(database),
+		Cities:          NewCityDAO(database),
+		Ranking:         NewRankingDAO(database),
+		VehicleProfiles: NewVehicleProfileDAO(database),
+		IdempotencyKeys: NewIdempotencyKeyDAO(database),
+	}
+}
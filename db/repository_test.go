@@ -0,0 +1,499 @@
+package db_test
+
+import (
+	"bytes"
+	"errors"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"green-journey-server/db"
+	"green-journey-server/db/memory"
+	"green-journey-server/model"
+	"os"
+	"testing"
+	"time"
+)
+
+// testDatabaseDSN is the environment variable with the connection string of the database used by the tests:
+// its users, travels and reviews are deleted, tests needing Postgres are skipped if it is not set
+const testDatabaseDSN = "TEST_DATABASE_DSN"
+
+// cities created by the tests, deleted when the database is reset
+const testCountryCode = "ZZ"
+
+// id of no record, the database ids are 32 bit integers
+const missingID = 1 << 30
+
+// the database and the in-memory repositories are checked against the same contract,
+// so that handlers served by the in-memory ones behave as with the database
+
+func TestMemoryRepositories(t *testing.T) {
+	testRepositories(t, func(t *testing.T) db.Repositories {
+		return memory.NewRepositories(memory.NewStore())
+	})
+}
+
+func TestDatabaseRepositories(t *testing.T) {
+	database := openTestDatabase(t)
+	testRepositories(t, func(t *testing.T) db.Repositories {
+		resetTestDatabase(t, database)
+		return db.NewRepositories(database)
+	})
+}
+
+// openTestDatabase connects to the database of TEST_DATABASE_DSN and applies the pending migrations
+func openTestDatabase(tb testing.TB) *gorm.DB {
+	dsn := os.Getenv(testDatabaseDSN)
+	if dsn == "" {
+		tb.Skip(testDatabaseDSN + " not set")
+	}
+
+	database, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		sqlDB, err := database.DB()
+		if err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+
+	_, err = db.MigrateUp(database)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return database
+}
+
+// resetTestDatabase deletes the rows of the previous tests: like db.ResetTestDatabase, imported cities and airports are kept
+func resetTestDatabase(tb testing.TB, database *gorm.DB) {
+	err := database.Exec(`TRUNCATE TABLE review, reviews_aggregated, segment, travel, "user" CASCADE;`).Error
+	if err != nil {
+		tb.Fatal(err)
+	}
+	err = database.Exec("DELETE FROM city WHERE country_code = ?", testCountryCode).Error
+	if err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func testRepositories(t *testing.T, newRepositories func(t *testing.T) db.Repositories) {
+	t.Run("users", func(t *testing.T) {
+		testUserRepository(t, newRepositories(t))
+	})
+	t.Run("cities", func(t *testing.T) {
+		testCityRepository(t, newRepositories(t))
+	})
+	t.Run("travels", func(t *testing.T) {
+		testTravelRepository(t, newRepositories(t))
+	})
+	t.Run("reviews", func(t *testing.T) {
+		testReviewRepository(t, newRepositories(t))
+	})
+	t.Run("ranking", func(t *testing.T) {
+		testRankingRepository(t, newRepositories(t))
+	})
+	t.Run("vehicle profiles", func(t *testing.T) {
+		testVehicleProfileRepository(t, newRepositories(t))
+	})
+	t.Run("idempotency keys", func(t *testing.T) {
+		testIdempotencyKeyRepository(t, newRepositories(t))
+	})
+}
+
+func testUserRepository(t *testing.T, repositories db.Repositories) {
+	user := createTestUser(t, repositories, "user-a")
+
+	storedUser, err := repositories.Users.GetUserById(user.UserID)
+	if err != nil || storedUser.FirebaseUID != "user-a" {
+		t.Fatalf("GetUserById = %+v, %v", storedUser, err)
+	}
+	storedUser, err = repositories.Users.GetUserByFirebaseUID("user-a")
+	if err != nil || storedUser.UserID != user.UserID {
+		t.Fatalf("GetUserByFirebaseUID = %+v, %v", storedUser, err)
+	}
+	storedUser, err = repositories.Users.GetUserByFirebaseUIDNoBadges("user-a")
+	if err != nil || storedUser.UserID != user.UserID {
+		t.Fatalf("GetUserByFirebaseUIDNoBadges = %+v, %v", storedUser, err)
+	}
+	_, err = repositories.Users.GetUserById(missingID)
+	if !errors.Is(err, db.ErrRecordNotFound) {
+		t.Fatalf("GetUserById of a missing user = %v, want ErrRecordNotFound", err)
+	}
+	_, err = repositories.Users.GetUserByFirebaseUID("missing")
+	if !errors.Is(err, db.ErrRecordNotFound) {
+		t.Fatalf("GetUserByFirebaseUID of a missing user = %v, want ErrRecordNotFound", err)
+	}
+
+	user.FirstName = "Updated"
+	err = repositories.Users.UpdateUser(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storedUser, err = repositories.Users.GetUserByIdNoBadges(user.UserID)
+	if err != nil || storedUser.FirstName != "Updated" {
+		t.Fatalf("GetUserByIdNoBadges after update = %+v, %v", storedUser, err)
+	}
+
+	err = repositories.Users.DeleteUser(user.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repositories.Users.GetUserByIdNoBadges(user.UserID)
+	if !errors.Is(err, db.ErrRecordNotFound) {
+		t.Fatalf("GetUserByIdNoBadges of a deleted user = %v, want ErrRecordNotFound", err)
+	}
+	err = repositories.Users.DeleteUser(user.UserID)
+	if err == nil {
+		t.Fatal("DeleteUser of a deleted user succeeded")
+	}
+}
+
+func testCityRepository(t *testing.T, repositories db.Repositories) {
+	departureCity, destinationCity := createTestCities(t, repositories)
+
+	city, err := repositories.Cities.GetCityById(departureCity.CityID)
+	if err != nil || city.CityName != departureCity.CityName {
+		t.Fatalf("GetCityById = %+v, %v", city, err)
+	}
+	city, err = repositories.Cities.GetCityByIataAndCountryCode("ZZB", testCountryCode)
+	if err != nil || city.CityID != destinationCity.CityID {
+		t.Fatalf("GetCityByIataAndCountryCode = %+v, %v", city, err)
+	}
+	city, err = repositories.Cities.GetCityByNameAndCountry(destinationCity.CityName, *destinationCity.CountryName)
+	if err != nil || city.CityID != destinationCity.CityID {
+		t.Fatalf("GetCityByNameAndCountry = %+v, %v", city, err)
+	}
+	_, err = repositories.Cities.GetCityById(missingID)
+	if !errors.Is(err, db.ErrRecordNotFound) {
+		t.Fatalf("GetCityById of a missing city = %v, want ErrRecordNotFound", err)
+	}
+
+	airport := model.Airport{AirportName: "Test airport", AirportIata: "ZZX", Latitude: 45, Longitude: 9, CityID: departureCity.CityID}
+	err = repositories.Cities.CreateAirport(&airport)
+	if err != nil || airport.AirportID == 0 {
+		t.Fatalf("CreateAirport = %+v, %v", airport, err)
+	}
+	storedAirport, err := repositories.Cities.GetAirportByAirportIata("ZZX")
+	if err != nil || storedAirport.AirportID != airport.AirportID {
+		t.Fatalf("GetAirportByAirportIata = %+v, %v", storedAirport, err)
+	}
+	city, err = repositories.Cities.GetCityByAirportIata("ZZX")
+	if err != nil || city.CityID != departureCity.CityID {
+		t.Fatalf("GetCityByAirportIata = %+v, %v", city, err)
+	}
+	airports, err := repositories.Cities.GetAirportsByCityId(departureCity.CityID)
+	if err != nil || len(airports) != 1 {
+		t.Fatalf("GetAirportsByCityId = %+v, %v", airports, err)
+	}
+	numAirports, err := repositories.Cities.GetNumAirportsByCity()
+	if err != nil || numAirports[departureCity.CityID] != 1 || numAirports[destinationCity.CityID] != 0 {
+		t.Fatalf("GetNumAirportsByCity = %v, %v", numAirports, err)
+	}
+}
+
+func testTravelRepository(t *testing.T, repositories db.Repositories) {
+	user := createTestUser(t, repositories, "user-a")
+	departureCity, destinationCity := createTestCities(t, repositories)
+
+	travelDetails := createTestTravel(t, repositories, user, departureCity, destinationCity)
+	if travelDetails.Travel.TravelID == 0 || len(travelDetails.Segments) != 2 {
+		t.Fatalf("CreateTravel = %+v", travelDetails)
+	}
+	for _, segment := range travelDetails.Segments {
+		if segment.SegmentID == 0 || segment.TravelID != travelDetails.Travel.TravelID {
+			t.Fatalf("CreateTravel segment = %+v", segment)
+		}
+	}
+
+	travels, err := repositories.Travels.GetTravelRequestsByUserId(user.UserID)
+	if err != nil || len(travels) != 1 || len(travels[0].Segments) != 2 {
+		t.Fatalf("GetTravelRequestsByUserId = %+v, %v", travels, err)
+	}
+	outward, back := travels[0].Segments[0], travels[0].Segments[1]
+	if outward.DepartureCity != departureCity.CityName || outward.DestinationCity != destinationCity.CityName ||
+		outward.DestinationCountry != *destinationCity.CountryName || outward.LegIndex != 0 || back.LegIndex != 1 {
+		t.Fatalf("GetTravelRequestsByUserId segments = %+v", travels[0].Segments)
+	}
+	if travels[0].Travel.UserReview != nil || len(travels[0].Travel.UserReviews) != 0 {
+		t.Fatalf("GetTravelRequestsByUserId reviews = %+v", travels[0].Travel)
+	}
+
+	storedTravelDetails, err := repositories.Travels.GetTravelDetailsByTravelID(travelDetails.Travel.TravelID)
+	if err != nil || len(storedTravelDetails.Segments) != 2 {
+		t.Fatalf("GetTravelDetailsByTravelID = %+v, %v", storedTravelDetails, err)
+	}
+	_, err = repositories.Travels.GetTravelById(missingID)
+	if !errors.Is(err, db.ErrRecordNotFound) {
+		t.Fatalf("GetTravelById of a missing travel = %v, want ErrRecordNotFound", err)
+	}
+
+	// confirming adds the score, deleting removes it
+	travel := travelDetails.Travel
+	travel.Confirmed = true
+	err = repositories.Travels.UpdateTravel(travel, 5, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storedTravel, err := repositories.Travels.GetTravelById(travel.TravelID)
+	if err != nil || !storedTravel.Confirmed {
+		t.Fatalf("GetTravelById after update = %+v, %v", storedTravel, err)
+	}
+	storedUser, err := repositories.Users.GetUserByIdNoBadges(user.UserID)
+	if err != nil || storedUser.ScoreShortDistance != 5 || storedUser.ScoreLongDistance != 0 {
+		t.Fatalf("user after update = %+v, %v", storedUser, err)
+	}
+
+	err = repositories.Travels.DeleteTravel(travel.TravelID, 5, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repositories.Travels.GetTravelById(travel.TravelID)
+	if !errors.Is(err, db.ErrRecordNotFound) {
+		t.Fatalf("GetTravelById of a deleted travel = %v, want ErrRecordNotFound", err)
+	}
+	travels, err = repositories.Travels.GetTravelRequestsByUserId(user.UserID)
+	if err != nil || len(travels) != 0 {
+		t.Fatalf("GetTravelRequestsByUserId after delete = %+v, %v", travels, err)
+	}
+	storedUser, err = repositories.Users.GetUserByIdNoBadges(user.UserID)
+	if err != nil || storedUser.ScoreShortDistance != 0 {
+		t.Fatalf("user after delete = %+v, %v", storedUser, err)
+	}
+	err = repositories.Travels.DeleteTravel(travel.TravelID, 0, true)
+	if err == nil {
+		t.Fatal("DeleteTravel of a deleted travel succeeded")
+	}
+}
+
+func testReviewRepository(t *testing.T, repositories db.Repositories) {
+	user := createTestUser(t, repositories, "user-a")
+	departureCity, destinationCity := createTestCities(t, repositories)
+	travelDetails := createTestTravel(t, repositories, user, departureCity, destinationCity)
+
+	review := model.Review{
+		CityID:               destinationCity.CityID,
+		UserID:               user.UserID,
+		ReviewText:           "Test review",
+		LocalTransportRating: 4,
+		GreenSpacesRating:    3,
+		WasteBinsRating:      2,
+		DateTime:             time.Now().UTC().Truncate(time.Second),
+	}
+	err := repositories.Reviews.CreateReview(&review)
+	if err != nil || review.ReviewID == 0 {
+		t.Fatalf("CreateReview = %+v, %v", review, err)
+	}
+
+	storedReview, err := repositories.Reviews.GetReviewById(review.ReviewID)
+	if err != nil || storedReview.CityIata != "ZZB" || storedReview.CountryCode != testCountryCode || storedReview.FirstName != user.FirstName {
+		t.Fatalf("GetReviewById = %+v, %v", storedReview, err)
+	}
+	userReview, err := repositories.Reviews.GetReviewByUserIDAndCityID(user.UserID, destinationCity.CityID)
+	if err != nil || userReview == nil || userReview.ReviewID != review.ReviewID {
+		t.Fatalf("GetReviewByUserIDAndCityID = %+v, %v", userReview, err)
+	}
+	userReview, err = repositories.Reviews.GetReviewByUserIDAndCityID(user.UserID, departureCity.CityID)
+	if err != nil || userReview != nil {
+		t.Fatalf("GetReviewByUserIDAndCityID of a city not reviewed = %+v, %v", userReview, err)
+	}
+	reviews, err := repositories.Reviews.GetReviewsByCity(destinationCity.CityID)
+	if err != nil || len(reviews) != 1 {
+		t.Fatalf("GetReviewsByCity = %+v, %v", reviews, err)
+	}
+
+	// the review of the destination is injected in the travel
+	storedTravelDetails, err := repositories.Travels.GetTravelDetailsByTravelID(travelDetails.Travel.TravelID)
+	if err != nil || storedTravelDetails.Travel.UserReview == nil || storedTravelDetails.Travel.UserReview.ReviewID != review.ReviewID {
+		t.Fatalf("GetTravelDetailsByTravelID review = %+v, %v", storedTravelDetails.Travel, err)
+	}
+
+	review.LocalTransportRating = 1
+	err = repositories.Reviews.UpdateReview(review)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storedReview, err = repositories.Reviews.GetReviewById(review.ReviewID)
+	if err != nil || storedReview.LocalTransportRating != 1 {
+		t.Fatalf("GetReviewById after update = %+v, %v", storedReview, err)
+	}
+
+	err = repositories.Reviews.DeleteReview(review.ReviewID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repositories.Reviews.GetReviewById(review.ReviewID)
+	if !errors.Is(err, db.ErrRecordNotFound) {
+		t.Fatalf("GetReviewById of a deleted review = %v, want ErrRecordNotFound", err)
+	}
+}
+
+func testRankingRepository(t *testing.T, repositories db.Repositories) {
+	user := createTestUser(t, repositories, "user-a")
+	bestUser := createTestUser(t, repositories, "user-b")
+	departureCity, destinationCity := createTestCities(t, repositories)
+
+	// only the confirmed travel of the best user is counted
+	createTestTravel(t, repositories, user, departureCity, destinationCity)
+	travel := createTestTravel(t, repositories, bestUser, departureCity, destinationCity).Travel
+	travel.Confirmed = true
+	err := repositories.Travels.UpdateTravel(travel, 10, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ranking, err := repositories.Ranking.ComputeShortDistanceRanking(user.UserID)
+	if err != nil || len(ranking) != 2 {
+		t.Fatalf("ComputeShortDistanceRanking = %+v, %v", ranking, err)
+	}
+	if ranking[0].UserID != bestUser.UserID || ranking[0].ScoreShortDistance != 10 || ranking[0].TotalDistance != 400 ||
+		ranking[1].UserID != user.UserID || ranking[1].TotalDistance != 0 {
+		t.Fatalf("ComputeShortDistanceRanking = %+v", ranking)
+	}
+	_, err = repositories.Ranking.ComputeLongDistanceRanking(missingID)
+	if !errors.Is(err, db.ErrRecordNotFound) {
+		t.Fatalf("ComputeLongDistanceRanking of a missing user = %v, want ErrRecordNotFound", err)
+	}
+}
+
+func testVehicleProfileRepository(t *testing.T, repositories db.Repositories) {
+	user := createTestUser(t, repositories, "user-a")
+
+	vehicleProfiles := []model.VehicleProfile{
+		{UserID: user.UserID, Name: "Car", FuelType: model.FuelTypePetrol, Consumption: 6.5, Seats: 5},
+		{UserID: user.UserID, Name: "Van", FuelType: model.FuelTypeDiesel, Consumption: 8, Seats: 7},
+	}
+	for i := range vehicleProfiles {
+		err := repositories.VehicleProfiles.CreateVehicleProfile(&vehicleProfiles[i])
+		if err != nil || vehicleProfiles[i].VehicleProfileID == 0 {
+			t.Fatalf("CreateVehicleProfile = %+v, %v", vehicleProfiles[i], err)
+		}
+	}
+
+	storedVehicleProfiles, err := repositories.VehicleProfiles.GetVehicleProfilesByUserId(user.UserID)
+	if err != nil || len(storedVehicleProfiles) != 2 || storedVehicleProfiles[0].Name != "Car" {
+		t.Fatalf("GetVehicleProfilesByUserId = %+v, %v", storedVehicleProfiles, err)
+	}
+
+	vehicleProfile := vehicleProfiles[0]
+	vehicleProfile.Consumption = 5
+	err = repositories.VehicleProfiles.UpdateVehicleProfile(vehicleProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storedVehicleProfile, err := repositories.VehicleProfiles.GetVehicleProfileById(vehicleProfile.VehicleProfileID)
+	if err != nil || storedVehicleProfile.Consumption != 5 {
+		t.Fatalf("GetVehicleProfileById after update = %+v, %v", storedVehicleProfile, err)
+	}
+
+	err = repositories.VehicleProfiles.DeleteVehicleProfile(vehicleProfile.VehicleProfileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repositories.VehicleProfiles.GetVehicleProfileById(vehicleProfile.VehicleProfileID)
+	if !errors.Is(err, db.ErrRecordNotFound) {
+		t.Fatalf("GetVehicleProfileById of a deleted profile = %v, want ErrRecordNotFound", err)
+	}
+	err = repositories.VehicleProfiles.DeleteVehicleProfile(vehicleProfile.VehicleProfileID)
+	if err == nil {
+		t.Fatal("DeleteVehicleProfile of a deleted profile succeeded")
+	}
+}
+
+func testIdempotencyKeyRepository(t *testing.T, repositories db.Repositories) {
+	user := createTestUser(t, repositories, "user-a")
+	newKey := func() *model.IdempotencyKey {
+		return &model.IdempotencyKey{UserID: user.UserID, Key: "key", RequestHash: "hash", ExpiresAt: time.Now().UTC().Add(time.Hour)}
+	}
+
+	idempotencyKey := newKey()
+	existingKey, err := repositories.IdempotencyKeys.ReserveIdempotencyKey(idempotencyKey)
+	if err != nil || existingKey != nil || idempotencyKey.IdempotencyKeyID == 0 {
+		t.Fatalf("ReserveIdempotencyKey = %+v, %v", existingKey, err)
+	}
+
+	// a retry gets the reserved key, with the response once it is stored
+	existingKey, err = repositories.IdempotencyKeys.ReserveIdempotencyKey(newKey())
+	if err != nil || existingKey == nil || existingKey.StatusCode != 0 {
+		t.Fatalf("ReserveIdempotencyKey in progress = %+v, %v", existingKey, err)
+	}
+	err = repositories.IdempotencyKeys.SetIdempotencyKeyResponse(idempotencyKey.IdempotencyKeyID, 200, []byte("body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	existingKey, err = repositories.IdempotencyKeys.ReserveIdempotencyKey(newKey())
+	if err != nil || existingKey == nil || existingKey.StatusCode != 200 || !bytes.Equal(existingKey.ResponseBody, []byte("body")) {
+		t.Fatalf("ReserveIdempotencyKey completed = %+v, %v", existingKey, err)
+	}
+
+	// a released key can be reserved again
+	err = repositories.IdempotencyKeys.DeleteIdempotencyKey(idempotencyKey.IdempotencyKeyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	existingKey, err = repositories.IdempotencyKeys.ReserveIdempotencyKey(newKey())
+	if err != nil || existingKey != nil {
+		t.Fatalf("ReserveIdempotencyKey after delete = %+v, %v", existingKey, err)
+	}
+}
+
+func createTestUser(t *testing.T, repositories db.Repositories, firebaseUID string) model.User {
+	user, err := repositories.Users.AddUser(model.User{FirstName: "Test", LastName: firebaseUID, FirebaseUID: firebaseUID})
+	if err != nil || user.UserID == 0 {
+		t.Fatalf("AddUser = %+v, %v", user, err)
+	}
+	return user
+}
+
+func createTestCities(t *testing.T, repositories db.Repositories) (model.City, model.City) {
+	countryCode := testCountryCode
+	countryName := "Test country"
+	departureIata, destinationIata := "ZZA", "ZZB"
+	cities := []model.City{
+		{CityIata: &departureIata, CityName: "Test departure", CountryCode: &countryCode, CountryName: &countryName},
+		{CityIata: &destinationIata, CityName: "Test destination", CountryCode: &countryCode, CountryName: &countryName},
+	}
+	for i := range cities {
+		err := repositories.Cities.CreateCity(&cities[i])
+		if err != nil || cities[i].CityID == 0 {
+			t.Fatalf("CreateCity = %+v, %v", cities[i], err)
+		}
+	}
+	return cities[0], cities[1]
+}
+
+// createTestTravel creates a round trip by train, the return is the second leg
+func createTestTravel(t *testing.T, repositories db.Repositories, user model.User, departureCity, destinationCity model.City) model.TravelDetails {
+	date := time.Now().UTC().Truncate(time.Hour)
+	segment := model.Segment{
+		DepartureId:   departureCity.CityID,
+		DestinationId: destinationCity.CityID,
+		DateTime:      date,
+		Duration:      2 * time.Hour,
+		Vehicle:       "train",
+		Currency:      "EUR",
+		CO2Emitted:    10,
+		Distance:      200,
+		NumSegment:    1,
+		IsOutward:     true,
+		LegIndex:      0,
+	}
+	returnSegment := segment
+	returnSegment.DepartureId, returnSegment.DestinationId = segment.DestinationId, segment.DepartureId
+	returnSegment.DateTime = date.Add(24 * time.Hour)
+	returnSegment.NumSegment = 2
+	returnSegment.IsOutward = false
+	returnSegment.LegIndex = 1
+
+	travelDetails, err := repositories.Travels.CreateTravel(model.TravelDetails{
+		Travel:   model.Travel{UserID: user.UserID},
+		Segments: []model.Segment{segment, returnSegment},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return travelDetails
+}
@@ -28,7 +28,7 @@ func (reviewDAO *ReviewDAO) GetReviewById(reviewID int) (model.Review, error) {
 	}
 
 	// inject data
-	err := injectReviewData(&review)
+	err := reviewDAO.injectReviewData(&review)
 	if err != nil {
 		return model.Review{}, err
 	}
@@ -49,7 +49,7 @@ func (reviewDAO *ReviewDAO) GetReviewByUserIDAndCityID(userID int, cityID int) (
 	}
 
 	// inject review data
-	err := injectReviewData(&review)
+	err := reviewDAO.injectReviewData(&review)
 	if err != nil {
 		return nil, err
 	}
@@ -57,6 +57,43 @@ func (reviewDAO *ReviewDAO) GetReviewByUserIDAndCityID(userID int, cityID int) (
 	return &review, nil
 }
 
+// reviewRow is a review read together with the data of its city and user
+type reviewRow struct {
+	model.Review
+	JoinedCityIata    string `gorm:"column:joined_city_iata"`
+	JoinedCountryCode string `gorm:"column:joined_country_code"`
+	JoinedFirstName   string `gorm:"column:joined_first_name"`
+	JoinedLastName    string `gorm:"column:joined_last_name"`
+}
+
+// getReviewsByUsersAndCities returns the reviews of the users for the cities, sorted by id,
+// with their data injected in a single query
+func (reviewDAO *ReviewDAO) getReviewsByUsersAndCities(userIDs []int, cityIDs []int) ([]model.Review, error) {
+	var rows []reviewRow
+	result := reviewDAO.db.
+		Table("review").
+		Select(`review.*, COALESCE(city.city_iata, '') AS joined_city_iata, COALESCE(city.country_code, '') AS joined_country_code,
+			"user".first_name AS joined_first_name, "user".last_name AS joined_last_name`).
+		Joins("JOIN city ON city.id_city = review.id_city").
+		Joins(`JOIN "user" ON "user".id_user = review.id_user`).
+		Where("review.id_user IN ? AND review.id_city IN ?", userIDs, cityIDs).
+		Order("review.id_review").
+		Find(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	reviews := make([]model.Review, len(rows))
+	for i, row := range rows {
+		reviews[i] = row.Review
+		reviews[i].CityIata = row.JoinedCityIata
+		reviews[i].CountryCode = row.JoinedCountryCode
+		reviews[i].FirstName = row.JoinedFirstName
+		reviews[i].LastName = row.JoinedLastName
+	}
+	return reviews, nil
+}
+
 func (reviewDAO *ReviewDAO) GetReviewsByCity(cityID int) ([]model.Review, error) {
 	var reviews []model.Review
 
@@ -68,7 +105,7 @@ func (reviewDAO *ReviewDAO) GetReviewsByCity(cityID int) ([]model.Review, error)
 
 	// inject data
 	for i, _ := range reviews {
-		err := injectReviewData(&reviews[i])
+		err := reviewDAO.injectReviewData(&reviews[i])
 		if err != nil {
 			return nil, err
 		}
@@ -89,7 +126,7 @@ func (reviewDAO *ReviewDAO) GetNextReviews(cityID int, reviewID int) (model.City
 
 	// get next reviews
 	var reviews = []model.Review{}
-	result := db.
+	result := reviewDAO.db.
 		Where("(id_city = ?) AND ((date_time < ?) OR (date_time = ? AND id_review < ?))", cityID, review.DateTime, review.DateTime, review.ReviewID).
 		Order("date_time desc, id_review desc").
 		Limit(reviewsPageSize + 1).
@@ -100,7 +137,7 @@ func (reviewDAO *ReviewDAO) GetNextReviews(cityID int, reviewID int) (model.City
 
 	// inject data
 	for i, _ := range reviews {
-		err = injectReviewData(&reviews[i])
+		err = reviewDAO.injectReviewData(&reviews[i])
 		if err != nil {
 			return model.CityReviewElement{}, err
 		}
@@ -124,7 +161,7 @@ func (reviewDAO *ReviewDAO) GetNextReviews(cityID int, reviewID int) (model.City
 
 	// get number of reviews
 	var numReviews int64
-	result = db.Model(&model.Review{}).Where("id_city = ?", cityID).Count(&numReviews)
+	result = reviewDAO.db.Model(&model.Review{}).Where("id_city = ?", cityID).Count(&numReviews)
 	if result.Error != nil {
 		return model.CityReviewElement{}, result.Error
 	}
@@ -155,7 +192,7 @@ func (reviewDAO *ReviewDAO) GetPreviousReviews(cityID int, reviewID int) (model.
 	// get previous reviews
 	var reviews []model.Review
 
-	result := db.
+	result := reviewDAO.db.
 		Where("(id_city = ?) AND ((date_time > ?) OR (date_time = ? AND id_review > ?))", cityID, review.DateTime, review.DateTime, review.ReviewID).
 		Order("date_time asc, id_review asc").
 		Limit(reviewsPageSize + 1).
@@ -185,7 +222,7 @@ func (reviewDAO *ReviewDAO) GetPreviousReviews(cityID int, reviewID int) (model.
 
 	// get number of reviews
 	var numReviews int64
-	result = db.Model(&model.Review{}).Where("id_city = ?", cityID).Count(&numReviews)
+	result = reviewDAO.db.Model(&model.Review{}).Where("id_city = ?", cityID).Count(&numReviews)
 	if result.Error != nil {
 		return model.CityReviewElement{}, result.Error
 	}
@@ -207,7 +244,7 @@ func (reviewDAO *ReviewDAO) GetFirstReviewsByCityID(cityID int) (model.CityRevie
 	var reviews []model.Review
 
 	// get 11 reviews
-	result := db.
+	result := reviewDAO.db.
 		Where("id_city = ?", cityID).
 		Order("date_time desc, id_review desc").
 		Limit(reviewsPageSize + 1).
@@ -218,7 +255,7 @@ func (reviewDAO *ReviewDAO) GetFirstReviewsByCityID(cityID int) (model.CityRevie
 
 	// inject data
 	for i, _ := range reviews {
-		err := injectReviewData(&reviews[i])
+		err := reviewDAO.injectReviewData(&reviews[i])
 		if err != nil {
 			return model.CityReviewElement{}, err
 		}
@@ -243,7 +280,7 @@ func (reviewDAO *ReviewDAO) GetFirstReviewsByCityID(cityID int) (model.CityRevie
 
 	// get number of reviews
 	var numReviews int64
-	result = db.Model(&model.Review{}).Where("id_city = ?", cityID).Count(&numReviews)
+	result = reviewDAO.db.Model(&model.Review{}).Where("id_city = ?", cityID).Count(&numReviews)
 	if result.Error != nil {
 		return model.CityReviewElement{}, result.Error
 	}
@@ -264,7 +301,7 @@ func (reviewDAO *ReviewDAO) GetFirstReviewsByCityID(cityID int) (model.CityRevie
 func (reviewDAO *ReviewDAO) GetLastReviewsByCityID(cityID int) (model.CityReviewElement, error) {
 	// get number of reviews
 	var numReviews int64
-	result := db.Model(&model.Review{}).Where("id_city = ?", cityID).Count(&numReviews)
+	result := reviewDAO.db.Model(&model.Review{}).Where("id_city = ?", cityID).Count(&numReviews)
 	if result.Error != nil {
 		return model.CityReviewElement{}, result.Error
 	}
@@ -277,14 +314,14 @@ func (reviewDAO *ReviewDAO) GetLastReviewsByCityID(cityID int) (model.CityReview
 
 	// get reviews
 	var reviews []model.Review
-	result = db.Where("id_city = ?", cityID).Order("date_time desc, id_review desc").Offset(offset).Limit(reviewsPageSize).Find(&reviews)
+	result = reviewDAO.db.Where("id_city = ?", cityID).Order("date_time desc, id_review desc").Offset(offset).Limit(reviewsPageSize).Find(&reviews)
 	if result.Error != nil {
 		return model.CityReviewElement{}, result.Error
 	}
 
 	// inject data
 	for i, _ := range reviews {
-		err := injectReviewData(&reviews[i])
+		err := reviewDAO.injectReviewData(&reviews[i])
 		if err != nil {
 			return model.CityReviewElement{}, err
 		}
@@ -343,20 +380,20 @@ func (reviewDAO *ReviewDAO) computeReviewsAverages(cityId int) (float64, float64
 	return averageLocalTransportRating, averageGreenSpacesRating, averageWasteBinsRating, nil
 }
 
-func injectReviewData(review *model.Review) error {
+func (reviewDAO *ReviewDAO) injectReviewData(review *model.Review) error {
 	if review == nil {
 		return errors.New("review is nil")
 	}
 
 	// get city
-	cityDAO := NewCityDAO(GetDB())
+	cityDAO := NewCityDAO(reviewDAO.db)
 	city, err := cityDAO.GetCityById(review.CityID)
 	if err != nil {
 		return err
 	}
 
 	// get user
-	userDAO := NewUserDAO(GetDB())
+	userDAO := NewUserDAO(reviewDAO.db)
 	user, err := userDAO.GetUserByIdNoBadges(review.UserID)
 	if err != nil {
 		return err
@@ -469,7 +506,7 @@ func (reviewDAO *ReviewDAO) UpdateReview(review model.Review) error {
 	reviewsAggregated.SumWasteBinsRating -= oldReview.WasteBinsRating
 
 	// update review
-	result = reviewDAO.db.Save(&review)
+	result = transaction.Save(&review)
 	if result.Error != nil {
 		return result.Error
 	}
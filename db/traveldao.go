@@ -53,55 +53,30 @@ func (travelDAO *TravelDAO) CreateTravel(travelDetails model.TravelDetails) (mod
 	}
 
 	// inject review
-	err := injectReviewInTravel(&travelDetails)
+	travelDetailsList := []model.TravelDetails{travelDetails}
+	err := travelDAO.injectReviews(travelDetailsList)
 	if err != nil {
 		return model.TravelDetails{}, err
 	}
 
-	return travelDetails, nil
+	return travelDetailsList[0], nil
 }
 
+// GetTravelRequestsByUserId returns the travels of the user with their segments and reviews,
+// loaded with a constant number of queries whatever the number of travels
 func (travelDAO *TravelDAO) GetTravelRequestsByUserId(userID int) ([]model.TravelDetails, error) {
 	var travels []model.Travel
-	var travelDetailsList []model.TravelDetails
 
 	// get travels
-	result := travelDAO.db.Where("id_user = ?", userID).Find(&travels)
+	result := travelDAO.db.Where("id_user = ?", userID).Order("id_travel").Find(&travels)
 	if result.Error != nil {
 		return nil, result.Error
 	}
-
-	// get segments for every travel
-	for _, travel := range travels {
-		var segments []model.Segment
-
-		// get segments
-		result = travelDAO.db.Where("id_travel = ?", travel.TravelID).Find(&segments)
-		if result.Error != nil {
-			return nil, result.Error
-		}
-
-		// inject segment data
-		err := injectCityInSegments(segments)
-		if err != nil {
-			return nil, err
-		}
-
-		// add to travelRequests
-		travelDetailsList = append(travelDetailsList, model.TravelDetails{
-			Travel: travel, Segments: segments,
-		})
-	}
-
-	// inject reviews
-	for i, _ := range travelDetailsList {
-		err := injectReviewInTravel(&travelDetailsList[i])
-		if err != nil {
-			return []model.TravelDetails{}, err
-		}
+	if len(travels) == 0 {
+		return nil, nil
 	}
 
-	return travelDetailsList, nil
+	return travelDAO.loadTravelDetails(travels)
 }
 
 func (travelDAO *TravelDAO) GetTravelById(travelID int) (model.Travel, error) {
@@ -117,27 +92,11 @@ func (travelDAO *TravelDAO) GetTravelDetailsByTravelID(travelID int) (model.Trav
 		return model.TravelDetails{}, err
 	}
 
-	// get segments
-	var segments []model.Segment
-	result := travelDAO.db.Where("id_travel = ?", travel.TravelID).Find(&segments)
-	if result.Error != nil {
-		return model.TravelDetails{}, result.Error
-	}
-
-	err = injectCityInSegments(segments)
-	if err != nil {
-		return model.TravelDetails{}, err
-	}
-
-	travelDetails := model.TravelDetails{Travel: travel, Segments: segments}
-
-	// inject review
-	err = injectReviewInTravel(&travelDetails)
+	travelDetailsList, err := travelDAO.loadTravelDetails([]model.Travel{travel})
 	if err != nil {
 		return model.TravelDetails{}, err
 	}
-
-	return travelDetails, nil
+	return travelDetailsList[0], nil
 }
 
 func (travelDAO *TravelDAO) UpdateTravel(travel model.Travel, deltaScore float64, isShortDistance bool) error {
@@ -162,11 +121,10 @@ func (travelDAO *TravelDAO) UpdateTravel(travel model.Travel, deltaScore float64
 		return result.Error
 	}
 
-	// update user score
-	userDAO := NewUserDAO(GetDB())
+	// update user score, the user is read in the transaction
+	userDAO := NewUserDAO(transaction)
 	user, err := userDAO.GetUserById(travel.UserID)
 	if err != nil {
-		// out of transaction, so rollback
 		transaction.Rollback()
 		return err
 	}
@@ -227,8 +185,8 @@ func (travelDAO *TravelDAO) DeleteTravel(travelID int, deltaScore float64, isSho
 		return errors.New("travel not found")
 	}
 
-	// update user score
-	userDAO := NewUserDAO(GetDB())
+	// update user score, the user is read in the transaction
+	userDAO := NewUserDAO(transaction)
 	user, err1 := userDAO.GetUserById(userID)
 	if err1 != nil {
 		transaction.Rollback()
@@ -260,51 +218,120 @@ func (travelDAO *TravelDAO) DeleteTravel(travelID int, deltaScore float64, isSho
 	return nil
 }
 
-func injectReviewInTravel(travelDetails *model.TravelDetails) error {
-	// get destination city id
-	destinationSegment := travelDetails.GetDestinationSegment()
-	if destinationSegment == nil {
-		return fmt.Errorf("no destination segment")
+// segmentRow is a segment read together with its departure and destination cities
+type segmentRow struct {
+	model.Segment
+	JoinedDepartureCity      string `gorm:"column:joined_departure_city"`
+	JoinedDepartureCountry   string `gorm:"column:joined_departure_country"`
+	JoinedDestinationCity    string `gorm:"column:joined_destination_city"`
+	JoinedDestinationCountry string `gorm:"column:joined_destination_country"`
+}
+
+// loadTravelDetails loads the segments and the reviews of the travels, two queries for all of them
+func (travelDAO *TravelDAO) loadTravelDetails(travels []model.Travel) ([]model.TravelDetails, error) {
+	travelIDs := make([]int, len(travels))
+	for i, travel := range travels {
+		travelIDs[i] = travel.TravelID
 	}
-	destinationCityID := destinationSegment.DestinationId
 
-	// get review
-	reviewDAO := NewReviewDAO(GetDB())
-	review, err := reviewDAO.GetReviewByUserIDAndCityID(travelDetails.Travel.UserID, destinationCityID)
-	if err != nil {
-		return err
+	// get segments of all travels, with the names of their cities: the joins are left joins
+	// so that a segment whose city is missing is still returned, with empty names
+	var rows []segmentRow
+	result := travelDAO.db.
+		Table("segment").
+		Select(`segment.*,
+			COALESCE(departure.city_name, '') AS joined_departure_city, COALESCE(departure.country_name, '') AS joined_departure_country,
+			COALESCE(destination.city_name, '') AS joined_destination_city, COALESCE(destination.country_name, '') AS joined_destination_country`).
+		Joins("LEFT JOIN city AS departure ON departure.id_city = segment.id_departure").
+		Joins("LEFT JOIN city AS destination ON destination.id_city = segment.id_destination").
+		Where("segment.id_travel IN ?", travelIDs).
+		Order("segment.id_segment").
+		Find(&rows)
+	if result.Error != nil {
+		return nil, result.Error
 	}
 
-	// inject review
-	travelDetails.Travel.UserReview = review
+	segmentsByTravel := map[int][]model.Segment{}
+	for _, row := range rows {
+		segment := row.Segment
+		segment.DepartureCity = row.JoinedDepartureCity
+		segment.DepartureCountry = row.JoinedDepartureCountry
+		segment.DestinationCity = row.JoinedDestinationCity
+		segment.DestinationCountry = row.JoinedDestinationCountry
+		segmentsByTravel[segment.TravelID] = append(segmentsByTravel[segment.TravelID], segment)
+	}
 
-	return nil
+	travelDetailsList := make([]model.TravelDetails, len(travels))
+	for i, travel := range travels {
+		travelDetailsList[i] = model.TravelDetails{Travel: travel, Segments: segmentsByTravel[travel.TravelID]}
+		travelDetailsList[i].SetLegacyLegIndexes()
+	}
+
+	// inject reviews
+	err := travelDAO.injectReviews(travelDetailsList)
+	if err != nil {
+		return nil, err
+	}
+
+	return travelDetailsList, nil
 }
 
-func injectCityInSegments(segments []model.Segment) error {
-	// add departure and destination to segments
-	cityDAO := NewCityDAO(GetDB())
-	for i, _ := range segments {
-		originCity, err := cityDAO.GetCityById(segments[i].DepartureId)
-		if err != nil {
-			return err
+// injectReviews injects the reviews of the users for all the cities visited by the travels,
+// read with a single query
+func (travelDAO *TravelDAO) injectReviews(travelDetailsList []model.TravelDetails) error {
+	// get visited cities
+	var userIDs, cityIDs []int
+	foundUserIDs, foundCityIDs := map[int]bool{}, map[int]bool{}
+	visitedCityIDs := make([][]int, len(travelDetailsList))
+	for i := range travelDetailsList {
+		visitedCityIDs[i] = travelDetailsList[i].GetVisitedCityIDs()
+		if len(visitedCityIDs[i]) == 0 {
+			return fmt.Errorf("no destination segment")
 		}
-		destinationCity, err := cityDAO.GetCityById(segments[i].DestinationId)
-		if err != nil {
-			return err
+		if userID := travelDetailsList[i].Travel.UserID; !foundUserIDs[userID] {
+			foundUserIDs[userID] = true
+			userIDs = append(userIDs, userID)
 		}
-		segments[i].DepartureCity = originCity.CityName
-		if originCity.CountryName != nil {
-			segments[i].DepartureCountry = *originCity.CountryName
-		} else {
-			segments[i].DepartureCountry = ""
+		for _, cityID := range visitedCityIDs[i] {
+			if !foundCityIDs[cityID] {
+				foundCityIDs[cityID] = true
+				cityIDs = append(cityIDs, cityID)
+			}
+		}
+	}
+
+	// get reviews, the first review of a user for a city is used
+	reviewDAO := NewReviewDAO(travelDAO.db)
+	reviews, err := reviewDAO.getReviewsByUsersAndCities(userIDs, cityIDs)
+	if err != nil {
+		return err
+	}
+	type userCity struct{ userID, cityID int }
+	reviewsByUserCity := map[userCity]model.Review{}
+	for _, review := range reviews {
+		key := userCity{review.UserID, review.CityID}
+		if _, ok := reviewsByUserCity[key]; !ok {
+			reviewsByUserCity[key] = review
 		}
-		segments[i].DestinationCity = destinationCity.CityName
-		if destinationCity.CountryName != nil {
-			segments[i].DestinationCountry = *destinationCity.CountryName
-		} else {
-			segments[i].DestinationCountry = ""
+	}
+
+	for i := range travelDetailsList {
+		travel := &travelDetailsList[i].Travel
+		travel.UserReview = nil
+		travel.UserReviews = []model.Review{}
+		for j, cityID := range visitedCityIDs[i] {
+			review, ok := reviewsByUserCity[userCity{travel.UserID, cityID}]
+			if !ok {
+				continue
+			}
+
+			// inject review, the first destination is the main one
+			if j == 0 {
+				travel.UserReview = &review
+			}
+			travel.UserReviews = append(travel.UserReviews, review)
 		}
 	}
+
 	return nil
 }
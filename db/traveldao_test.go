@@ -0,0 +1,159 @@
+package db_test
+
+import (
+	"gorm.io/gorm"
+	"green-journey-server/db"
+	"green-journey-server/model"
+	"testing"
+	"time"
+)
+
+// travels of the benchmark user
+const numBenchmarkTravels = 100
+
+// BenchmarkTravelQueries counts the queries of the calls loading travels, for a user with numBenchmarkTravels
+// round trips. The count must be the same as with a single travel.
+// Run with TEST_DATABASE_DSN=... go test -run=^$ -bench=TravelQueries ./db/
+func BenchmarkTravelQueries(b *testing.B) {
+	database := openTestDatabase(b)
+	resetTestDatabase(b, database)
+
+	// the data is created in a transaction rolled back at the end, so the database is not changed
+	transaction := database.Begin()
+	if transaction.Error != nil {
+		b.Fatal(transaction.Error)
+	}
+	b.Cleanup(func() {
+		transaction.Rollback()
+	})
+
+	// only the statements of the measured calls are counted
+	counter := newQueryCounter(transaction.Logger)
+	countedTransaction := counter.Session(transaction)
+
+	user, departureCity, destinationCity := createBenchmarkData(b, transaction)
+	calls := []struct {
+		name string
+		call func() error
+	}{
+		{"travels of the user", func() error {
+			_, err := db.NewTravelDAO(countedTransaction).GetTravelRequestsByUserId(user.UserID)
+			return err
+		}},
+		{"user with badges", func() error {
+			_, err := db.NewUserDAO(countedTransaction).GetUserById(user.UserID)
+			return err
+		}},
+		{"short distance ranking", func() error {
+			_, err := db.NewRankingDAO(countedTransaction).ComputeShortDistanceRanking(user.UserID)
+			return err
+		}},
+	}
+	countQueries := func(b *testing.B, call func() error) int64 {
+		counter.Reset()
+		err := call()
+		if err != nil {
+			b.Fatal(err)
+		}
+		return counter.Count()
+	}
+
+	createBenchmarkTravels(b, transaction, user, departureCity, destinationCity, 1)
+	singleTravelQueries := make([]int64, len(calls))
+	for i, call := range calls {
+		singleTravelQueries[i] = countQueries(b, call.call)
+	}
+	createBenchmarkTravels(b, transaction, user, departureCity, destinationCity, numBenchmarkTravels-1)
+
+	for i, call := range calls {
+		b.Run(call.name, func(b *testing.B) {
+			var queries int64
+			for range b.N {
+				queries = countQueries(b, call.call)
+			}
+			if queries != singleTravelQueries[i] {
+				b.Fatalf("%d queries with %d travels, %d with one", queries, numBenchmarkTravels, singleTravelQueries[i])
+			}
+			b.ReportMetric(float64(queries), "queries/op")
+		})
+	}
+}
+
+// createBenchmarkData creates two cities and a user with a review of the destination.
+// The rows are created directly in the transaction, as the DAOs would open a nested one
+func createBenchmarkData(b *testing.B, transaction *gorm.DB) (model.User, model.City, model.City) {
+	countryCode := testCountryCode
+	countryName := "Benchmark"
+	departureIata, destinationIata := "ZZA", "ZZB"
+	cities := []model.City{
+		{CityIata: &departureIata, CityName: "Benchmark departure", CountryCode: &countryCode, CountryName: &countryName},
+		{CityIata: &destinationIata, CityName: "Benchmark destination", CountryCode: &countryCode, CountryName: &countryName},
+	}
+	result := transaction.Create(&cities)
+	if result.Error != nil {
+		b.Fatal(result.Error)
+	}
+
+	user := model.User{FirstName: "Benchmark", LastName: "User", FirebaseUID: "benchmark"}
+	result = transaction.Create(&user)
+	if result.Error != nil {
+		b.Fatal(result.Error)
+	}
+
+	review := model.Review{
+		CityID:               cities[1].CityID,
+		UserID:               user.UserID,
+		ReviewText:           "Benchmark review",
+		LocalTransportRating: 3,
+		GreenSpacesRating:    3,
+		WasteBinsRating:      3,
+		DateTime:             time.Now().UTC(),
+	}
+	result = transaction.Create(&review)
+	if result.Error != nil {
+		b.Fatal(result.Error)
+	}
+	return user, cities[0], cities[1]
+}
+
+// createBenchmarkTravels creates numTravels confirmed round trips of the user by train
+func createBenchmarkTravels(b *testing.B, transaction *gorm.DB, user model.User, departureCity, destinationCity model.City, numTravels int) {
+	travels := make([]model.Travel, numTravels)
+	for i := range travels {
+		travels[i] = model.Travel{UserID: user.UserID, Confirmed: true}
+	}
+	result := transaction.Create(&travels)
+	if result.Error != nil {
+		b.Fatal(result.Error)
+	}
+
+	// outward and return segment of every travel
+	date := time.Now().UTC().Truncate(time.Hour)
+	var segments []model.Segment
+	for _, travel := range travels {
+		segment := model.Segment{
+			DepartureId:   departureCity.CityID,
+			DestinationId: destinationCity.CityID,
+			DateTime:      date,
+			Duration:      2 * time.Hour,
+			Vehicle:       "train",
+			Currency:      "EUR",
+			CO2Emitted:    10,
+			Distance:      200,
+			NumSegment:    1,
+			IsOutward:     true,
+			LegIndex:      0,
+			TravelID:      travel.TravelID,
+		}
+		returnSegment := segment
+		returnSegment.DepartureId, returnSegment.DestinationId = segment.DestinationId, segment.DepartureId
+		returnSegment.DateTime = date.Add(24 * time.Hour)
+		returnSegment.IsOutward = false
+		returnSegment.LegIndex = 1
+		segments = append(segments, segment, returnSegment)
+	}
+	result = transaction.Create(&segments)
+	if result.Error != nil {
+		b.Fatal(result.Error)
+	}
+}
@@ -48,52 +48,23 @@ func (userDAO *UserDAO) GetUserByFirebaseUID(firebaseUID string) (model.User, er
 	return user, result.Error
 }
 
+func (userDAO *UserDAO) GetUserByFirebaseUIDNoBadges(firebaseUID string) (model.User, error) {
+	var user model.User
+	result := userDAO.db.Where("firebase_uid = ?", firebaseUID).First(&user)
+
+	return user, result.Error
+}
+
+// InjectBadges computes the badges of the user from their travels, read with the same handle
 func (userDAO *UserDAO) InjectBadges(user *model.User) error {
-	// empty slice if no badge
-	badges := []model.Badge{}
-
-	// compute data
-	totalDistance := 0.0
-	totalCO2Emitted := 0.0
-	totalCO2Compensated := 0.0
-	numTravels := 0
-	travelDAO := NewTravelDAO(GetDB())
+	travelDAO := NewTravelDAO(userDAO.db)
 	travels, err := travelDAO.GetTravelRequestsByUserId(user.UserID)
 	if err != nil {
 		return err
 	}
-	for _, travelDetails := range travels {
-		if travelDetails.Travel.Confirmed {
-			numTravels++
-			totalCO2Compensated += travelDetails.Travel.CO2Compensated
-
-			for _, segment := range travelDetails.Segments {
-				totalDistance += segment.Distance
-				totalCO2Emitted += segment.CO2Emitted
-			}
-		}
-	}
-
-	// compute badges
-	distanceBadge, err := internals.ComputeDistanceBadge(totalDistance)
-	if err == nil {
-		badges = append(badges, distanceBadge)
-	}
-	ecologicalChoiceBadge, err := internals.ComputeEcologicalChoiceBadge(totalDistance, totalCO2Emitted)
-	if err == nil {
-		badges = append(badges, ecologicalChoiceBadge)
-	}
-	compensationBadge, err := internals.ComputeCompensationBadge(totalCO2Compensated, totalCO2Emitted)
-	if err == nil {
-		badges = append(badges, compensationBadge)
-	}
-	numTravelsBadge, err := internals.ComputeTravelsNumberCoefficient(numTravels)
-	if err == nil {
-		badges = append(badges, numTravelsBadge)
-	}
 
 	// inject badges
-	user.Badges = badges
+	user.Badges = internals.ComputeUserBadges(travels)
 	return nil
 }
 
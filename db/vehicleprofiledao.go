@@ -0,0 +1,49 @@
+package db
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"green-journey-server/model"
+)
+
+type VehicleProfileDAO struct {
+	db *gorm.DB
+}
+
+func NewVehicleProfileDAO(db *gorm.DB) *VehicleProfileDAO {
+	return &VehicleProfileDAO{db: db}
+}
+
+func (vehicleProfileDAO *VehicleProfileDAO) CreateVehicleProfile(vehicleProfile *model.VehicleProfile) error {
+	// takes a pointer, in order to update the param struct
+	result := vehicleProfileDAO.db.Create(vehicleProfile)
+	return result.Error
+}
+
+func (vehicleProfileDAO *VehicleProfileDAO) GetVehicleProfileById(vehicleProfileID int) (model.VehicleProfile, error) {
+	var vehicleProfile model.VehicleProfile
+	result := vehicleProfileDAO.db.First(&vehicleProfile, vehicleProfileID)
+	return vehicleProfile, result.Error
+}
+
+func (vehicleProfileDAO *VehicleProfileDAO) GetVehicleProfilesByUserId(userID int) ([]model.VehicleProfile, error) {
+	var vehicleProfiles []model.VehicleProfile
+	result := vehicleProfileDAO.db.Where("id_user = ?", userID).Order("id_vehicle_profile").Find(&vehicleProfiles)
+	return vehicleProfiles, result.Error
+}
+
+func (vehicleProfileDAO *VehicleProfileDAO) UpdateVehicleProfile(vehicleProfile model.VehicleProfile) error {
+	result := vehicleProfileDAO.db.Save(&vehicleProfile)
+	return result.Error
+}
+
+func (vehicleProfileDAO *VehicleProfileDAO) DeleteVehicleProfile(vehicleProfileID int) error {
+	result := vehicleProfileDAO.db.Delete(&model.VehicleProfile{}, vehicleProfileID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("vehicle profile not found")
+	}
+	return nil
+}
@@ -2,11 +2,11 @@ package externals
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/joho/godotenv"
-	"gorm.io/gorm"
+	"green-journey-server/config"
 	"green-journey-server/db"
 	"green-journey-server/internals"
 	"green-journey-server/model"
@@ -15,18 +15,36 @@ import (
 	"math/rand"
 	"net/http"
 	"net/url"
-	"os"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
 )
 
-var accessToken string
-var amadeusApiKey string
-var amadeusApiSecret string
-var mockOptions bool
+// AmadeusApi calls the Amadeus apis: if mockOptions is set, mock flights are returned
+// when Amadeus has no offers. Cities and airports of the flights are read and stored with cities
+type AmadeusApi struct {
+	baseUrl     string
+	apiKey      string
+	apiSecret   string
+	cities      db.CityRepository
+	mockOptions bool
+
+	// access token of the apis, renewed when it expires
+	accessToken      string
+	accessTokenMutex sync.RWMutex
+}
+
+// FlightOptions contains the flight specific parameters of a search
+type FlightOptions struct {
+	// cabin class of the searched offers, economy if empty
+	CabinClass string
+	// apply the radiative forcing multiplier to the emissions
+	RadiativeForcing bool
+}
 
 // firebase authentication structure
 
@@ -40,16 +58,26 @@ type FlightResponse struct {
 	Data []FlightOffer `json:"data"`
 }
 type FlightOffer struct {
-	Itineraries []Itinerary  `json:"itineraries"`
-	Price       *FlightPrice `json:"price"`
+	Itineraries      []Itinerary       `json:"itineraries"`
+	Price            *FlightPrice      `json:"price"`
+	TravelerPricings []TravelerPricing `json:"travelerPricings"`
+}
+type TravelerPricing struct {
+	FareDetailsBySegment []FareDetails `json:"fareDetailsBySegment"`
+}
+type FareDetails struct {
+	SegmentID string `json:"segmentId"`
+	Cabin     string `json:"cabin"`
 }
 type FlightPrice struct {
+	Currency   string `json:"currency"`
 	GrandTotal string `json:"grandTotal"`
 }
 type Itinerary struct {
 	Segments []FlightSegment `json:"segments"`
 }
 type FlightSegment struct {
+	ID          string   `json:"id"`
 	Departure   *Airport `json:"departure"`
 	Arrival     *Airport `json:"arrival"`
 	CarrierCode string   `json:"carrierCode"`
@@ -79,23 +107,31 @@ type GeoCode struct {
 	Longitude *float64 `json:"longitude"`
 }
 
-func InitAmadeusApi(mockOptionsArg bool) {
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatal("Error loading .env file")
+func NewAmadeusApi(amadeusConfig config.AmadeusConfig, cities db.CityRepository, mockOptions bool) *AmadeusApi {
+	return &AmadeusApi{
+		baseUrl:     strings.TrimSuffix(amadeusConfig.BaseURL, "/"),
+		apiKey:      amadeusConfig.APIKey,
+		apiSecret:   amadeusConfig.APISecret,
+		cities:      cities,
+		mockOptions: mockOptions,
 	}
-	amadeusApiKey = os.Getenv("AMADEUS_API_KEY")
-	amadeusApiSecret = os.Getenv("AMADEUS_API_SECRET")
-	mockOptions = mockOptionsArg
 }
 
-func GetAccessToken() error {
+// getAuthorizationHeader returns the value of the authorization header, with the current access token
+func (amadeusApi *AmadeusApi) getAuthorizationHeader() string {
+	amadeusApi.accessTokenMutex.RLock()
+	defer amadeusApi.accessTokenMutex.RUnlock()
+
+	return "Bearer " + amadeusApi.accessToken
+}
+
+func (amadeusApi *AmadeusApi) GetAccessToken(ctx context.Context) error {
 	// access token url
-	accessTokenUrl := "https://test.api.amadeus.com/v1/security/oauth2/token"
+	accessTokenUrl := amadeusApi.baseUrl + "/v1/security/oauth2/token"
 
 	// create POST request
-	payload := []byte("grant_type=client_credentials&client_id=" + amadeusApiKey + "&client_secret=" + amadeusApiSecret)
-	req, err := http.NewRequest("POST", accessTokenUrl, bytes.NewBuffer(payload))
+	payload := []byte("grant_type=client_credentials&client_id=" + amadeusApi.apiKey + "&client_secret=" + amadeusApi.apiSecret)
+	req, err := http.NewRequestWithContext(ctx, "POST", accessTokenUrl, bytes.NewBuffer(payload))
 	if err != nil {
 		log.Println("Error creating request: ", err)
 		return err
@@ -128,27 +164,29 @@ func GetAccessToken() error {
 		log.Println("Error unmarshalling auth response: ", err)
 		return err
 	}
-	accessToken = authResponse.AccessToken
+	amadeusApi.accessTokenMutex.Lock()
+	amadeusApi.accessToken = authResponse.AccessToken
+	amadeusApi.accessTokenMutex.Unlock()
 
 	return nil
 }
 
-func GetFlights(departureCity, destinationCity model.City, date time.Time, t time.Time, isOutbound bool) ([][]model.Segment, error) {
+func (amadeusApi *AmadeusApi) GetFlights(ctx context.Context, departureCity, destinationCity model.City, date time.Time, t time.Time, isOutbound bool, flightOptions FlightOptions) ([][]model.Segment, error) {
 	var flights [][]model.Segment
 
-	flights, err := getRealFlights(departureCity, destinationCity, date, isOutbound)
+	flights, err := amadeusApi.getRealFlights(ctx, departureCity, destinationCity, date, isOutbound, flightOptions)
 
 	if err != nil || flights == nil || len(flights) == 0 {
-		if mockOptions {
+		if amadeusApi.mockOptions {
 			dateTime := time.Date(date.Year(), date.Month(), date.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
-			return getMockFlights(departureCity, destinationCity, dateTime, isOutbound), nil
+			return amadeusApi.getMockFlights(departureCity, destinationCity, dateTime, isOutbound), nil
 		}
 	}
 
 	return flights, err
 }
 
-func getRealFlights(departureCity, destinationCity model.City, date time.Time, isOutbound bool) ([][]model.Segment, error) {
+func (amadeusApi *AmadeusApi) getRealFlights(ctx context.Context, departureCity, destinationCity model.City, date time.Time, isOutbound bool, flightOptions FlightOptions) ([][]model.Segment, error) {
 	// get cities iata codes
 	if departureCity.CityIata == nil {
 		return nil, fmt.Errorf("null departure city iata")
@@ -159,9 +197,6 @@ func getRealFlights(departureCity, destinationCity model.City, date time.Time, i
 	}
 	destinationIata := *destinationCity.CityIata
 
-	// amadeus flight offers search url
-	baseUrl := "https://test.api.amadeus.com/v2/shopping/flight-offers"
-
 	// compute departure-time value
 	departureDate := date.Format("2006-01-02")
 
@@ -172,22 +207,133 @@ func getRealFlights(departureCity, destinationCity model.City, date time.Time, i
 	params.Add("departureDate", departureDate)
 	params.Add("adults", "1")
 	params.Add("max", "2")
+	if flightOptions.CabinClass != "" {
+		params.Add("travelClass", strings.ToUpper(flightOptions.CabinClass))
+	}
 
-	apiUrl := fmt.Sprintf("%s?%s", baseUrl, params.Encode())
+	response, err := amadeusApi.requestFlightOffers(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var flights [][]model.Segment
 
-	// create request
-	req, err := http.NewRequest("GET", apiUrl, nil)
+	for _, flightOffer := range response.Data {
+		// check no missing data
+		if flightOffer.Itineraries == nil ||
+			len(flightOffer.Itineraries) == 0 {
+			// a different offer might have data
+			// don't return, just skip an iteration
+			continue
+		}
+
+		flight, distances, ok := amadeusApi.decodeItinerary(ctx, flightOffer.Itineraries[0], getCabinClasses(flightOffer), isOutbound, flightOptions.RadiativeForcing)
+		if ok {
+			// set indicative price to segments
+			setIndicativePrice(flight, distances, flightOffer.Price)
+			flights = append(flights, flight)
+		}
+	}
+
+	return flights, nil
+}
+
+func (amadeusApi *AmadeusApi) GetRoundTripFlights(ctx context.Context, departureCity, destinationCity model.City, date, t, returnDate, returnTime time.Time, flightOptions FlightOptions) ([]model.RoundTripOption, error) {
+	flights, err := amadeusApi.getRealRoundTripFlights(ctx, departureCity, destinationCity, date, returnDate, flightOptions)
+
+	if err != nil || len(flights) == 0 {
+		if amadeusApi.mockOptions {
+			dateTime := time.Date(date.Year(), date.Month(), date.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+			returnDateTime := time.Date(returnDate.Year(), returnDate.Month(), returnDate.Day(), returnTime.Hour(), returnTime.Minute(), returnTime.Second(), returnTime.Nanosecond(), returnTime.Location())
+			outwardFlights := amadeusApi.getMockFlights(departureCity, destinationCity, dateTime, true)
+			returnFlights := amadeusApi.getMockFlights(destinationCity, departureCity, returnDateTime, false)
+			return []model.RoundTripOption{internals.ComputeRoundTripOption(outwardFlights[0], returnFlights[0])}, nil
+		}
+	}
+
+	return flights, err
+}
+
+// getRealRoundTripFlights asks Amadeus for round trip offers: every offer has an outward and a return itinerary,
+// the price of the offer refers to both of them
+func (amadeusApi *AmadeusApi) getRealRoundTripFlights(ctx context.Context, departureCity, destinationCity model.City, date, returnDate time.Time, flightOptions FlightOptions) ([]model.RoundTripOption, error) {
+	// get cities iata codes
+	if departureCity.CityIata == nil {
+		return nil, fmt.Errorf("null departure city iata")
+	}
+	if destinationCity.CityIata == nil {
+		return nil, fmt.Errorf("null destination city iata")
+	}
+
+	// compose url
+	params := url.Values{}
+	params.Add("originLocationCode", *departureCity.CityIata)
+	params.Add("destinationLocationCode", *destinationCity.CityIata)
+	params.Add("departureDate", date.Format("2006-01-02"))
+	params.Add("returnDate", returnDate.Format("2006-01-02"))
+	params.Add("adults", "1")
+	params.Add("max", "2")
+	if flightOptions.CabinClass != "" {
+		params.Add("travelClass", strings.ToUpper(flightOptions.CabinClass))
+	}
+
+	response, err := amadeusApi.requestFlightOffers(ctx, params)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	client := &http.Client{Timeout: 3 * time.Second}
+
+	var roundTrips []model.RoundTripOption
+
+	for _, flightOffer := range response.Data {
+		// check no missing data
+		if len(flightOffer.Itineraries) < 2 {
+			continue
+		}
+
+		cabinClasses := getCabinClasses(flightOffer)
+		outwardFlight, outwardDistances, ok := amadeusApi.decodeItinerary(ctx, flightOffer.Itineraries[0], cabinClasses, true, flightOptions.RadiativeForcing)
+		if !ok {
+			continue
+		}
+		returnFlight, returnDistances, ok := amadeusApi.decodeItinerary(ctx, flightOffer.Itineraries[1], cabinClasses, false, flightOptions.RadiativeForcing)
+		if !ok {
+			continue
+		}
+
+		// the price is split among the segments of both itineraries, concatenated in a new array
+		// so that the outward and return options don't share storage
+		segments := slices.Concat(outwardFlight, returnFlight)
+		distances := slices.Concat(outwardDistances, returnDistances)
+		setIndicativePrice(segments, distances, flightOffer.Price)
+
+		outwardSegments := slices.Clone(segments[:len(outwardFlight)])
+		returnSegments := slices.Clone(segments[len(outwardFlight):])
+		roundTrips = append(roundTrips, internals.ComputeRoundTripOption(outwardSegments, returnSegments))
+	}
+
+	return roundTrips, nil
+}
+
+// requestFlightOffers calls the Amadeus flight offers search, renewing the access token if needed
+func (amadeusApi *AmadeusApi) requestFlightOffers(ctx context.Context, params url.Values) (FlightResponse, error) {
+	// amadeus flight offers search url
+	baseUrl := amadeusApi.baseUrl + "/v2/shopping/flight-offers"
+
+	apiUrl := fmt.Sprintf("%s?%s", baseUrl, params.Encode())
+
+	// create request, the deadline is set by the caller context
+	req, err := http.NewRequestWithContext(ctx, "GET", apiUrl, nil)
+	if err != nil {
+		return FlightResponse{}, err
+	}
+	req.Header.Set("Authorization", amadeusApi.getAuthorizationHeader())
+	client := &http.Client{}
 
 	start := time.Now()
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, err
+		return FlightResponse{}, err
 	}
 	defer func() {
 		err = resp.Body.Close()
@@ -199,22 +345,22 @@ func getRealFlights(departureCity, destinationCity model.City, date time.Time, i
 	// if access token out of date
 	if resp == nil || resp.StatusCode == http.StatusUnauthorized {
 		// get new access token
-		err = GetAccessToken()
+		err = amadeusApi.GetAccessToken(ctx)
 		if err != nil {
 			log.Println("Failed to get amadeus api access token: ", err)
-			return nil, err
+			return FlightResponse{}, err
 		}
 
 		// repeat request
 		var req2 *http.Request
-		req2, err = http.NewRequest("GET", apiUrl, nil)
+		req2, err = http.NewRequestWithContext(ctx, "GET", apiUrl, nil)
 		if err != nil {
-			return nil, err
+			return FlightResponse{}, err
 		}
-		req2.Header.Set("Authorization", "Bearer "+accessToken)
-		resp, err = client.Do(req)
+		req2.Header.Set("Authorization", amadeusApi.getAuthorizationHeader())
+		resp, err = client.Do(req2)
 		if err != nil {
-			return nil, err
+			return FlightResponse{}, err
 		}
 		defer func() {
 			err = resp.Body.Close()
@@ -223,177 +369,190 @@ func getRealFlights(departureCity, destinationCity model.City, date time.Time, i
 			}
 		}()
 		if resp == nil || resp.StatusCode == http.StatusUnauthorized {
-			return nil, errors.New("unauthorized")
+			return FlightResponse{}, errors.New("unauthorized")
 		}
 	}
 
 	elapsed := time.Since(start)
 	log.Println("CALL Amadeus API took", elapsed)
 
-	start = time.Now()
-
 	// check response
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return FlightResponse{}, err
 	}
 
 	// check response status code
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("errore HTTP: %d - %s", resp.StatusCode, string(body))
+		return FlightResponse{}, fmt.Errorf("errore HTTP: %d - %s", resp.StatusCode, string(body))
 	}
 
 	var response FlightResponse
 	err = json.Unmarshal(body, &response)
 	if err != nil {
 		log.Print("Error while decoding body: ", err)
-		return nil, err
+		return FlightResponse{}, err
 	}
 
 	if len(response.Data) == 0 {
 		log.Println("Missing data in the response")
-		return nil, fmt.Errorf("missing data in the response")
+		return FlightResponse{}, fmt.Errorf("missing data in the response")
 	}
 
-	var flights [][]model.Segment
+	return response, nil
+}
 
-	for _, flightOffer := range response.Data {
-		// check no missing data
-		if flightOffer.Itineraries == nil ||
-			len(flightOffer.Itineraries) == 0 ||
-			flightOffer.Itineraries[0].Segments == nil ||
-			len(flightOffer.Itineraries[0].Segments) == 0 {
-			// a different offer might have data
-			// don't return, just skip an iteration
-			continue
+// decodeItinerary converts the segments of an itinerary, cabinClasses contains the cabin of every segment id
+func (amadeusApi *AmadeusApi) decodeItinerary(ctx context.Context, itinerary Itinerary, cabinClasses map[string]string, isOutbound bool, radiativeForcing bool) ([]model.Segment, []float64, bool) {
+	if len(itinerary.Segments) == 0 {
+		return nil, nil, false
+	}
+
+	var flight []model.Segment
+	numSegment := 0
+	var distances []float64
+	emissionModel := internals.GetEmissionModel()
+	for _, flightSegment := range itinerary.Segments {
+		// check segment data
+		if flightSegment.Departure == nil ||
+			flightSegment.Arrival == nil {
+			// the current flight must be discarded
+			return nil, nil, false
 		}
-		itinerary := flightOffer.Itineraries[0]
-
-		var flight []model.Segment
-		// if I break out of the loop, I must not add to flights
-		addToFlights := true
-		numSegment := 0
-		var distances []float64
-		for _, flightSegment := range itinerary.Segments {
-			// check segment data
-			if flightSegment.Departure == nil ||
-				flightSegment.Arrival == nil {
-				// the current flight must be discarded
-				addToFlights = false
-				break
-			}
-
-			// increment segment number
-			numSegment++
-			// get departure time
-			parsedTime, err1 := time.Parse("2006-01-02T15:04:05", flightSegment.Departure.At)
-			if err1 != nil {
-				// the current flight must be discarded
-				addToFlights = false
-				break
-			}
-			// get duration
-			duration, err1 := parseISODuration(flightSegment.Duration)
-			if err1 != nil {
-				// the current flight must be discarded
-				addToFlights = false
-				break
-			}
-			// get cities
-			segmentDepCity, segmentDepAirport, err1 := GetCityAndAirportFromAirportIATA(flightSegment.Departure.IataCode)
-			if err1 != nil {
-				// the current flight must be discarded
-				addToFlights = false
-				break
-			}
-			segmentDestCity, segmentDestAirport, err1 := GetCityAndAirportFromAirportIATA(flightSegment.Arrival.IataCode)
-			if err1 != nil {
-				// the current flight must be discarded
-				addToFlights = false
-				break
-			}
-			// compute Haversine distance
-			distance := internals.ComputeHaversineDistance(segmentDepAirport.Latitude, segmentDepAirport.Longitude, segmentDestAirport.Latitude, segmentDestAirport.Longitude)
-			distances = append(distances, distance)
 
-			departureCountry := ""
-			if segmentDepCity.CountryName != nil {
-				departureCountry = *segmentDepCity.CountryName
-			}
-			destinationCountry := ""
-			if segmentDestCity.CountryName != nil {
-				destinationCountry = *segmentDestCity.CountryName
-			}
-			segment := model.Segment{
-				// segment id is autogenerated
-				DepartureId:        segmentDepCity.CityID,
-				DestinationId:      segmentDestCity.CityID,
-				DepartureCity:      segmentDepCity.CityName,
-				DepartureCountry:   departureCountry,
-				DestinationCity:    segmentDestCity.CityName,
-				DestinationCountry: destinationCountry,
-				DateTime:           parsedTime,
-				Duration:           duration,
-				Vehicle:            "plane",
-				Description:        flightSegment.CarrierCode + " " + flightSegment.Number,
-				// indicative price set after
-				CO2Emitted: internals.ComputeAircraftEmission(int(duration.Hours()), int(duration.Minutes())),
-				Distance:   distance,
-				NumSegment: numSegment,
-				IsOutward:  isOutbound,
-				// travel id can't be set here
-			}
-			flight = append(flight, segment)
+		// increment segment number
+		numSegment++
+		// get departure time
+		parsedTime, err := time.Parse("2006-01-02T15:04:05", flightSegment.Departure.At)
+		if err != nil {
+			// the current flight must be discarded
+			return nil, nil, false
 		}
-
-		if addToFlights {
-			// set indicative price to segments
-			totalPrice, err1 := strconv.ParseFloat(flightOffer.Price.GrandTotal, 64)
-			if err1 != nil {
-				totalPrice = 0
-			}
-			totalDistance := 0.0
-			for _, d := range distances {
-				totalDistance += d
-			}
-			for i := range flight {
-				flight[i].Price = totalPrice * (distances[i] / totalDistance)
-			}
-
-			flights = append(flights, flight)
+		// get duration
+		duration, err := parseISODuration(flightSegment.Duration)
+		if err != nil {
+			// the current flight must be discarded
+			return nil, nil, false
+		}
+		// get cities
+		segmentDepCity, segmentDepAirport, err := amadeusApi.GetCityAndAirportFromAirportIATA(ctx, flightSegment.Departure.IataCode)
+		if err != nil {
+			// the current flight must be discarded
+			return nil, nil, false
+		}
+		segmentDestCity, segmentDestAirport, err := amadeusApi.GetCityAndAirportFromAirportIATA(ctx, flightSegment.Arrival.IataCode)
+		if err != nil {
+			// the current flight must be discarded
+			return nil, nil, false
+		}
+		// compute Haversine distance
+		distance := internals.ComputeHaversineDistance(segmentDepAirport.Latitude, segmentDepAirport.Longitude, segmentDestAirport.Latitude, segmentDestAirport.Longitude)
+		distances = append(distances, distance)
+		// compute co2 from distance and cabin class
+		emissionBreakdown := emissionModel.FlightEmission(distance, cabinClasses[flightSegment.ID], radiativeForcing)
+
+		departureCountry := ""
+		if segmentDepCity.CountryName != nil {
+			departureCountry = *segmentDepCity.CountryName
+		}
+		destinationCountry := ""
+		if segmentDestCity.CountryName != nil {
+			destinationCountry = *segmentDestCity.CountryName
+		}
+		segment := model.Segment{
+			// segment id is autogenerated
+			DepartureId:        segmentDepCity.CityID,
+			DestinationId:      segmentDestCity.CityID,
+			DepartureCity:      segmentDepCity.CityName,
+			DepartureCountry:   departureCountry,
+			DestinationCity:    segmentDestCity.CityName,
+			DestinationCountry: destinationCountry,
+			DateTime:           parsedTime,
+			Duration:           duration,
+			Vehicle:            "plane",
+			Description:        flightSegment.CarrierCode + " " + flightSegment.Number,
+			// indicative price set after
+			CO2Emitted:          emissionBreakdown.CO2Emitted,
+			EmissionMethodology: emissionModel.Version(),
+			EmissionBreakdown:   &emissionBreakdown,
+			Distance:            distance,
+			NumSegment:          numSegment,
+			IsOutward:           isOutbound,
+			// travel id can't be set here
 		}
+		flight = append(flight, segment)
 	}
 
-	elapsed = time.Since(start)
+	return flight, distances, true
+}
 
-	return flights, nil
+// getCabinClasses returns the cabin class of every segment id of an offer, the cabins of the first traveler are used
+func getCabinClasses(flightOffer FlightOffer) map[string]string {
+	cabinClasses := map[string]string{}
+	if len(flightOffer.TravelerPricings) == 0 {
+		return cabinClasses
+	}
+	for _, fareDetails := range flightOffer.TravelerPricings[0].FareDetailsBySegment {
+		cabinClasses[fareDetails.SegmentID] = strings.ToLower(fareDetails.Cabin)
+	}
+	return cabinClasses
+}
+
+// setIndicativePrice splits the price of an offer among its segments, proportionally to their distance,
+// segments keep the currency of the offer
+func setIndicativePrice(segments []model.Segment, distances []float64, price *FlightPrice) {
+	totalPrice := 0.0
+	currency := DefaultCurrency
+	if price != nil {
+		parsedPrice, err := strconv.ParseFloat(price.GrandTotal, 64)
+		if err == nil {
+			totalPrice = parsedPrice
+		}
+		if price.Currency != "" {
+			currency = price.Currency
+		}
+	}
+	totalDistance := 0.0
+	for _, d := range distances {
+		totalDistance += d
+	}
+	for i := range segments {
+		segments[i].Currency = currency
+		if totalDistance == 0 {
+			segments[i].Price = totalPrice / float64(len(segments))
+		} else {
+			segments[i].Price = totalPrice * (distances[i] / totalDistance)
+		}
+	}
 }
 
-func getMockFlights(departureCity, destinationCity model.City, date time.Time, isOutbound bool) [][]model.Segment {
+func (amadeusApi *AmadeusApi) getMockFlights(departureCity, destinationCity model.City, date time.Time, isOutbound bool) [][]model.Segment {
 	var flights [][]model.Segment
 
 	// if mock options required, produce them
-	if mockOptions {
+	if amadeusApi.mockOptions {
 		var flight []model.Segment
 
 		// option formed by a single segment
 		segment := model.Segment{
 			// segment id is autogenerated
-			DepartureId:        departureCity.CityID,
-			DestinationId:      destinationCity.CityID,
-			DepartureCity:      departureCity.CityName,
-			DepartureCountry:   *departureCity.CountryName,
-			DestinationCity:    destinationCity.CityName,
-			DestinationCountry: *destinationCity.CountryName,
-			DateTime:           date,
-			Duration:           time.Duration(randFloats(3600000000000, 2*3600000000000)),
-			Vehicle:            "plane",
-			Description:        "Mock travel option",
-			Price:              randFloats(50, 150),
-			CO2Emitted:         randFloats(100, 400),
-			Distance:           randFloats(1000, 5000),
-			NumSegment:         1,
-			IsOutward:          isOutbound,
+			DepartureId:         departureCity.CityID,
+			DestinationId:       destinationCity.CityID,
+			DepartureCity:       departureCity.CityName,
+			DepartureCountry:    *departureCity.CountryName,
+			DestinationCity:     destinationCity.CityName,
+			DestinationCountry:  *destinationCity.CountryName,
+			DateTime:            date,
+			Duration:            time.Duration(randFloats(3600000000000, 2*3600000000000)),
+			Vehicle:             "plane",
+			Description:         "Mock travel option",
+			Price:               randFloats(50, 150),
+			Currency:            DefaultCurrency,
+			CO2Emitted:          randFloats(100, 400),
+			EmissionMethodology: "mock",
+			Distance:            randFloats(1000, 5000),
+			NumSegment:          1,
+			IsOutward:           isOutbound,
 			// travel id can't be set here
 		}
 		flight = append(flight, segment)
@@ -431,31 +590,29 @@ func parseISODuration(duration string) (time.Duration, error) {
 	return totalDuration, nil
 }
 
-func GetCityAndAirportFromAirportIATA(iata string) (model.City, model.Airport, error) {
+func (amadeusApi *AmadeusApi) GetCityAndAirportFromAirportIATA(ctx context.Context, iata string) (model.City, model.Airport, error) {
 	// this method returns the city based on the airport_iata
 
-	cityDAO := db.NewCityDAO(db.GetDB())
-
 	// check existing airport_iata
-	airport, err1 := cityDAO.GetAirportByAirportIata(iata)
-	city, err2 := cityDAO.GetCityByAirportIata(iata)
+	airport, err1 := amadeusApi.cities.GetAirportByAirportIata(iata)
+	city, err2 := amadeusApi.cities.GetCityByAirportIata(iata)
 	if err1 == nil && err2 == nil {
 		return city, airport, nil
-	} else if !errors.Is(err1, gorm.ErrRecordNotFound) {
+	} else if !errors.Is(err1, db.ErrRecordNotFound) {
 		return model.City{}, model.Airport{}, err1
-	} else if !errors.Is(err2, gorm.ErrRecordNotFound) {
+	} else if !errors.Is(err2, db.ErrRecordNotFound) {
 		return model.City{}, model.Airport{}, err2
 	}
 
 	// else, make an api call
-	err := MakeAirportCityCall(iata)
+	err := amadeusApi.MakeAirportCityCall(ctx, iata)
 	if err != nil {
 		return model.City{}, model.Airport{}, err
 	}
 
 	// try getting by airport_iata
-	airport, err1 = cityDAO.GetAirportByAirportIata(iata)
-	city, err2 = cityDAO.GetCityByAirportIata(iata)
+	airport, err1 = amadeusApi.cities.GetAirportByAirportIata(iata)
+	city, err2 = amadeusApi.cities.GetCityByAirportIata(iata)
 	if err1 == nil && err2 == nil {
 		return city, airport, nil
 	} else {
@@ -464,15 +621,15 @@ func GetCityAndAirportFromAirportIATA(iata string) (model.City, model.Airport, e
 	}
 }
 
-func MakeAirportCityCall(keyword string) error {
-	apiUrl := "https://test.api.amadeus.com/v1/reference-data/locations?subType=CITY,AIRPORT&keyword=" + keyword
+func (amadeusApi *AmadeusApi) MakeAirportCityCall(ctx context.Context, keyword string) error {
+	apiUrl := amadeusApi.baseUrl + "/v1/reference-data/locations?subType=CITY,AIRPORT&keyword=" + keyword
 
-	req, err := http.NewRequest("GET", apiUrl, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", apiUrl, nil)
 	if err != nil {
 		log.Println("Error while creating the request: ", err)
 		return err
 	}
-	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Authorization", amadeusApi.getAuthorizationHeader())
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
@@ -489,7 +646,7 @@ func MakeAirportCityCall(keyword string) error {
 	// if access token out of date
 	if resp == nil || resp.StatusCode == http.StatusUnauthorized {
 		// get new access token
-		err = GetAccessToken()
+		err = amadeusApi.GetAccessToken(ctx)
 		if err != nil {
 			log.Println("Failed to get amadeus api access token: ", err)
 			return err
@@ -497,12 +654,12 @@ func MakeAirportCityCall(keyword string) error {
 
 		// repeat request
 		var req2 *http.Request
-		req2, err = http.NewRequest("GET", apiUrl, nil)
+		req2, err = http.NewRequestWithContext(ctx, "GET", apiUrl, nil)
 		if err != nil {
 			log.Println("Error creating the request:", err)
 			return err
 		}
-		req2.Header.Set("Authorization", "Bearer "+accessToken)
+		req2.Header.Set("Authorization", amadeusApi.getAuthorizationHeader())
 		resp, err = client.Do(req2)
 		if err != nil {
 			log.Println("Error while creating the request: ", err)
@@ -537,8 +694,6 @@ func MakeAirportCityCall(keyword string) error {
 		return fmt.Errorf("no data in the response")
 	}
 
-	cityDAO := db.NewCityDAO(db.GetDB())
-
 	// add airports
 	for _, element := range locationResponse.Data {
 		if element.Address == nil ||
@@ -554,7 +709,7 @@ func MakeAirportCityCall(keyword string) error {
 
 		if element.SubType == "AIRPORT" {
 			// check airport not present
-			airport, err1 := cityDAO.GetAirportByAirportIata(*element.IataCode)
+			airport, err1 := amadeusApi.cities.GetAirportByAirportIata(*element.IataCode)
 			if err1 == nil {
 				// airport already present
 				continue
@@ -563,7 +718,7 @@ func MakeAirportCityCall(keyword string) error {
 			// check corresponding city exists in db with same city_iata and country_code
 			cityIata := *element.Address.CityCode
 			countryCode := *element.Address.CountryCode
-			dbCity, err1 := cityDAO.GetCityByIataAndCountryCode(cityIata, countryCode)
+			dbCity, err1 := amadeusApi.cities.GetCityByIataAndCountryCode(cityIata, countryCode)
 			if err1 != nil {
 				// skip only one airport
 				continue
@@ -580,7 +735,7 @@ func MakeAirportCityCall(keyword string) error {
 			}
 
 			// add airport to db
-			err1 = cityDAO.CreateAirport(&airport)
+			err1 = amadeusApi.cities.CreateAirport(&airport)
 			if err1 != nil {
 				continue
 			}
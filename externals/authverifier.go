@@ -0,0 +1,187 @@
+package externals
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"github.com/MicahParks/keyfunc"
+	"github.com/golang-jwt/jwt/v4"
+	"green-journey-server/config"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuthVerifier verifies the bearer tokens of the requests
+type AuthVerifier interface {
+	// VerifyToken returns the uid of the user the token was issued to
+	VerifyToken(ctx context.Context, token string) (string, error)
+}
+
+var (
+	authVerifier      AuthVerifier
+	authVerifierMutex sync.RWMutex
+)
+
+// GetAuthVerifier returns the verifier used by the server, all tokens are rejected until one is set
+func GetAuthVerifier() AuthVerifier {
+	authVerifierMutex.RLock()
+	defer authVerifierMutex.RUnlock()
+
+	if authVerifier == nil {
+		return missingAuthVerifier{}
+	}
+	return authVerifier
+}
+
+// missingAuthVerifier rejects all tokens, it is used before the verifier is initialized
+type missingAuthVerifier struct{}
+
+func (missingAuthVerifier) VerifyToken(ctx context.Context, token string) (string, error) {
+	return "", errors.New("auth verifier not initialized")
+}
+
+// SetAuthVerifier replaces the verifier used by the server
+func SetAuthVerifier(verifier AuthVerifier) {
+	authVerifierMutex.Lock()
+	defer authVerifierMutex.Unlock()
+
+	authVerifier = verifier
+}
+
+// InitAuthVerifier chooses the verifier: in test mode tokens are minted by a local test issuer,
+// otherwise if a jwks file is configured tokens are verified with its keys, else with Firebase
+func InitAuthVerifier(testMode string, authConfig config.AuthConfig) error {
+	if testMode == "test" {
+		testIssuer, err := NewTestIssuer()
+		if err != nil {
+			return err
+		}
+		SetAuthVerifier(testIssuer)
+		return nil
+	}
+
+	if authConfig.JWKSFile != "" {
+		jwksVerifier, err := LoadJWKSVerifier(authConfig.JWKSFile, authConfig.Issuer, authConfig.Audience)
+		if err != nil {
+			return err
+		}
+		SetAuthVerifier(jwksVerifier)
+		return nil
+	}
+
+	firebaseVerifier, err := NewFirebaseVerifier(authConfig.FirebaseCredentialsFile)
+	if err != nil {
+		return err
+	}
+	SetAuthVerifier(firebaseVerifier)
+	return nil
+}
+
+// JWKSVerifier verifies signed JWTs offline, with the public keys of a JWKS:
+// the uid is the subject of the token
+type JWKSVerifier struct {
+	jwks *keyfunc.JWKS
+	// expected issuer and audience, not checked if empty
+	issuer   string
+	audience string
+}
+
+func NewJWKSVerifier(jwks *keyfunc.JWKS, issuer, audience string) *JWKSVerifier {
+	return &JWKSVerifier{
+		jwks:     jwks,
+		issuer:   issuer,
+		audience: audience,
+	}
+}
+
+// LoadJWKSVerifier reads the keys from a JWKS file
+func LoadJWKSVerifier(jwksFile, issuer, audience string) (*JWKSVerifier, error) {
+	jwksBytes, err := os.ReadFile(jwksFile)
+	if err != nil {
+		return nil, err
+	}
+	jwks, err := keyfunc.NewJSON(jwksBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwks file %s: %w", jwksFile, err)
+	}
+	return NewJWKSVerifier(jwks, issuer, audience), nil
+}
+
+func (verifier *JWKSVerifier) VerifyToken(ctx context.Context, token string) (string, error) {
+	// signature, expiration and not before are checked by the parser
+	var claims jwt.RegisteredClaims
+	_, err := jwt.ParseWithClaims(token, &claims, verifier.jwks.Keyfunc)
+	if err != nil {
+		return "", err
+	}
+
+	if verifier.issuer != "" && !claims.VerifyIssuer(verifier.issuer, true) {
+		return "", errors.New("invalid token issuer")
+	}
+	if verifier.audience != "" && !claims.VerifyAudience(verifier.audience, true) {
+		return "", errors.New("invalid token audience")
+	}
+	if claims.ExpiresAt == nil {
+		return "", errors.New("token without expiration")
+	}
+	if claims.Subject == "" {
+		return "", errors.New("token without subject")
+	}
+
+	return claims.Subject, nil
+}
+
+// issuer, audience and key id of the tokens minted by the test issuer
+const (
+	testIssuerName   = "green-journey-test-issuer"
+	testIssuerKeyID  = "test"
+	testTokenTTL     = 24 * time.Hour
+	testIssuerKeyLen = 2048
+)
+
+// TestIssuer mints tokens for arbitrary uids and verifies them, so that tests can act as different users:
+// the key is generated at startup, tokens don't survive a restart of the server
+type TestIssuer struct {
+	*JWKSVerifier
+	privateKey *rsa.PrivateKey
+}
+
+func NewTestIssuer() (*TestIssuer, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, testIssuerKeyLen)
+	if err != nil {
+		return nil, err
+	}
+
+	jwks := keyfunc.NewGiven(map[string]keyfunc.GivenKey{
+		testIssuerKeyID: keyfunc.NewGivenRSACustomWithOptions(&privateKey.PublicKey, keyfunc.GivenKeyOptions{
+			Algorithm: jwt.SigningMethodRS256.Alg(),
+		}),
+	})
+
+	return &TestIssuer{
+		JWKSVerifier: NewJWKSVerifier(jwks, testIssuerName, testIssuerName),
+		privateKey:   privateKey,
+	}, nil
+}
+
+// MintToken returns a signed token for uid
+func (issuer *TestIssuer) MintToken(uid string) (string, error) {
+	if uid == "" {
+		return "", errors.New("missing uid")
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.RegisteredClaims{
+		Issuer:    testIssuerName,
+		Audience:  jwt.ClaimStrings{testIssuerName},
+		Subject:   uid,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(testTokenTTL)),
+	})
+	token.Header["kid"] = testIssuerKeyID
+
+	return token.SignedString(issuer.privateKey)
+}
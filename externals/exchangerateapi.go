@@ -0,0 +1,85 @@
+package externals
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"green-journey-server/internals"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// currency of the prices computed by the server, e.g. fuel, toll and transit costs
+const DefaultCurrency = internals.DefaultCurrency
+
+// exchange rates are read from the api at most once in this interval
+const exchangeRatesTTL = time.Hour
+
+type ExchangeRateResponse struct {
+	Base  string             `json:"base"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+// GetExchangeRates returns the value of 1 EUR in every supported currency
+func (priceApis *PriceApis) GetExchangeRates(ctx context.Context) (map[string]float64, error) {
+	priceApis.exchangeRatesMutex.Lock()
+	defer priceApis.exchangeRatesMutex.Unlock()
+
+	if priceApis.exchangeRates != nil && time.Now().Before(priceApis.exchangeRatesExpiration) {
+		return priceApis.exchangeRates, nil
+	}
+
+	// call api
+	apiUrl := priceApis.exchangeRateApiUrl + "?base=" + DefaultCurrency
+	req, err := http.NewRequestWithContext(ctx, "GET", apiUrl, nil)
+	if err != nil {
+		log.Println("Error while creating the request")
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Println("Error while getting exchange rates from api")
+		return nil, err
+	}
+	defer func() {
+		err = resp.Body.Close()
+		if err != nil {
+			log.Println("Error closing response body:", err)
+		}
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Println("Error while reading response body: ", err)
+		return nil, err
+	}
+
+	// check response status code
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	var response ExchangeRateResponse
+	jsonReader := bytes.NewReader(body)
+	decoder := json.NewDecoder(jsonReader)
+	err = decoder.Decode(&response)
+	if err != nil {
+		log.Println("Error while decoding: ", err)
+		return nil, err
+	}
+	if response.Base != DefaultCurrency {
+		return nil, fmt.Errorf("unexpected exchange rates base %s", response.Base)
+	}
+	if response.Rates == nil {
+		return nil, fmt.Errorf("no exchange rates in the response")
+	}
+	response.Rates[DefaultCurrency] = 1
+
+	priceApis.exchangeRates = response.Rates
+	priceApis.exchangeRatesExpiration = time.Now().Add(exchangeRatesTTL)
+
+	return priceApis.exchangeRates, nil
+}
@@ -4,48 +4,33 @@ import (
 	"context"
 	"firebase.google.com/go/v4"
 	"google.golang.org/api/option"
-	"log"
-	"sync"
 )
 
-var (
-	firebaseApp *firebase.App
-	once        sync.Once
-)
-var testMode string
-
-func InitializeFirebase(testModeArg string) *firebase.App {
-	once.Do(func() {
-		opt := option.WithCredentialsFile("firebaseServiceAccountKey.json")
-		app, err := firebase.NewApp(context.Background(), nil, opt)
-		if err != nil {
-			log.Fatalf("Error initializing Firebase Admin SDK: %v", err)
-		}
-		firebaseApp = app
-	})
-
-	testMode = testModeArg
-
-	return firebaseApp
+// FirebaseVerifier verifies the id tokens issued by Firebase Authentication
+type FirebaseVerifier struct {
+	app *firebase.App
 }
 
-func VerifyFirebaseToken(ctx context.Context, idToken string) (string, error) {
-	if testMode == "real" {
-		app := InitializeFirebase(testMode)
-
-		authClient, err := app.Auth(ctx)
-		if err != nil {
-			return "", err
-		}
+// NewFirebaseVerifier initializes the Firebase Admin SDK with a service account credentials file
+func NewFirebaseVerifier(credentialsFile string) (*FirebaseVerifier, error) {
+	opt := option.WithCredentialsFile(credentialsFile)
+	app, err := firebase.NewApp(context.Background(), nil, opt)
+	if err != nil {
+		return nil, err
+	}
+	return &FirebaseVerifier{app: app}, nil
+}
 
-		token, err := authClient.VerifyIDToken(ctx, idToken)
-		if err != nil {
-			return "", err
-		}
+func (verifier *FirebaseVerifier) VerifyToken(ctx context.Context, idToken string) (string, error) {
+	authClient, err := verifier.app.Auth(ctx)
+	if err != nil {
+		return "", err
+	}
 
-		return token.UID, nil
-	} else {
-		// if test mode, return a fake value
-		return "firebase_uid", nil
+	token, err := authClient.VerifyIDToken(ctx, idToken)
+	if err != nil {
+		return "", err
 	}
+
+	return token.UID, nil
 }
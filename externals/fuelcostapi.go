@@ -2,6 +2,7 @@ package externals
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"log"
@@ -13,12 +14,18 @@ type FuelCostResponse struct {
 	FuelCost float64 `json:"fuel-cost"`
 }
 
-func GetFuelCostPerLiter(from string) float64 {
+// GetFuelCostPerLiter returns the cost of a liter of fuel of the given type, or of a kWh for electric vehicles
+func (priceApis *PriceApis) GetFuelCostPerLiter(ctx context.Context, from, fuelType string) float64 {
 	fuelCostPerLiter := 0.0
 
 	// call api
-	apiUrl := "http://localhost:8083/fuelcostapi?location=" + url.QueryEscape(from)
-	resp, err := http.Get(apiUrl)
+	apiUrl := priceApis.fuelCostApiUrl + "?location=" + url.QueryEscape(from) + "&fuel_type=" + url.QueryEscape(fuelType)
+	req, err := http.NewRequestWithContext(ctx, "GET", apiUrl, nil)
+	if err != nil {
+		log.Println("Error while creating the request")
+		return fuelCostPerLiter
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		log.Println("Error while creating the request")
 		return fuelCostPerLiter
@@ -2,11 +2,11 @@ package externals
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/joho/godotenv"
-	"gorm.io/gorm"
+	"green-journey-server/config"
 	"green-journey-server/db"
 	"green-journey-server/internals"
 	"green-journey-server/model"
@@ -14,13 +14,11 @@ import (
 	"log"
 	"net/http"
 	"net/url"
-	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
-var googleApiKey string
-
 // car directions
 
 type DistanceMatrixResponse struct {
@@ -58,6 +56,10 @@ type Step struct {
 	Duration       *Duration       `json:"duration"`
 	TravelMode     string          `json:"travel_mode"`
 	TransitDetails *TransitDetails `json:"transit_details"`
+	Polyline       *Polyline       `json:"polyline"`
+}
+type Polyline struct {
+	Points string `json:"points"`
 }
 type TransitDetails struct {
 	ArrivalStop   *Stop        `json:"arrival_stop"`
@@ -92,6 +94,10 @@ type GeocodeResponse struct {
 }
 type Result struct {
 	AddressComponents []AddressComponent `json:"address_components"`
+	Geometry          *Geometry          `json:"geometry"`
+}
+type Geometry struct {
+	Location *GoogleMapsLocation `json:"location"`
 }
 type AddressComponent struct {
 	LongName  string   `json:"long_name"`
@@ -99,17 +105,36 @@ type AddressComponent struct {
 	Types     []string `json:"types"`
 }
 
-func InitGoogleMapsApi() {
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatal("Error loading .env file")
+// time zone response
+
+type TimeZoneResponse struct {
+	Status     string `json:"status"`
+	TimeZoneID string `json:"timeZoneId"`
+	RawOffset  int    `json:"rawOffset"`
+	DstOffset  int    `json:"dstOffset"`
+}
+
+// GoogleMapsApi calls the Google Maps apis, the prices of transit and car travels are read from priceApis
+// and the stops of transit travels are read and stored with cities
+type GoogleMapsApi struct {
+	baseUrl   string
+	apiKey    string
+	cities    db.CityRepository
+	priceApis *PriceApis
+}
+
+func NewGoogleMapsApi(googleMapsConfig config.GoogleMapsConfig, cities db.CityRepository, priceApis *PriceApis) *GoogleMapsApi {
+	return &GoogleMapsApi{
+		baseUrl:   strings.TrimSuffix(googleMapsConfig.BaseURL, "/"),
+		apiKey:    googleMapsConfig.APIKey,
+		cities:    cities,
+		priceApis: priceApis,
 	}
-	googleApiKey = os.Getenv("GOOGLE_MAPS_API_KEY")
 }
 
-func GetDirectionsBike(originCity, destinationCity model.City, date time.Time, hour time.Time, isOutbound bool) ([]model.Segment, error) {
+func (googleMapsApi *GoogleMapsApi) GetDirectionsBike(ctx context.Context, originCity, destinationCity model.City, date time.Time, hour time.Time, isOutbound bool) ([]model.Segment, error) {
 	// distance matrix api url
-	baseURL := "https://maps.googleapis.com/maps/api/distancematrix/json"
+	baseURL := googleMapsApi.baseUrl + "/maps/api/distancematrix/json"
 
 	// compute departure-time value
 	dateHour := time.Date(date.Year(), date.Month(), date.Day(), hour.Hour(), hour.Minute(), 0, 0, hour.Location())
@@ -121,13 +146,18 @@ func GetDirectionsBike(originCity, destinationCity model.City, date time.Time, h
 	params.Add("destinations", destinationCity.CityName)
 	params.Add("departure-time", departureTime)
 	params.Add("mode", "bicycling")
-	params.Add("key", googleApiKey)
+	params.Add("key", googleMapsApi.apiKey)
 
 	fullURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
 
 	start := time.Now()
 
-	resp, err := http.Get(fullURL)
+	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
+	if err != nil {
+		log.Println("error creating the request: ", err)
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		log.Println("error creating the request: ", err)
 		return nil, err
@@ -187,30 +217,34 @@ func GetDirectionsBike(originCity, destinationCity model.City, date time.Time, h
 
 	segment := model.Segment{
 		// id auto increment
-		DepartureId:        originCity.CityID,
-		DestinationId:      destinationCity.CityID,
-		DepartureCity:      originCity.CityName,
-		DepartureCountry:   departureCountry,
-		DestinationCity:    destinationCity.CityName,
-		DestinationCountry: destinationCountry,
-		DateTime:           unifiedTime,
-		Duration:           time.Duration(response.Rows[0].Elements[0].Duration.Value * int(time.Second)),
-		Vehicle:            "bike",
-		Description:        "",
-		Price:              0,
-		CO2Emitted:         0,
-		Distance:           float64(distance),
-		NumSegment:         1,
-		IsOutward:          isOutbound,
-		TravelID:           -1,
+		DepartureId:         originCity.CityID,
+		DestinationId:       destinationCity.CityID,
+		DepartureCity:       originCity.CityName,
+		DepartureCountry:    departureCountry,
+		DestinationCity:     destinationCity.CityName,
+		DestinationCountry:  destinationCountry,
+		DateTime:            unifiedTime,
+		Duration:            time.Duration(response.Rows[0].Elements[0].Duration.Value * int(time.Second)),
+		Vehicle:             "bike",
+		Description:         "",
+		Price:               0,
+		Currency:            DefaultCurrency,
+		CO2Emitted:          0,
+		EmissionMethodology: internals.GetEmissionModel().Version(),
+		Distance:            float64(distance),
+		NumSegment:          1,
+		IsOutward:           isOutbound,
+		TravelID:            -1,
 	}
 
 	return []model.Segment{segment}, nil
 }
 
-func GetDirectionsCar(originCity, destinationCity model.City, date time.Time, hour time.Time, isOutbound bool) ([]model.Segment, error) {
+// GetDirectionsCar returns the car segment, price and co2 are per person: if vehicleProfile is nil,
+// an average petrol car is assumed
+func (googleMapsApi *GoogleMapsApi) GetDirectionsCar(ctx context.Context, originCity, destinationCity model.City, date time.Time, hour time.Time, isOutbound bool, vehicleProfile *model.VehicleProfile, passengers int) ([]model.Segment, error) {
 	// distance matrix api url
-	baseURL := "https://maps.googleapis.com/maps/api/distancematrix/json"
+	baseURL := googleMapsApi.baseUrl + "/maps/api/distancematrix/json"
 
 	// compute departure-time value
 	dateHour := time.Date(date.Year(), date.Month(), date.Day(), hour.Hour(), hour.Minute(), 0, 0, hour.Location())
@@ -221,13 +255,18 @@ func GetDirectionsCar(originCity, destinationCity model.City, date time.Time, ho
 	params.Add("origins", originCity.CityName)
 	params.Add("destinations", destinationCity.CityName)
 	params.Add("departure-time", departureTime)
-	params.Add("key", googleApiKey)
+	params.Add("key", googleMapsApi.apiKey)
 
 	fullURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
 
 	start := time.Now()
 
-	resp, err := http.Get(fullURL)
+	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
+	if err != nil {
+		log.Println("error creating the request: ", err)
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		log.Println("error creating the request: ", err)
 		return nil, err
@@ -274,8 +313,23 @@ func GetDirectionsCar(originCity, destinationCity model.City, date time.Time, ho
 	}
 
 	distance := response.Rows[0].Elements[0].Distance.Value / 1000
-	fuelCostPerLiter := GetFuelCostPerLiter(originCity.CityName)
-	tollCost := GetTollCost(originCity.CityName, destinationCity.CityName, distance)
+	tollCost := googleMapsApi.priceApis.GetTollCost(ctx, originCity.CityName, destinationCity.CityName, distance)
+
+	// price and co2 of the whole car, divided among the passengers
+	if passengers < 1 {
+		passengers = 1
+	}
+	var price, co2Emitted float64
+	emissionModel := internals.GetEmissionModel()
+	if vehicleProfile != nil {
+		fuelCost := googleMapsApi.priceApis.GetFuelCostPerLiter(ctx, originCity.CityName, vehicleProfile.FuelType)
+		price = internals.ComputeCarPriceWithProfile(fuelCost, float64(distance), tollCost, *vehicleProfile, passengers)
+		co2Emitted = emissionModel.CarEmissionWithProfile(float64(distance), *vehicleProfile, passengers)
+	} else {
+		fuelCostPerLiter := googleMapsApi.priceApis.GetFuelCostPerLiter(ctx, originCity.CityName, model.FuelTypePetrol)
+		price = internals.ComputeCarPrice(fuelCostPerLiter, float64(distance), tollCost) / float64(passengers)
+		co2Emitted = emissionModel.CarEmission(float64(distance)) / float64(passengers)
+	}
 
 	departureCountry := ""
 	if originCity.CountryName != nil {
@@ -290,30 +344,32 @@ func GetDirectionsCar(originCity, destinationCity model.City, date time.Time, ho
 
 	segment := model.Segment{
 		// id auto increment
-		DepartureId:        originCity.CityID,
-		DestinationId:      destinationCity.CityID,
-		DepartureCity:      originCity.CityName,
-		DepartureCountry:   departureCountry,
-		DestinationCity:    destinationCity.CityName,
-		DestinationCountry: destinationCountry,
-		DateTime:           unifiedTime,
-		Duration:           time.Duration(response.Rows[0].Elements[0].Duration.Value * int(time.Second)),
-		Vehicle:            "car",
-		Description:        "",
-		Price:              internals.ComputeCarPrice(fuelCostPerLiter, float64(distance), tollCost),
-		CO2Emitted:         internals.ComputeCarEmission(distance),
-		Distance:           float64(response.Rows[0].Elements[0].Distance.Value) / 1000,
-		NumSegment:         1,
-		IsOutward:          isOutbound,
-		TravelID:           -1,
+		DepartureId:         originCity.CityID,
+		DestinationId:       destinationCity.CityID,
+		DepartureCity:       originCity.CityName,
+		DepartureCountry:    departureCountry,
+		DestinationCity:     destinationCity.CityName,
+		DestinationCountry:  destinationCountry,
+		DateTime:            unifiedTime,
+		Duration:            time.Duration(response.Rows[0].Elements[0].Duration.Value * int(time.Second)),
+		Vehicle:             "car",
+		Description:         "",
+		Price:               price,
+		Currency:            DefaultCurrency,
+		CO2Emitted:          co2Emitted,
+		EmissionMethodology: emissionModel.Version(),
+		Distance:            float64(response.Rows[0].Elements[0].Distance.Value) / 1000,
+		NumSegment:          1,
+		IsOutward:           isOutbound,
+		TravelID:            -1,
 	}
 
 	return []model.Segment{segment}, nil
 }
 
-func GetDirectionsTrain(originCity, destinationCity model.City, date, hour time.Time, isOutbound bool) ([]model.Segment, error) {
+func (googleMapsApi *GoogleMapsApi) GetDirectionsTrain(ctx context.Context, originCity, destinationCity model.City, date, hour time.Time, isOutbound bool) ([]model.Segment, error) {
 	// google directions api
-	baseURL := "https://maps.googleapis.com/maps/api/directions/json"
+	baseURL := googleMapsApi.baseUrl + "/maps/api/directions/json"
 
 	// compute departure-time value
 	dateHour := time.Date(date.Year(), date.Month(), date.Day(), hour.Hour(), hour.Minute(), 0, 0, hour.Location())
@@ -326,7 +382,7 @@ func GetDirectionsTrain(originCity, destinationCity model.City, date, hour time.
 	params.Add("transit_mode", "rail")
 	params.Add("departure_time", strconv.FormatInt(timestamp, 10))
 	params.Add("transit_routing_preference", "fewer_transfers")
-	params.Add("key", googleApiKey)
+	params.Add("key", googleMapsApi.apiKey)
 
 	params.Add("alternatives", "false")
 
@@ -334,7 +390,12 @@ func GetDirectionsTrain(originCity, destinationCity model.City, date, hour time.
 
 	start := time.Now()
 
-	resp, err := http.Get(fullURL)
+	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
+	if err != nil {
+		log.Println("error creating the request: ", err)
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		log.Println("error creating the request: ", err)
 		return nil, err
@@ -360,7 +421,7 @@ func GetDirectionsTrain(originCity, destinationCity model.City, date, hour time.
 		return nil, fmt.Errorf("errore HTTP: %d - %s", resp.StatusCode, string(body))
 	}
 
-	segments, err := decodeDirectionsTransit(body, originCity, destinationCity, "train", isOutbound)
+	segments, err := googleMapsApi.decodeDirectionsTransit(ctx, body, originCity, destinationCity, "train", isOutbound)
 	if err != nil {
 		log.Println("error decoding JSON: ", err)
 		return nil, err
@@ -369,9 +430,9 @@ func GetDirectionsTrain(originCity, destinationCity model.City, date, hour time.
 	return segments, nil
 }
 
-func GetDirectionsBus(originCity, destinationCity model.City, date, hour time.Time, isOutbound bool) ([]model.Segment, error) {
+func (googleMapsApi *GoogleMapsApi) GetDirectionsBus(ctx context.Context, originCity, destinationCity model.City, date, hour time.Time, isOutbound bool) ([]model.Segment, error) {
 	// google directions api
-	baseURL := "https://maps.googleapis.com/maps/api/directions/json"
+	baseURL := googleMapsApi.baseUrl + "/maps/api/directions/json"
 
 	// compute departure-time value
 	dateHour := time.Date(date.Year(), date.Month(), date.Day(), hour.Hour(), hour.Minute(), 0, 0, hour.Location())
@@ -384,13 +445,18 @@ func GetDirectionsBus(originCity, destinationCity model.City, date, hour time.Ti
 	params.Add("mode", "transit")
 	params.Add("transit_mode", "bus")
 	params.Add("departure_time", strconv.FormatInt(timestamp, 10))
-	params.Add("key", googleApiKey)
+	params.Add("key", googleMapsApi.apiKey)
 	fullURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
 
 	start := time.Now()
 
 	// request
-	resp, err := http.Get(fullURL)
+	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
+	if err != nil {
+		log.Println("error creating the request: ", err)
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		log.Println("error creating the request: ", err)
 		return nil, err
@@ -416,7 +482,7 @@ func GetDirectionsBus(originCity, destinationCity model.City, date, hour time.Ti
 		return nil, fmt.Errorf("errore HTTP: %d - %s", resp.StatusCode, string(body))
 	}
 
-	segments, err := decodeDirectionsTransit(body, originCity, destinationCity, "bus", isOutbound)
+	segments, err := googleMapsApi.decodeDirectionsTransit(ctx, body, originCity, destinationCity, "bus", isOutbound)
 	if err != nil {
 		log.Println("error decoding JSON: ", err)
 		return nil, err
@@ -425,7 +491,7 @@ func GetDirectionsBus(originCity, destinationCity model.City, date, hour time.Ti
 	return segments, nil
 }
 
-func decodeDirectionsTransit(body []byte, originCity, destinationCity model.City, transitMode string, isOutbound bool) ([]model.Segment, error) {
+func (googleMapsApi *GoogleMapsApi) decodeDirectionsTransit(ctx context.Context, body []byte, originCity, destinationCity model.City, transitMode string, isOutbound bool) ([]model.Segment, error) {
 	// vehicles returned by the api
 
 	busVehicles := []string{"BUS", "INTERCITY_BUS", "SHARE_TAXI", "TROLLEYBUS"}
@@ -450,6 +516,7 @@ func decodeDirectionsTransit(body []byte, originCity, destinationCity model.City
 	}
 
 	leg := response.Routes[0].Legs[0]
+	emissionModel := internals.GetEmissionModel()
 
 	var segments []model.Segment
 	numSegment := 0
@@ -470,21 +537,23 @@ func decodeDirectionsTransit(body []byte, originCity, destinationCity model.City
 
 			segment = model.Segment{
 				// id is autoincrement
-				DepartureId:        -1, // updated at the end
-				DestinationId:      -1, // updated at the end
-				DepartureCity:      "", // updated at the end
-				DestinationCity:    "", // updated at the end
-				DepartureCountry:   "", // updated at the end
-				DestinationCountry: "", // updated at the end
-				DateTime:           time.Unix(0, 0),
-				Duration:           time.Duration(duration) * time.Second,
-				Vehicle:            "walk",
-				Description:        "",
-				Price:              0,
-				Distance:           distance,
-				CO2Emitted:         0,
-				NumSegment:         numSegment,
-				IsOutward:          isOutbound,
+				DepartureId:         -1, // updated at the end
+				DestinationId:       -1, // updated at the end
+				DepartureCity:       "", // updated at the end
+				DestinationCity:     "", // updated at the end
+				DepartureCountry:    "", // updated at the end
+				DestinationCountry:  "", // updated at the end
+				DateTime:            time.Unix(0, 0),
+				Duration:            time.Duration(duration) * time.Second,
+				Vehicle:             "walk",
+				Description:         "",
+				Price:               0,
+				Currency:            DefaultCurrency,
+				Distance:            distance,
+				CO2Emitted:          0,
+				EmissionMethodology: emissionModel.Version(),
+				NumSegment:          numSegment,
+				IsOutward:           isOutbound,
 				// travel id set later
 			}
 		} else {
@@ -529,14 +598,14 @@ func decodeDirectionsTransit(body []byte, originCity, destinationCity model.City
 			returnedTime := time.Unix(step.TransitDetails.DepartureTime.Value, 0)
 			distance := float64(step.Distance.Value) / 1000
 
-			stepDepCity, err1 := GetCityNoIata(
+			stepDepCity, err1 := googleMapsApi.GetCityNoIata(ctx,
 				step.TransitDetails.DepartureStop.Name,
 				step.TransitDetails.DepartureStop.Location.Latitude,
 				step.TransitDetails.DepartureStop.Location.Longitude)
 			if err1 != nil {
 				return nil, err1
 			}
-			stepDestCity, err1 := GetCityNoIata(
+			stepDestCity, err1 := googleMapsApi.GetCityNoIata(ctx,
 				step.TransitDetails.ArrivalStop.Name,
 				step.TransitDetails.ArrivalStop.Location.Latitude,
 				step.TransitDetails.ArrivalStop.Location.Longitude)
@@ -546,9 +615,21 @@ func decodeDirectionsTransit(body []byte, originCity, destinationCity model.City
 
 			co2Emitted := 0.0
 			if transitMode == "train" {
-				co2Emitted = internals.ComputeTrainEmission(int(distance))
+				departureCountryCode := ""
+				if stepDepCity.CountryCode != nil {
+					departureCountryCode = *stepDepCity.CountryCode
+				}
+				destinationCountryCode := ""
+				if stepDestCity.CountryCode != nil {
+					destinationCountryCode = *stepDestCity.CountryCode
+				}
+				countryDistances, err1 := googleMapsApi.computeCountryDistances(ctx, step.Polyline, distance, departureCountryCode, destinationCountryCode)
+				if err1 != nil {
+					return nil, err1
+				}
+				co2Emitted = emissionModel.TrainEmission(countryDistances)
 			} else if transitMode == "bus" {
-				co2Emitted = internals.ComputeBusEmission(int(distance))
+				co2Emitted = emissionModel.BusEmission(distance)
 			}
 
 			departureCountry := ""
@@ -575,21 +656,23 @@ func decodeDirectionsTransit(body []byte, originCity, destinationCity model.City
 
 			segment = model.Segment{
 				// id is autoincrement
-				DepartureId:        stepDepCity.CityID,
-				DestinationId:      stepDestCity.CityID,
-				DepartureCity:      stepDepCity.CityName,
-				DepartureCountry:   departureCountry,
-				DestinationCity:    stepDestCity.CityName,
-				DestinationCountry: destinationCountry,
-				DateTime:           time.Date(returnedTime.Year(), returnedTime.Month(), returnedTime.Day(), returnedTime.Hour(), returnedTime.Minute(), returnedTime.Second(), returnedTime.Nanosecond(), returnedTime.Location()),
-				Duration:           time.Duration(step.Duration.Value) * time.Second,
-				Vehicle:            travelMode,
-				Description:        description,
-				Price:              GetTransitCost(stepDepCity.CityName, stepDestCity.CityName, transitMode, int(distance)),
-				Distance:           distance,
-				CO2Emitted:         co2Emitted,
-				NumSegment:         numSegment,
-				IsOutward:          isOutbound,
+				DepartureId:         stepDepCity.CityID,
+				DestinationId:       stepDestCity.CityID,
+				DepartureCity:       stepDepCity.CityName,
+				DepartureCountry:    departureCountry,
+				DestinationCity:     stepDestCity.CityName,
+				DestinationCountry:  destinationCountry,
+				DateTime:            time.Date(returnedTime.Year(), returnedTime.Month(), returnedTime.Day(), returnedTime.Hour(), returnedTime.Minute(), returnedTime.Second(), returnedTime.Nanosecond(), returnedTime.Location()),
+				Duration:            time.Duration(step.Duration.Value) * time.Second,
+				Vehicle:             travelMode,
+				Description:         description,
+				Price:               googleMapsApi.priceApis.GetTransitCost(ctx, stepDepCity.CityName, stepDestCity.CityName, transitMode, int(distance)),
+				Currency:            DefaultCurrency,
+				Distance:            distance,
+				CO2Emitted:          co2Emitted,
+				EmissionMethodology: emissionModel.Version(),
+				NumSegment:          numSegment,
+				IsOutward:           isOutbound,
 				// travel id set later
 			}
 		}
@@ -612,6 +695,99 @@ func decodeDirectionsTransit(body []byte, originCity, destinationCity model.City
 	return segments, nil
 }
 
+// computeCountryDistances splits the distance [km] of a transit step between the countries of its stops:
+// the border is searched along the polyline of the step, with a binary search reverse geocoding its points,
+// points in a third country are counted in the destination one. If the step has no polyline,
+// the position of the border is not known and the distance is split in equal shares
+func (googleMapsApi *GoogleMapsApi) computeCountryDistances(ctx context.Context, polyline *Polyline, distance float64, departureCountryCode, destinationCountryCode string) (map[string]float64, error) {
+	if departureCountryCode == destinationCountryCode {
+		return map[string]float64{departureCountryCode: distance}, nil
+	}
+
+	var points []GoogleMapsLocation
+	if polyline != nil {
+		var err error
+		points, err = decodePolyline(polyline.Points)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(points) < 2 {
+		log.Println("Missing polyline of a cross-border step, distance split in equal shares")
+		return map[string]float64{departureCountryCode: distance / 2, destinationCountryCode: distance / 2}, nil
+	}
+
+	// distance along the polyline, from the first point to every point
+	routeDistances := make([]float64, len(points))
+	for i := 1; i < len(points); i++ {
+		routeDistances[i] = routeDistances[i-1] + internals.ComputeHaversineDistance(points[i-1].Latitude, points[i-1].Longitude, points[i].Latitude, points[i].Longitude)
+	}
+
+	// the first point is in the departure country, the last one in the destination country
+	low, high := 0, len(points)-1
+	for high-low > 1 {
+		middle := (low + high) / 2
+		_, countryCode, err := googleMapsApi.getCountryFromCoordinates(ctx, points[middle].Latitude, points[middle].Longitude)
+		if err != nil {
+			return nil, err
+		}
+		if strings.EqualFold(countryCode, departureCountryCode) {
+			low = middle
+		} else {
+			high = middle
+		}
+	}
+
+	// the border is halfway between the last point in the departure country and the next one,
+	// the share is applied to the distance of the step, which follows the tracks
+	departureShare := 0.5
+	routeDistance := routeDistances[len(points)-1]
+	if routeDistance > 0 {
+		departureShare = (routeDistances[low] + routeDistances[high]) / 2 / routeDistance
+	}
+	return map[string]float64{
+		departureCountryCode:   distance * departureShare,
+		destinationCountryCode: distance * (1 - departureShare),
+	}, nil
+}
+
+// decodePolyline decodes the points of a polyline, in the encoded polyline format of Google Maps
+func decodePolyline(encoded string) ([]GoogleMapsLocation, error) {
+	var points []GoogleMapsLocation
+	latitude, longitude := 0, 0
+	for i := 0; i < len(encoded); {
+		// every point is the difference in latitude and longitude from the previous one
+		var deltas [2]int
+		for j := range deltas {
+			value, shift := 0, 0
+			for {
+				if i >= len(encoded) {
+					return nil, fmt.Errorf("invalid polyline")
+				}
+				chunk := int(encoded[i]) - 63
+				i++
+				if chunk < 0 {
+					return nil, fmt.Errorf("invalid polyline")
+				}
+				value |= (chunk & 0x1f) << shift
+				shift += 5
+				if chunk < 0x20 {
+					break
+				}
+			}
+			if value&1 != 0 {
+				deltas[j] = ^(value >> 1)
+			} else {
+				deltas[j] = value >> 1
+			}
+		}
+		latitude += deltas[0]
+		longitude += deltas[1]
+		points = append(points, GoogleMapsLocation{Latitude: float64(latitude) / 1e5, Longitude: float64(longitude) / 1e5})
+	}
+	return points, nil
+}
+
 // method that sets the first city and the last city to origin and destination city (first class cities)
 func resetDepDestCity(segments []model.Segment, originCity, destinationCity model.City) []model.Segment {
 	// set departure
@@ -701,21 +877,23 @@ func compactTransitSegments(segments []model.Segment) []model.Segment {
 			if lastIsWalk {
 				compactedSegments = append(compactedSegments, model.Segment{
 					// id is autoincrement
-					DepartureId:        -1, // updated at the end
-					DestinationId:      -1, // updated at the end
-					DepartureCity:      "", // updated at the end
-					DepartureCountry:   "", // updated at the end
-					DestinationCity:    "", // updated at the end
-					DestinationCountry: "", // updated at the end
-					DateTime:           time.Unix(0, 0),
-					Duration:           totDuration,
-					Vehicle:            "walk",
-					Description:        "",
-					Price:              0,
-					Distance:           totDistance,
-					CO2Emitted:         0,
-					NumSegment:         -1,
-					IsOutward:          isOutward,
+					DepartureId:         -1, // updated at the end
+					DestinationId:       -1, // updated at the end
+					DepartureCity:       "", // updated at the end
+					DepartureCountry:    "", // updated at the end
+					DestinationCity:     "", // updated at the end
+					DestinationCountry:  "", // updated at the end
+					DateTime:            time.Unix(0, 0),
+					Duration:            totDuration,
+					Vehicle:             "walk",
+					Description:         "",
+					Price:               0,
+					Currency:            DefaultCurrency,
+					Distance:            totDistance,
+					CO2Emitted:          0,
+					EmissionMethodology: internals.GetEmissionModel().Version(),
+					NumSegment:          -1,
+					IsOutward:           isOutward,
 					// travel id set later
 				})
 
@@ -809,13 +987,48 @@ func setMissingDataWalkingSegments(segments []model.Segment, originCity, destina
 	return segments
 }
 
-func GetCityNoIata(cityName string, latitude, longitude float64) (model.City, error) {
+func (googleMapsApi *GoogleMapsApi) GetCityNoIata(ctx context.Context, cityName string, latitude, longitude float64) (model.City, error) {
 	// get country associated to city (place more in general) and coordinates
+	countryName, countryCode, err := googleMapsApi.getCountryFromCoordinates(ctx, latitude, longitude)
+	if err != nil {
+		return model.City{}, err
+	}
+
+	// check if a city with same name and country exists
+	city, err := googleMapsApi.cities.GetCityByNameAndCountry(cityName, countryName)
+	if err == nil {
+		return city, nil
+	} else if !errors.Is(err, db.ErrRecordNotFound) {
+		return model.City{}, err
+	}
+
+	// else create a city without iata
+	city = model.City{
+		// id autogenerated
+		CityIata:    nil,
+		CityName:    cityName,
+		CountryName: &countryName,
+		CountryCode: &countryCode,
+		Continent:   nil,
+		Latitude:    &latitude,
+		Longitude:   &longitude,
+	}
+
+	err = googleMapsApi.cities.CreateCity(&city)
+	if err != nil {
+		return model.City{}, err
+	}
+
+	return city, nil
+}
+
+// getCountryFromCoordinates returns name and code of the country of a place, using the geocoding api
+func (googleMapsApi *GoogleMapsApi) getCountryFromCoordinates(ctx context.Context, latitude, longitude float64) (string, string, error) {
 	countryName := ""
 	countryCode := ""
 
 	// google geocoding api
-	baseURL := "https://maps.googleapis.com/maps/api/geocode/json"
+	baseURL := googleMapsApi.baseUrl + "/maps/api/geocode/json"
 
 	latitudeString := strconv.FormatFloat(latitude, 'f', -1, 64)
 	longitudeString := strconv.FormatFloat(longitude, 'f', -1, 64)
@@ -823,14 +1036,19 @@ func GetCityNoIata(cityName string, latitude, longitude float64) (model.City, er
 
 	params := url.Values{}
 	params.Add("latlng", latlngString)
-	params.Add("key", googleApiKey)
+	params.Add("key", googleMapsApi.apiKey)
 
 	fullURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
 
-	resp, err := http.Get(fullURL)
+	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
 	if err != nil {
 		log.Println("error creating the request: ", err)
-		return model.City{}, err
+		return "", "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Println("error creating the request: ", err)
+		return "", "", err
 	}
 	defer func() {
 		err = resp.Body.Close()
@@ -842,12 +1060,12 @@ func GetCityNoIata(cityName string, latitude, longitude float64) (model.City, er
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		log.Println("error reading the body: ", err)
-		return model.City{}, err
+		return "", "", err
 	}
 
 	// check response status code
 	if resp.StatusCode != http.StatusOK {
-		return model.City{}, fmt.Errorf("errore HTTP: %d - %s", resp.StatusCode, string(body))
+		return "", "", fmt.Errorf("errore HTTP: %d - %s", resp.StatusCode, string(body))
 	}
 
 	var response GeocodeResponse
@@ -856,7 +1074,7 @@ func GetCityNoIata(cityName string, latitude, longitude float64) (model.City, er
 	err = decoder.Decode(&response)
 	if err != nil {
 		log.Println("error decoding JSON: ", err)
-		return model.City{}, err
+		return "", "", err
 	}
 
 	for _, result := range response.Results {
@@ -871,30 +1089,127 @@ func GetCityNoIata(cityName string, latitude, longitude float64) (model.City, er
 		}
 	}
 
-	cityDAO := db.NewCityDAO(db.GetDB())
+	return countryName, countryCode, nil
+}
 
-	// check if a city with same name and country exists
-	city, err := cityDAO.GetCityByNameAndCountry(cityName, countryName)
-	if err == nil {
-		return city, nil
-	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-		return model.City{}, err
+// GetCityCoordinates returns latitude and longitude of a city, using the geocoding api
+func (googleMapsApi *GoogleMapsApi) GetCityCoordinates(ctx context.Context, city model.City) (float64, float64, error) {
+	// google geocoding api
+	baseURL := googleMapsApi.baseUrl + "/maps/api/geocode/json"
+
+	address := city.CityName
+	if city.CountryName != nil {
+		address += ", " + *city.CountryName
 	}
 
-	// else create a city without iata
-	city = model.City{
-		// id autogenerated
-		CityIata:    nil,
-		CityName:    cityName,
-		CountryName: &countryName,
-		CountryCode: &countryCode,
-		Continent:   nil,
+	params := url.Values{}
+	params.Add("address", address)
+	params.Add("key", googleMapsApi.apiKey)
+
+	fullURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
+	if err != nil {
+		log.Println("error creating the request: ", err)
+		return 0, 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Println("error creating the request: ", err)
+		return 0, 0, err
 	}
+	defer func() {
+		err = resp.Body.Close()
+		if err != nil {
+			log.Println("Error closing response body:", err)
+		}
+	}()
 
-	err = cityDAO.CreateCity(&city)
+	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return model.City{}, err
+		log.Println("error reading the body: ", err)
+		return 0, 0, err
 	}
 
-	return city, nil
+	// check response status code
+	if resp.StatusCode != http.StatusOK {
+		return 0, 0, fmt.Errorf("errore HTTP: %d - %s", resp.StatusCode, string(body))
+	}
+
+	var response GeocodeResponse
+	jsonReader := bytes.NewReader(body)
+	decoder := json.NewDecoder(jsonReader)
+	err = decoder.Decode(&response)
+	if err != nil {
+		log.Println("error decoding JSON: ", err)
+		return 0, 0, err
+	}
+
+	if len(response.Results) == 0 ||
+		response.Results[0].Geometry == nil ||
+		response.Results[0].Geometry.Location == nil {
+		return 0, 0, fmt.Errorf("no coordinates found for %s", address)
+	}
+
+	location := response.Results[0].Geometry.Location
+	return location.Latitude, location.Longitude, nil
+}
+
+// GetTimeZone returns the time zone of a place at the given instant, using the time zone api:
+// the offset includes the daylight saving time in effect at that instant
+func (googleMapsApi *GoogleMapsApi) GetTimeZone(ctx context.Context, latitude, longitude float64, instant time.Time) (*time.Location, error) {
+	// google time zone api
+	baseURL := googleMapsApi.baseUrl + "/maps/api/timezone/json"
+
+	latitudeString := strconv.FormatFloat(latitude, 'f', -1, 64)
+	longitudeString := strconv.FormatFloat(longitude, 'f', -1, 64)
+
+	params := url.Values{}
+	params.Add("location", latitudeString+","+longitudeString)
+	params.Add("timestamp", strconv.FormatInt(instant.Unix(), 10))
+	params.Add("key", googleMapsApi.apiKey)
+
+	fullURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
+	if err != nil {
+		log.Println("error creating the request: ", err)
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Println("error creating the request: ", err)
+		return nil, err
+	}
+	defer func() {
+		err = resp.Body.Close()
+		if err != nil {
+			log.Println("Error closing response body:", err)
+		}
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Println("error reading the body: ", err)
+		return nil, err
+	}
+
+	// check response status code
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("errore HTTP: %d - %s", resp.StatusCode, string(body))
+	}
+
+	var response TimeZoneResponse
+	jsonReader := bytes.NewReader(body)
+	decoder := json.NewDecoder(jsonReader)
+	err = decoder.Decode(&response)
+	if err != nil {
+		log.Println("error decoding JSON: ", err)
+		return nil, err
+	}
+	if response.Status != "OK" {
+		return nil, fmt.Errorf("no time zone found for %s,%s: %s", latitudeString, longitudeString, response.Status)
+	}
+
+	return time.FixedZone(response.TimeZoneID, response.RawOffset+response.DstOffset), nil
 }
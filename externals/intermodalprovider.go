@@ -0,0 +1,185 @@
+package externals
+
+import (
+	"context"
+	"fmt"
+	"green-journey-server/db"
+	"green-journey-server/model"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// max distance of a hub airport from the departure city [km]
+	maxHubAirportDistance = 200
+	// max number of hub airports tried by a search
+	maxHubAirports = 2
+	// min time between the arrival at the hub and the departure of the flight
+	minAirportConnectionTime = 90 * time.Minute
+)
+
+// intermodalProvider combines a train or bus to a hub airport near the departure city
+// with a flight from the hub to the destination, e.g. train to Milan Malpensa, then fly
+type intermodalProvider struct {
+	cities        db.CityRepository
+	googleMapsApi *GoogleMapsApi
+	amadeusApi    *AmadeusApi
+}
+
+func (intermodalProvider) Name() string {
+	return "intermodal"
+}
+
+func (intermodalProvider) Vehicles() []string {
+	return []string{"train", "bus", "plane"}
+}
+
+func (provider intermodalProvider) Search(ctx context.Context, request SearchRequest) ([][]model.Segment, error) {
+	hubs, err := provider.findHubAirports(ctx, request.DepartureCity, request.DestinationCity)
+	if err != nil {
+		return nil, err
+	}
+
+	// every hub is tried in its own go routine
+	var wg sync.WaitGroup
+	hubOptions := make([][][]model.Segment, len(hubs))
+	hubErrors := make([]error, len(hubs))
+	for i, hub := range hubs {
+		wg.Add(1)
+		go func(i int, hub model.Airport) {
+			defer wg.Done()
+			hubOptions[i], hubErrors[i] = provider.composeHubOptions(ctx, request, hub)
+		}(i, hub)
+	}
+	wg.Wait()
+
+	var options [][]model.Segment
+	var lastErr error
+	for i := range hubs {
+		if hubErrors[i] != nil {
+			log.Println("Error composing options via ", hubs[i].AirportIata, ": ", hubErrors[i])
+			lastErr = hubErrors[i]
+			continue
+		}
+		options = append(options, hubOptions[i]...)
+	}
+
+	// the search fails only if no hub could be used
+	if len(options) == 0 {
+		return nil, lastErr
+	}
+	return options, nil
+}
+
+// findHubAirports returns the airports closest to the departure city, sorted by distance:
+// airports of the departure and destination cities are excluded, direct flights are searched by other providers
+func (provider intermodalProvider) findHubAirports(ctx context.Context, departureCity, destinationCity model.City) ([]model.Airport, error) {
+
+	// departure coordinates, from the city itself, from its airports or from the geocoding api
+	var latitude, longitude float64
+	if departureCity.Latitude != nil && departureCity.Longitude != nil {
+		latitude, longitude = *departureCity.Latitude, *departureCity.Longitude
+	} else {
+		departureAirports, err := provider.cities.GetAirportsByCityId(departureCity.CityID)
+		if err != nil {
+			return nil, err
+		}
+		if len(departureAirports) > 0 {
+			for _, airport := range departureAirports {
+				latitude += airport.Latitude
+				longitude += airport.Longitude
+			}
+			latitude /= float64(len(departureAirports))
+			longitude /= float64(len(departureAirports))
+		} else {
+			latitude, longitude, err = provider.googleMapsApi.GetCityCoordinates(ctx, departureCity)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	nearbyAirports, err := provider.cities.GetNearbyAirports(latitude, longitude, maxHubAirportDistance)
+	if err != nil {
+		return nil, err
+	}
+
+	// airports are already sorted by distance
+	var hubs []model.Airport
+	for _, airport := range nearbyAirports {
+		if airport.CityID == departureCity.CityID || airport.CityID == destinationCity.CityID {
+			continue
+		}
+		hubs = append(hubs, airport.Airport)
+		if len(hubs) == maxHubAirports {
+			break
+		}
+	}
+
+	return hubs, nil
+}
+
+// composeHubOptions searches a train, or a bus if there is no train, from the departure city to the hub,
+// then the flights from the hub leaving after the min connection time
+func (provider intermodalProvider) composeHubOptions(ctx context.Context, request SearchRequest, hub model.Airport) ([][]model.Segment, error) {
+	hubCity, err := provider.cities.GetCityById(hub.CityID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the ground leg goes to the airport, not to the city center
+	groundDestination := hubCity
+	groundDestination.CityName = hub.AirportName
+	groundLeg, err := provider.googleMapsApi.GetDirectionsTrain(ctx, request.DepartureCity, groundDestination, request.Date, request.Time, request.IsOutward)
+	if err != nil || len(groundLeg) == 0 {
+		groundLeg, err = provider.googleMapsApi.GetDirectionsBus(ctx, request.DepartureCity, groundDestination, request.Date, request.Time, request.IsOutward)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(groundLeg) == 0 {
+		return nil, fmt.Errorf("no ground connection to %s", hub.AirportIata)
+	}
+
+	// flights must leave the hub airport after the connection time
+	lastGroundSegment := groundLeg[len(groundLeg)-1]
+	earliestDeparture := lastGroundSegment.DateTime.Add(lastGroundSegment.Duration).Add(minAirportConnectionTime)
+
+	// the ground leg times are instants, while amadeus times are the local times of the airports
+	// parsed as utc: the earliest departure is converted to the local time of the hub
+	hubLocation, err := provider.googleMapsApi.GetTimeZone(ctx, hub.Latitude, hub.Longitude, earliestDeparture)
+	if err != nil {
+		return nil, err
+	}
+	earliestDeparture = localTimeAsUTC(earliestDeparture.In(hubLocation))
+
+	flightDeparture := hubCity
+	flightDeparture.CityIata = &hub.AirportIata
+	flights, err := provider.amadeusApi.GetFlights(ctx, flightDeparture, request.DestinationCity, earliestDeparture, earliestDeparture, request.IsOutward, request.FlightOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	var options [][]model.Segment
+	for _, flight := range flights {
+		if len(flight) == 0 || flight[0].DateTime.Before(earliestDeparture) {
+			continue
+		}
+
+		option := make([]model.Segment, 0, len(groundLeg)+len(flight))
+		option = append(option, groundLeg...)
+		option = append(option, flight...)
+		for i := range option {
+			option[i].NumSegment = i + 1
+		}
+		options = append(options, option)
+	}
+
+	return options, nil
+}
+
+// localTimeAsUTC returns the same wall clock time in utc, as the times returned by amadeus
+func localTimeAsUTC(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
@@ -0,0 +1,30 @@
+package externals
+
+import (
+	"green-journey-server/config"
+	"sync"
+	"time"
+)
+
+// PriceApis calls the toll, transit cost, fuel cost and exchange rate apis,
+// which can be served by the local mock servers
+type PriceApis struct {
+	tollApiUrl         string
+	transitCostApiUrl  string
+	fuelCostApiUrl     string
+	exchangeRateApiUrl string
+
+	// exchange rates read from the api, until they expire
+	exchangeRates           map[string]float64
+	exchangeRatesExpiration time.Time
+	exchangeRatesMutex      sync.Mutex
+}
+
+func NewPriceApis(mockApisConfig config.MockApisConfig) *PriceApis {
+	return &PriceApis{
+		tollApiUrl:         mockApisConfig.Toll.URL,
+		transitCostApiUrl:  mockApisConfig.TransitCost.URL,
+		fuelCostApiUrl:     mockApisConfig.FuelCost.URL,
+		exchangeRateApiUrl: mockApisConfig.ExchangeRate.URL,
+	}
+}
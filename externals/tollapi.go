@@ -2,6 +2,7 @@ package externals
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"log"
@@ -14,12 +15,17 @@ type TollCostResponse struct {
 	TollCost float64 `json:"toll-cost"`
 }
 
-func GetTollCost(from, to string, distance int) float64 {
+func (priceApis *PriceApis) GetTollCost(ctx context.Context, from, to string, distance int) float64 {
 	tollCost := 0.0
 
 	// call api
-	apiUrl := "http://localhost:8081/tollapi?from=" + url.QueryEscape(from) + "&to=" + url.QueryEscape(to) + "&distance=" + url.QueryEscape(strconv.Itoa(distance))
-	resp, err := http.Get(apiUrl)
+	apiUrl := priceApis.tollApiUrl + "?from=" + url.QueryEscape(from) + "&to=" + url.QueryEscape(to) + "&distance=" + url.QueryEscape(strconv.Itoa(distance))
+	req, err := http.NewRequestWithContext(ctx, "GET", apiUrl, nil)
+	if err != nil {
+		log.Println("Error while creating the request")
+		return tollCost
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		log.Println("Error while getting toll cost from api")
 		return tollCost
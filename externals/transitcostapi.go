@@ -2,6 +2,7 @@ package externals
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"log"
@@ -14,12 +15,17 @@ type TransitCostResponse struct {
 	TransitCost float64 `json:"transit-cost"`
 }
 
-func GetTransitCost(from, to, transitMode string, distance int) float64 {
+func (priceApis *PriceApis) GetTransitCost(ctx context.Context, from, to, transitMode string, distance int) float64 {
 	transitCost := 0.0
 
 	// call api
-	apiUrl := "http://localhost:8082/transitcostapi?from=" + url.QueryEscape(from) + "&to=" + url.QueryEscape(to) + "&mode=" + url.QueryEscape(transitMode) + "&distance=" + strconv.Itoa(distance)
-	resp, err := http.Get(apiUrl)
+	apiUrl := priceApis.transitCostApiUrl + "?from=" + url.QueryEscape(from) + "&to=" + url.QueryEscape(to) + "&mode=" + url.QueryEscape(transitMode) + "&distance=" + strconv.Itoa(distance)
+	req, err := http.NewRequestWithContext(ctx, "GET", apiUrl, nil)
+	if err != nil {
+		log.Println("Error while creating the request")
+		return transitCost
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		log.Println("Error while getting transit cost from api")
 		return transitCost
@@ -0,0 +1,253 @@
+package externals
+
+import (
+	"context"
+	"green-journey-server/config"
+	"green-journey-server/db"
+	"green-journey-server/internals"
+	"green-journey-server/model"
+	"sync"
+	"time"
+)
+
+// SearchRequest contains the parameters of a travel search, shared by all providers
+type SearchRequest struct {
+	DepartureCity   model.City
+	DestinationCity model.City
+	Date            time.Time
+	Time            time.Time
+	IsOutward       bool
+	// car used by the search, nil for an average car, and number of people sharing it
+	VehicleProfile *model.VehicleProfile
+	Passengers     int
+	// flight cabin class and radiative forcing
+	FlightOptions FlightOptions
+	// return date and time, only used by round trip searches
+	ReturnDate time.Time
+	ReturnTime time.Time
+}
+
+// TravelProvider is a source of travel options, e.g. an external api,
+// that can be plugged into the travel search
+type TravelProvider interface {
+	// Name identifies the provider, it is unique in the registry
+	Name() string
+	// Vehicles returns the vehicles used by the options of the provider
+	Vehicles() []string
+	// Search returns the travel options, each option is a list of segments
+	Search(ctx context.Context, request SearchRequest) ([][]model.Segment, error)
+}
+
+// RoundTripProvider is implemented by providers that can search outward and return options together,
+// e.g. flights sold as a single round trip offer
+type RoundTripProvider interface {
+	TravelProvider
+	// SearchRoundTrip returns outward and return options already paired
+	SearchRoundTrip(ctx context.Context, request SearchRequest) ([]model.RoundTripOption, error)
+}
+
+var (
+	travelProviders        []TravelProvider
+	travelProviderTimeouts = map[string]time.Duration{}
+	// deadline of a provider search, if not configured otherwise
+	defaultTravelProviderTimeout = 10 * time.Second
+	travelProvidersMutex         sync.RWMutex
+)
+
+// RegisterTravelProvider adds a provider to the registry,
+// a provider with the same name is replaced
+func RegisterTravelProvider(provider TravelProvider) {
+	travelProvidersMutex.Lock()
+	defer travelProvidersMutex.Unlock()
+
+	for i := range travelProviders {
+		if travelProviders[i].Name() == provider.Name() {
+			travelProviders[i] = provider
+			return
+		}
+	}
+	travelProviders = append(travelProviders, provider)
+}
+
+// UnregisterTravelProvider removes a provider from the registry, if present
+func UnregisterTravelProvider(name string) {
+	travelProvidersMutex.Lock()
+	defer travelProvidersMutex.Unlock()
+
+	for i := range travelProviders {
+		if travelProviders[i].Name() == name {
+			travelProviders = append(travelProviders[:i], travelProviders[i+1:]...)
+			return
+		}
+	}
+}
+
+// GetTravelProviders returns a copy of the registered providers
+func GetTravelProviders() []TravelProvider {
+	travelProvidersMutex.RLock()
+	defer travelProvidersMutex.RUnlock()
+
+	providers := make([]TravelProvider, len(travelProviders))
+	copy(providers, travelProviders)
+	return providers
+}
+
+// SetTravelProviderTimeout sets the deadline of the searches of a provider
+func SetTravelProviderTimeout(name string, timeout time.Duration) {
+	travelProvidersMutex.Lock()
+	defer travelProvidersMutex.Unlock()
+
+	travelProviderTimeouts[name] = timeout
+}
+
+// GetTravelProviderTimeout returns the deadline of the searches of a provider
+func GetTravelProviderTimeout(name string) time.Duration {
+	travelProvidersMutex.RLock()
+	defer travelProvidersMutex.RUnlock()
+
+	timeout, ok := travelProviderTimeouts[name]
+	if !ok {
+		return defaultTravelProviderTimeout
+	}
+	return timeout
+}
+
+// SetTravelProviderTimeouts sets the deadlines of the configured providers and the default deadline of the others
+func SetTravelProviderTimeouts(timeoutsConfig config.TimeoutsConfig) {
+	travelProvidersMutex.Lock()
+	defer travelProvidersMutex.Unlock()
+
+	defaultTravelProviderTimeout = timeoutsConfig.Default
+	for name, timeout := range timeoutsConfig.Providers {
+		travelProviderTimeouts[name] = timeout
+	}
+}
+
+// RegisterDefaultTravelProviders registers the providers backed by Amadeus and Google Maps,
+// and the intermodal provider combining them, which finds the hub airports in cities
+func RegisterDefaultTravelProviders(cities db.CityRepository, googleMapsApi *GoogleMapsApi, amadeusApi *AmadeusApi) {
+	RegisterTravelProvider(flightProvider{amadeusApi: amadeusApi})
+	RegisterTravelProvider(googleMapsProvider{name: "google_maps_bike", vehicle: "bike", fetch: googleMapsApi.GetDirectionsBike})
+	RegisterTravelProvider(carProvider{googleMapsApi: googleMapsApi})
+	RegisterTravelProvider(googleMapsProvider{name: "google_maps_train", vehicle: "train", fetch: googleMapsApi.GetDirectionsTrain})
+	RegisterTravelProvider(googleMapsProvider{name: "google_maps_bus", vehicle: "bus", fetch: googleMapsApi.GetDirectionsBus})
+	RegisterTravelProvider(intermodalProvider{cities: cities, googleMapsApi: googleMapsApi, amadeusApi: amadeusApi})
+}
+
+// SearchRoundTrip returns the round trip options of a provider: if the provider doesn't support
+// round trips, outward and return options are searched concurrently and paired
+func SearchRoundTrip(ctx context.Context, provider TravelProvider, request SearchRequest) ([]model.RoundTripOption, error) {
+	roundTripProvider, ok := provider.(RoundTripProvider)
+	if ok {
+		return roundTripProvider.SearchRoundTrip(ctx, request)
+	}
+
+	// return request, from destination to departure
+	returnRequest := request
+	returnRequest.DepartureCity = request.DestinationCity
+	returnRequest.DestinationCity = request.DepartureCity
+	returnRequest.Date = request.ReturnDate
+	returnRequest.Time = request.ReturnTime
+	returnRequest.IsOutward = false
+	request.IsOutward = true
+
+	var wg sync.WaitGroup
+	var outwardOptions, returnOptions [][]model.Segment
+	var outwardErr, returnErr error
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		outwardOptions, outwardErr = provider.Search(ctx, request)
+	}()
+	go func() {
+		defer wg.Done()
+		returnOptions, returnErr = provider.Search(ctx, returnRequest)
+	}()
+	wg.Wait()
+
+	if outwardErr != nil {
+		return nil, outwardErr
+	}
+	if returnErr != nil {
+		return nil, returnErr
+	}
+
+	// pair every outward option with every return option
+	var roundTrips []model.RoundTripOption
+	for _, outwardOption := range outwardOptions {
+		for _, returnOption := range returnOptions {
+			if outwardOption == nil || returnOption == nil {
+				continue
+			}
+			roundTrips = append(roundTrips, internals.ComputeRoundTripOption(outwardOption, returnOption))
+		}
+	}
+
+	return roundTrips, nil
+}
+
+// flightProvider returns flight options from Amadeus
+type flightProvider struct {
+	amadeusApi *AmadeusApi
+}
+
+func (flightProvider) Name() string {
+	return "amadeus"
+}
+
+func (flightProvider) Vehicles() []string {
+	return []string{"plane"}
+}
+
+func (provider flightProvider) Search(ctx context.Context, request SearchRequest) ([][]model.Segment, error) {
+	return provider.amadeusApi.GetFlights(ctx, request.DepartureCity, request.DestinationCity, request.Date, request.Time, request.IsOutward, request.FlightOptions)
+}
+
+func (provider flightProvider) SearchRoundTrip(ctx context.Context, request SearchRequest) ([]model.RoundTripOption, error) {
+	return provider.amadeusApi.GetRoundTripFlights(ctx, request.DepartureCity, request.DestinationCity, request.Date, request.Time, request.ReturnDate, request.ReturnTime, request.FlightOptions)
+}
+
+// carProvider returns the car option computed by Google Maps, with price and co2 per person
+type carProvider struct {
+	googleMapsApi *GoogleMapsApi
+}
+
+func (carProvider) Name() string {
+	return "google_maps_car"
+}
+
+func (carProvider) Vehicles() []string {
+	return []string{"car"}
+}
+
+func (provider carProvider) Search(ctx context.Context, request SearchRequest) ([][]model.Segment, error) {
+	directions, err := provider.googleMapsApi.GetDirectionsCar(ctx, request.DepartureCity, request.DestinationCity, request.Date, request.Time, request.IsOutward, request.VehicleProfile, request.Passengers)
+	if err != nil || directions == nil {
+		return nil, err
+	}
+	return [][]model.Segment{directions}, nil
+}
+
+// googleMapsProvider returns the single option computed by a Google Maps directions call
+type googleMapsProvider struct {
+	name    string
+	vehicle string
+	fetch   func(context.Context, model.City, model.City, time.Time, time.Time, bool) ([]model.Segment, error)
+}
+
+func (provider googleMapsProvider) Name() string {
+	return provider.name
+}
+
+func (provider googleMapsProvider) Vehicles() []string {
+	return []string{provider.vehicle}
+}
+
+func (provider googleMapsProvider) Search(ctx context.Context, request SearchRequest) ([][]model.Segment, error) {
+	directions, err := provider.fetch(ctx, request.DepartureCity, request.DestinationCity, request.Date, request.Time, request.IsOutward)
+	if err != nil || directions == nil {
+		return nil, err
+	}
+	return [][]model.Segment{directions}, nil
+}
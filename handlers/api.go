@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"green-journey-server/db"
+	"green-journey-server/externals"
+	"green-journey-server/policy"
+)
+
+// API holds the dependencies of the handlers, created once at startup: the handlers
+// can be served with the database repositories or with the in-memory ones.
+// Prices are converted with the exchange rates of priceApis
+type API struct {
+	repositories db.Repositories
+	priceApis    *externals.PriceApis
+	policy       *policy.Policy
+	citiesCache  citiesCache
+}
+
+func NewAPI(repositories db.Repositories, priceApis *externals.PriceApis) *API {
+	return &API{
+		repositories: repositories,
+		priceApis:    priceApis,
+		policy:       policy.NewPolicy(repositories.Travels, repositories.Reviews, repositories.VehicleProfiles),
+	}
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"green-journey-server/db"
+	"green-journey-server/externals"
+	"green-journey-server/model"
+	"green-journey-server/policy"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// AccessLevel defines who can call an endpoint
+type AccessLevel int
+
+const (
+	// AccessPublic endpoints can be called without token, if a valid token is sent its user is resolved anyway
+	AccessPublic AccessLevel = iota
+	// AccessIdentified endpoints need a valid token, but the user may not exist yet, e.g. during sign up
+	AccessIdentified
+	// AccessAuthenticated endpoints need a valid token of an existing user
+	AccessAuthenticated
+	// AccessAdmin endpoints need an authenticated user whose firebase uid is one of the configured admins
+	AccessAdmin
+)
+
+// Endpoint is the handler of a method of a route, together with its access level
+type Endpoint struct {
+	Access  AccessLevel
+	Handler http.HandlerFunc
+}
+
+// Router registers the routes of the server: every method of a route declares its access level
+// and is authenticated before the handler is called, methods not declared are rejected
+type Router struct {
+	mux       *http.ServeMux
+	adminUIDs map[string]bool
+	users     db.UserRepository
+}
+
+type contextKey string
+
+const (
+	firebaseUIDContextKey contextKey = "firebase_uid"
+	userContextKey        contextKey = "user"
+)
+
+// NewRouter creates a router, adminUIDs are the firebase uids of the admins:
+// the users of the tokens are read from users
+func NewRouter(adminUIDs []string, users db.UserRepository) *Router {
+	router := &Router{
+		mux:       http.NewServeMux(),
+		adminUIDs: map[string]bool{},
+		users:     users,
+	}
+	for _, adminUID := range adminUIDs {
+		router.adminUIDs[adminUID] = true
+	}
+	return router
+}
+
+// Handle registers the endpoints of a route, indexed by method
+func (router *Router) Handle(pattern string, endpoints map[string]Endpoint) {
+	router.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		endpoint, ok := endpoints[r.Method]
+		if !ok {
+			log.Println("Method not supported")
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+			return
+		}
+
+		r, ok = router.authenticate(w, r, endpoint.Access)
+		if !ok {
+			return
+		}
+		endpoint.Handler(w, r)
+	})
+}
+
+func (router *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	router.mux.ServeHTTP(w, r)
+}
+
+// authenticate checks the token of the request against the access level, the firebase uid
+// and the user are added to the request context: if the request is not allowed,
+// the error is written and false is returned
+func (router *Router) authenticate(w http.ResponseWriter, r *http.Request, access AccessLevel) (*http.Request, bool) {
+	// get bearer token
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
+		if access == AccessPublic {
+			return r, true
+		}
+		log.Println("Missing or invalid auth header")
+		http.Error(w, "Missing or invalid auth header", http.StatusUnauthorized)
+		return nil, false
+	}
+	idToken := strings.TrimPrefix(authHeader, "Bearer ")
+
+	// verify token, with Firebase or the configured verifier
+	ctx := r.Context()
+	firebaseUID, err := externals.GetAuthVerifier().VerifyToken(ctx, idToken)
+	if err != nil {
+		if access == AccessPublic {
+			return r, true
+		}
+		log.Println("Unauthorized", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	ctx = context.WithValue(ctx, firebaseUIDContextKey, firebaseUID)
+
+	// get user associated to firebaseUID, badges are computed only by the handlers returning them
+	user, err := router.users.GetUserByFirebaseUIDNoBadges(firebaseUID)
+	if err != nil {
+		if access == AccessPublic || access == AccessIdentified {
+			return r.WithContext(ctx), true
+		}
+		log.Println("User not found: ", err)
+		http.Error(w, "User could not be found", http.StatusNotFound)
+		return nil, false
+	}
+	ctx = context.WithValue(ctx, userContextKey, user)
+
+	if access == AccessAdmin && !router.adminUIDs[firebaseUID] {
+		log.Println("Forbidden, user is not an admin")
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil, false
+	}
+
+	return r.WithContext(ctx), true
+}
+
+// FirebaseUIDFromContext returns the firebase uid of the verified token, if any
+func FirebaseUIDFromContext(ctx context.Context) (string, bool) {
+	firebaseUID, ok := ctx.Value(firebaseUIDContextKey).(string)
+	return firebaseUID, ok
+}
+
+// UserFromContext returns the authenticated user, if any
+func UserFromContext(ctx context.Context) (model.User, bool) {
+	user, ok := ctx.Value(userContextKey).(model.User)
+	return user, ok
+}
+
+// requireFirebaseUID returns the firebase uid of the request, if the token was not verified
+// the error is written and false is returned
+func requireFirebaseUID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	firebaseUID, ok := FirebaseUIDFromContext(r.Context())
+	if !ok {
+		log.Println("Unauthorized, missing firebase uid")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return "", false
+	}
+	return firebaseUID, true
+}
+
+// requireUser returns the authenticated user of the request, if the user was not resolved
+// the error is written and false is returned
+func requireUser(w http.ResponseWriter, r *http.Request) (model.User, bool) {
+	user, ok := UserFromContext(r.Context())
+	if !ok {
+		log.Println("Unauthorized, missing user")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return model.User{}, false
+	}
+	return user, true
+}
+
+// writePolicyError writes the error of a policy check: missing resources are not found,
+// resources of other users are forbidden
+func writePolicyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, policy.ErrNotFound):
+		log.Println("Resource not found: ", err)
+		http.Error(w, "Not found", http.StatusNotFound)
+	case errors.Is(err, policy.ErrForbidden):
+		log.Println("Forbidden: ", err)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	default:
+		log.Println("Error while interacting with the database: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
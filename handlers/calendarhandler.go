@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"green-journey-server/externals"
+	"green-journey-server/internals"
+	"green-journey-server/model"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// default and max number of days searched before and after the requested date
+	defaultCalendarDays = 3
+	maxCalendarDays     = 7
+	// number of days searched at the same time, to limit the load on the external apis
+	maxConcurrentCalendarDays = 3
+	// time a day search is kept in the cache
+	calendarCacheTTL = 30 * time.Minute
+)
+
+type CalendarOptions struct {
+	Days []model.CalendarDay `json:"days"`
+}
+
+// calendarCacheEntry contains the unfiltered options of a day search
+type calendarCacheEntry struct {
+	options    [][]model.Segment
+	expiration time.Time
+}
+
+var (
+	calendarCache      = map[string]calendarCacheEntry{}
+	calendarCacheMutex sync.Mutex
+)
+
+// HandleSearchCalendar runs the travel search on the days around the requested date,
+// returning for each day and vehicle the cheapest price and the lowest co2 found
+func (api *API) HandleSearchCalendar(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		log.Println("Method not supported")
+		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	start := time.Now()
+
+	// get request parameters
+	request, ok := api.parseSearchRequest(w, r, false)
+	if !ok {
+		return
+	}
+	filter, ok := parseTravelOptionsFilter(w, r)
+	if !ok {
+		return
+	}
+	currency, ok := api.parseCurrency(w, r)
+	if !ok {
+		return
+	}
+	days := defaultCalendarDays
+	if daysStr := r.URL.Query().Get("days"); daysStr != "" {
+		var err error
+		days, err = strconv.Atoi(daysStr)
+		if err != nil || days < 0 || days > maxCalendarDays {
+			log.Println("Invalid number of days")
+			http.Error(w, "Invalid number of days", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// days in the past are not searched
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	var dates []time.Time
+	for i := -days; i <= days; i++ {
+		date := request.Date.AddDate(0, 0, i)
+		if date.Before(today) {
+			continue
+		}
+		dates = append(dates, date)
+	}
+
+	// search the days concurrently, at most maxConcurrentCalendarDays at the same time
+	calendarDays := make([]model.CalendarDay, len(dates))
+	conversionErrors := make([]error, len(dates))
+	semaphore := make(chan struct{}, maxConcurrentCalendarDays)
+	var wg sync.WaitGroup
+	for i, date := range dates {
+		wg.Add(1)
+		go func(i int, date time.Time) {
+			defer wg.Done()
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			dayRequest := request
+			dayRequest.Date = date
+			options, skippedProviders := searchCalendarDay(r.Context(), dayRequest)
+			// cached options are in the currency of the providers
+			options, err := api.convertTravelOptions(r.Context(), options, currency)
+			if err != nil {
+				conversionErrors[i] = err
+				return
+			}
+
+			if skippedProviders == nil {
+				skippedProviders = []string{}
+			}
+			calendarDays[i] = model.CalendarDay{
+				Date:             date.Format("2006-01-02"),
+				Vehicles:         internals.ComputeCalendarEntries(internals.FilterTravelOptions(options, filter)),
+				SkippedProviders: skippedProviders,
+			}
+		}(i, date)
+	}
+	wg.Wait()
+
+	// if the client disconnected, nobody is waiting for the response
+	if r.Context().Err() != nil {
+		log.Println("Client disconnected during search: ", r.Context().Err())
+		return
+	}
+
+	for _, err := range conversionErrors {
+		if err != nil {
+			log.Println("Error converting prices: ", err)
+			http.Error(w, "Error converting prices", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	elapsed := time.Since(start)
+	log.Println("TOTAL SEARCH CALENDAR call took:", elapsed)
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(CalendarOptions{Days: calendarDays})
+	if err != nil {
+		log.Println("Error encoding JSON: ", err)
+		http.Error(w, "Error encoding JSON", http.StatusInternalServerError)
+		return
+	}
+}
+
+// searchCalendarDay returns the options of a day from the cache, or searches them:
+// searches with skipped providers are incomplete, so they are not cached
+func searchCalendarDay(ctx context.Context, request externals.SearchRequest) ([][]model.Segment, []string) {
+	key := calendarCacheKey(request)
+
+	calendarCacheMutex.Lock()
+	entry, ok := calendarCache[key]
+	calendarCacheMutex.Unlock()
+	if ok && time.Now().Before(entry.expiration) {
+		return entry.options, nil
+	}
+
+	options, skippedProviders := computeApiData(ctx, request)
+	if len(skippedProviders) > 0 || ctx.Err() != nil {
+		return options, skippedProviders
+	}
+
+	calendarCacheMutex.Lock()
+	defer calendarCacheMutex.Unlock()
+
+	// remove expired entries, so that the cache doesn't grow forever
+	now := time.Now()
+	for cachedKey, cachedEntry := range calendarCache {
+		if now.After(cachedEntry.expiration) {
+			delete(calendarCache, cachedKey)
+		}
+	}
+	calendarCache[key] = calendarCacheEntry{
+		options:    options,
+		expiration: now.Add(calendarCacheTTL),
+	}
+
+	return options, nil
+}
+
+func calendarCacheKey(request externals.SearchRequest) string {
+	// the car option depends on the vehicle and the passengers, the profile content is used
+	// instead of its id, so that updated profiles don't hit old entries
+	vehicle := "average"
+	if request.VehicleProfile != nil {
+		vehicle = request.VehicleProfile.FuelType + ":" + strconv.FormatFloat(request.VehicleProfile.Consumption, 'f', -1, 64)
+	}
+	return vehicle + "|" +
+		strconv.Itoa(request.Passengers) + "|" +
+		request.FlightOptions.CabinClass + "|" +
+		strconv.FormatBool(request.FlightOptions.RadiativeForcing) + "|" +
+		strconv.Itoa(request.DepartureCity.CityID) + "|" +
+		strconv.Itoa(request.DestinationCity.CityID) + "|" +
+		request.Date.Format("2006-01-02") + "|" +
+		request.Time.Format("15:04") + "|" +
+		strconv.FormatBool(request.IsOutward)
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/json"
+	"green-journey-server/internals"
+	"green-journey-server/model"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCitiesPageSize = 20
+	maxCitiesPageSize     = 100
+	// cities rarely change, they are read from the db at most once in this interval
+	citiesCacheTTL = 10 * time.Minute
+)
+
+type CitiesPage struct {
+	Cities   []model.City `json:"cities"`
+	Page     int          `json:"page"`
+	PageSize int          `json:"page_size"`
+	Total    int          `json:"total"`
+}
+
+// citiesCache keeps all cities and their number of airports, used by the cities search
+type citiesCache struct {
+	mutex       sync.Mutex
+	cities      []model.City
+	numAirports map[int]int
+	expiration  time.Time
+}
+
+func (api *API) HandleSearchCities(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		api.searchCities(w, r)
+	default:
+		log.Println("Method not supported")
+		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+func (api *API) searchCities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		log.Println("Method not supported")
+		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// get request parameters
+	query := r.URL.Query().Get("q")
+	if internals.FoldText(query) == "" {
+		log.Println("Missing query")
+		http.Error(w, "Missing query", http.StatusBadRequest)
+		return
+	}
+	page := 0
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		var err error
+		page, err = strconv.Atoi(pageStr)
+		if err != nil || page < 0 {
+			log.Println("Invalid page")
+			http.Error(w, "Invalid page", http.StatusBadRequest)
+			return
+		}
+	}
+	pageSize := defaultCitiesPageSize
+	if pageSizeStr := r.URL.Query().Get("page_size"); pageSizeStr != "" {
+		var err error
+		pageSize, err = strconv.Atoi(pageSizeStr)
+		if err != nil || pageSize <= 0 || pageSize > maxCitiesPageSize {
+			log.Println("Invalid page size")
+			http.Error(w, "Invalid page size", http.StatusBadRequest)
+			return
+		}
+	}
+
+	cities, numAirports, err := api.getCachedCities()
+	if err != nil {
+		log.Println("Error getting cities: ", err)
+		http.Error(w, "Error getting cities", http.StatusInternalServerError)
+		return
+	}
+
+	// search and paginate
+	results := internals.SearchCities(cities, numAirports, query)
+	response := CitiesPage{
+		Cities:   []model.City{},
+		Page:     page,
+		PageSize: pageSize,
+		Total:    len(results),
+	}
+	if page*pageSize < len(results) {
+		end := min(page*pageSize+pageSize, len(results))
+		response.Cities = results[page*pageSize : end]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Println("Error encoding JSON: ", err)
+		http.Error(w, "Error encoding JSON", http.StatusInternalServerError)
+		return
+	}
+}
+
+// getCachedCities returns all cities and their number of airports, reading them from the db if the cache expired
+func (api *API) getCachedCities() ([]model.City, map[int]int, error) {
+	cache := &api.citiesCache
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if cache.cities != nil && time.Now().Before(cache.expiration) {
+		return cache.cities, cache.numAirports, nil
+	}
+
+	cities, err := api.repositories.Cities.GetCities()
+	if err != nil {
+		return nil, nil, err
+	}
+	numAirports, err := api.repositories.Cities.GetNumAirportsByCity()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cache.cities = cities
+	cache.numAirports = numAirports
+	cache.expiration = time.Now().Add(citiesCacheTTL)
+
+	return cache.cities, cache.numAirports, nil
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"encoding/json"
+	"green-journey-server/externals"
+	"green-journey-server/internals"
+	"green-journey-server/model"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// max number of stops of an itinerary, departure included
+const maxItineraryStops = 6
+
+// ItineraryOptions contains the options of every leg of a multi-city itinerary,
+// the i-th leg goes from the i-th stop to the next one
+type ItineraryOptions struct {
+	Legs []TravelOptions `json:"legs"`
+}
+
+// HandleSearchItinerary searches a multi-city itinerary, e.g. Milan, Vienna, Prague, Milan:
+// every leg is searched independently, with its own date
+func (api *API) HandleSearchItinerary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		log.Println("Method not supported")
+		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	start := time.Now()
+
+	// get request parameters
+	requests, ok := api.parseItineraryRequests(w, r)
+	if !ok {
+		return
+	}
+	filter, ok := parseTravelOptionsFilter(w, r)
+	if !ok {
+		return
+	}
+	currency, ok := api.parseCurrency(w, r)
+	if !ok {
+		return
+	}
+	sortBy := r.URL.Query().Get("sort")
+	if sortBy != "" && !internals.IsValidSortKey(sortBy) {
+		log.Println("Invalid sort value")
+		http.Error(w, "Invalid sort value", http.StatusBadRequest)
+		return
+	}
+
+	// search all legs concurrently
+	legs := make([]TravelOptions, len(requests))
+	conversionErrors := make([]error, len(requests))
+	var wg sync.WaitGroup
+	for i, request := range requests {
+		wg.Add(1)
+		go func(legIndex int, request externals.SearchRequest) {
+			defer wg.Done()
+
+			travelOptions, skippedProviders := computeApiData(r.Context(), request)
+
+			travelOptions, err := api.convertTravelOptions(r.Context(), travelOptions, currency)
+			if err != nil {
+				conversionErrors[legIndex] = err
+				return
+			}
+			travelOptions = internals.FilterTravelOptions(travelOptions, filter)
+			if sortBy != "" {
+				internals.SortTravelOptions(travelOptions, sortBy)
+			}
+			for j := range travelOptions {
+				for k := range travelOptions[j] {
+					travelOptions[j][k].LegIndex = legIndex
+				}
+			}
+
+			if skippedProviders == nil {
+				skippedProviders = []string{}
+			}
+			legs[legIndex] = TravelOptions{
+				Options:          travelOptions,
+				Summaries:        internals.ComputeTravelOptionSummaries(travelOptions),
+				SkippedProviders: skippedProviders,
+			}
+		}(i, request)
+	}
+	wg.Wait()
+
+	// if the client disconnected, nobody is waiting for the response
+	if r.Context().Err() != nil {
+		log.Println("Client disconnected during search: ", r.Context().Err())
+		return
+	}
+
+	for _, err := range conversionErrors {
+		if err != nil {
+			log.Println("Error converting prices: ", err)
+			http.Error(w, "Error converting prices", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	elapsed := time.Since(start)
+	log.Println("TOTAL SEARCH ITINERARY call took:", elapsed)
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(ItineraryOptions{Legs: legs})
+	if err != nil {
+		log.Println("Error encoding JSON: ", err)
+		http.Error(w, "Error encoding JSON", http.StatusInternalServerError)
+		return
+	}
+}
+
+// parseItineraryRequests reads the itinerary from the query string and returns the request of every leg:
+// stops are comma separated iata:country_code pairs, dates are comma separated, one for each leg,
+// time is the same for all legs. If some parameter is not valid, the error is written and false is returned
+func (api *API) parseItineraryRequests(w http.ResponseWriter, r *http.Request) ([]externals.SearchRequest, bool) {
+	// stops
+	stopsStr := r.URL.Query().Get("stops")
+	if stopsStr == "" {
+		log.Println("Missing stops")
+		http.Error(w, "Missing stops", http.StatusBadRequest)
+		return nil, false
+	}
+	stops := strings.Split(stopsStr, ",")
+	if len(stops) < 2 || len(stops) > maxItineraryStops {
+		log.Println("Invalid number of stops")
+		http.Error(w, "Invalid number of stops", http.StatusBadRequest)
+		return nil, false
+	}
+
+	// dates
+	datesStr := r.URL.Query().Get("dates")
+	if datesStr == "" {
+		log.Println("Missing dates")
+		http.Error(w, "Missing dates", http.StatusBadRequest)
+		return nil, false
+	}
+	dates := strings.Split(datesStr, ",")
+	if len(dates) != len(stops)-1 {
+		log.Println("Wrong number of dates")
+		http.Error(w, "A date is needed for every leg", http.StatusBadRequest)
+		return nil, false
+	}
+
+	// time
+	departureTime, err := time.Parse("15:04", r.URL.Query().Get("time"))
+	if err != nil {
+		log.Println("Wrong time format: ", err)
+		http.Error(w, "Wrong time format", http.StatusBadRequest)
+		return nil, false
+	}
+	departureTime = departureTime.UTC()
+
+	// car used by the search
+	vehicleProfile, passengers, ok := api.parseVehicleParams(w, r)
+	if !ok {
+		return nil, false
+	}
+	flightOptions, ok := parseFlightOptions(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	// get cities
+	cities := make([]model.City, len(stops))
+	for i, stop := range stops {
+		parts := strings.Split(strings.TrimSpace(stop), ":")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			log.Println("Invalid stop: ", stop)
+			http.Error(w, "Invalid stop format", http.StatusBadRequest)
+			return nil, false
+		}
+		city, err := api.repositories.Cities.GetCityByIataAndCountryCode(parts[0], parts[1])
+		if err != nil || city.CityIata == nil {
+			log.Println("Stop city not found: ", err)
+			http.Error(w, "Stop city not found", http.StatusBadRequest)
+			return nil, false
+		}
+		if i > 0 && city.CityID == cities[i-1].CityID {
+			log.Println("Consecutive stops are the same city")
+			http.Error(w, "Consecutive stops must be different cities", http.StatusBadRequest)
+			return nil, false
+		}
+		cities[i] = city
+	}
+
+	// build the request of every leg, legs are outward except the final return to the departure city
+	requests := make([]externals.SearchRequest, len(dates))
+	for i, dateStr := range dates {
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(dateStr))
+		if err != nil {
+			log.Println("Wrong date format: ", err)
+			http.Error(w, "Wrong date format", http.StatusBadRequest)
+			return nil, false
+		}
+		date = date.UTC()
+		if i > 0 && date.Before(requests[i-1].Date) {
+			log.Println("Dates not ordered")
+			http.Error(w, "Dates must be ordered", http.StatusBadRequest)
+			return nil, false
+		}
+
+		isLastLeg := i == len(dates)-1
+		requests[i] = externals.SearchRequest{
+			DepartureCity:   cities[i],
+			DestinationCity: cities[i+1],
+			Date:            date,
+			Time:            departureTime,
+			IsOutward:       !(isLastLeg && i > 0 && cities[i+1].CityID == cities[0].CityID),
+			VehicleProfile:  vehicleProfile,
+			Passengers:      passengers,
+			FlightOptions:   flightOptions,
+		}
+	}
+
+	return requests, true
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// default and max radius of nearby searches [km]
+	defaultNearbyRadius = 50
+	maxNearbyRadius     = 500
+	// default and max number of results of nearby searches
+	defaultNearbyLimit = 20
+	maxNearbyLimit     = 100
+)
+
+func (api *API) HandleNearbyCities(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		api.getNearbyCities(w, r)
+	default:
+		log.Println("Method not supported")
+		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+func (api *API) HandleNearbyAirports(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		api.getNearbyAirports(w, r)
+	default:
+		log.Println("Method not supported")
+		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+func (api *API) getNearbyCities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		log.Println("Method not supported")
+		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	latitude, longitude, radius, limit, ok := parseNearbyParams(w, r)
+	if !ok {
+		return
+	}
+
+	cities, err := api.repositories.Cities.GetNearbyCities(latitude, longitude, radius)
+	if err != nil {
+		log.Println("Error getting nearby cities: ", err)
+		http.Error(w, "Error getting nearby cities", http.StatusInternalServerError)
+		return
+	}
+	if len(cities) > limit {
+		cities = cities[:limit]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(cities)
+	if err != nil {
+		log.Println("Error encoding JSON: ", err)
+		http.Error(w, "Error encoding JSON", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (api *API) getNearbyAirports(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		log.Println("Method not supported")
+		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	latitude, longitude, radius, limit, ok := parseNearbyParams(w, r)
+	if !ok {
+		return
+	}
+
+	airports, err := api.repositories.Cities.GetNearbyAirports(latitude, longitude, radius)
+	if err != nil {
+		log.Println("Error getting nearby airports: ", err)
+		http.Error(w, "Error getting nearby airports", http.StatusInternalServerError)
+		return
+	}
+	if len(airports) > limit {
+		airports = airports[:limit]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(airports)
+	if err != nil {
+		log.Println("Error encoding JSON: ", err)
+		http.Error(w, "Error encoding JSON", http.StatusInternalServerError)
+		return
+	}
+}
+
+// parseNearbyParams reads point, radius and max number of results of a nearby search,
+// if some parameter is not valid, the error is written and false is returned
+func parseNearbyParams(w http.ResponseWriter, r *http.Request) (float64, float64, float64, int, bool) {
+	latitude, err := strconv.ParseFloat(r.URL.Query().Get("lat"), 64)
+	if err != nil || latitude < -90 || latitude > 90 {
+		log.Println("Invalid latitude")
+		http.Error(w, "Invalid latitude", http.StatusBadRequest)
+		return 0, 0, 0, 0, false
+	}
+	longitude, err := strconv.ParseFloat(r.URL.Query().Get("lng"), 64)
+	if err != nil || longitude < -180 || longitude > 180 {
+		log.Println("Invalid longitude")
+		http.Error(w, "Invalid longitude", http.StatusBadRequest)
+		return 0, 0, 0, 0, false
+	}
+
+	radius := float64(defaultNearbyRadius)
+	if radiusStr := r.URL.Query().Get("radius_km"); radiusStr != "" {
+		radius, err = strconv.ParseFloat(radiusStr, 64)
+		if err != nil || radius <= 0 || radius > maxNearbyRadius {
+			log.Println("Invalid radius")
+			http.Error(w, "Invalid radius", http.StatusBadRequest)
+			return 0, 0, 0, 0, false
+		}
+	}
+
+	limit := defaultNearbyLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > maxNearbyLimit {
+			log.Println("Invalid limit")
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return 0, 0, 0, 0, false
+		}
+	}
+
+	return latitude, longitude, radius, limit, true
+}
@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"encoding/json"
-	"green-journey-server/db"
 	"green-journey-server/model"
 	"log"
 	"net/http"
@@ -14,10 +13,10 @@ type RankingResponse struct {
 	LongDistanceRanking  []model.RankingElement `json:"long_distance_ranking"`
 }
 
-func HandleRanking(w http.ResponseWriter, r *http.Request) {
+func (api *API) HandleRanking(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "GET":
-		computeRanking(w, r)
+		api.computeRanking(w, r)
 	default:
 		log.Println("Method not supported")
 		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
@@ -25,7 +24,7 @@ func HandleRanking(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func computeRanking(w http.ResponseWriter, r *http.Request) {
+func (api *API) computeRanking(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
 		log.Println("Method not supported")
 		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
@@ -40,9 +39,7 @@ func computeRanking(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userDAO := db.NewUserDAO(db.GetDB())
-
-	_, err = userDAO.GetUserById(id)
+	_, err = api.repositories.Users.GetUserById(id)
 	if err != nil {
 		log.Println("User not found: ", err)
 		http.Error(w, "User could not be found", http.StatusNotFound)
@@ -50,14 +47,13 @@ func computeRanking(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// compute ranking
-	rankingDAO := db.NewRankingDAO(db.GetDB())
-	shortDistanceTopUsers, err := rankingDAO.ComputeShortDistanceRanking(id)
+	shortDistanceTopUsers, err := api.repositories.Ranking.ComputeShortDistanceRanking(id)
 	if err != nil {
 		log.Println("Error computing ranking: ", err)
 		http.Error(w, "Error computing ranking", http.StatusBadRequest)
 		return
 	}
-	longDistanceTopUsers, err := rankingDAO.ComputeLongDistanceRanking(id)
+	longDistanceTopUsers, err := api.repositories.Ranking.ComputeLongDistanceRanking(id)
 	if err != nil {
 		log.Println("Error computing ranking: ", err)
 		http.Error(w, "Error computing ranking", http.StatusBadRequest)
@@ -1,23 +1,21 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
-	"green-journey-server/db"
-	"green-journey-server/externals"
 	"green-journey-server/model"
+	"green-journey-server/policy"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
 )
 
-func HandleReviews(w http.ResponseWriter, r *http.Request) {
+func (api *API) HandleReviews(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "GET":
-		getReviewsForCity(w, r)
+		api.getReviewsForCity(w, r)
 	case "POST":
-		createReview(w, r)
+		api.createReview(w, r)
 	default:
 		log.Println("Method not supported")
 		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
@@ -25,7 +23,7 @@ func HandleReviews(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func getReviewsForCity(w http.ResponseWriter, r *http.Request) {
+func (api *API) getReviewsForCity(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
 		log.Println("Method not supported")
 		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
@@ -63,8 +61,7 @@ func getReviewsForCity(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cityDAO := db.NewCityDAO(db.GetDB())
-	city, err := cityDAO.GetCityByIataAndCountryCode(cityIata, countryCode)
+	city, err := api.repositories.Cities.GetCityByIataAndCountryCode(cityIata, countryCode)
 	if err != nil {
 		log.Println("Error getting city: ", err)
 		http.Error(w, "Error getting city", http.StatusBadRequest)
@@ -72,14 +69,13 @@ func getReviewsForCity(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// get reviews
-	reviewDAO := db.NewReviewDAO(db.GetDB())
 	var cityReviewElement model.CityReviewElement
 	if direction {
 		// next reviews
-		cityReviewElement, err = reviewDAO.GetNextReviews(city.CityID, reviewID)
+		cityReviewElement, err = api.repositories.Reviews.GetNextReviews(city.CityID, reviewID)
 	} else {
 		// previous reviews
-		cityReviewElement, err = reviewDAO.GetPreviousReviews(city.CityID, reviewID)
+		cityReviewElement, err = api.repositories.Reviews.GetPreviousReviews(city.CityID, reviewID)
 	}
 
 	if err != nil {
@@ -101,34 +97,22 @@ func getReviewsForCity(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func createReview(w http.ResponseWriter, r *http.Request) {
+func (api *API) createReview(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		log.Println("Method not supported")
 		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// get Firebase token
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-		log.Println("Missing or invalid auth header")
-		http.Error(w, "Missing or invalid auth header", http.StatusUnauthorized)
-		return
-	}
-	idToken := strings.TrimPrefix(authHeader, "Bearer ")
-
-	// verify Firebase token
-	ctx := context.Background()
-	firebaseUID, err := externals.VerifyFirebaseToken(ctx, idToken)
-	if err != nil {
-		log.Println("Unauthorized", err)
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	// get the user authenticated by the middleware
+	authUser, ok := requireUser(w, r)
+	if !ok {
 		return
 	}
 
 	// decode json data
 	var review model.Review
-	err = json.NewDecoder(r.Body).Decode(&review)
+	err := json.NewDecoder(r.Body).Decode(&review)
 	if err != nil {
 		log.Println("Error decoding JSON: ", err)
 		http.Error(w, "Invalid data format", http.StatusBadRequest)
@@ -141,19 +125,10 @@ func createReview(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
-	// get user
-	userDAO := db.NewUserDAO(db.GetDB())
-	user, err := userDAO.GetUserById(review.UserID)
+	// check the review is created for the authenticated user
+	err = policy.CanCreateFor(authUser, review.UserID)
 	if err != nil {
-		log.Println("Error getting user: ", err)
-		http.Error(w, "Error getting user", http.StatusNotFound)
-		return
-	}
-
-	// check matching firebaseUID
-	if user.FirebaseUID != firebaseUID {
-		log.Println("Unauthorized")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writePolicyError(w, err)
 		return
 	}
 
@@ -164,8 +139,7 @@ func createReview(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Invalid iata and country code", http.StatusBadRequest)
 			return
 		}
-		cityDAO := db.NewCityDAO(db.GetDB())
-		city, err1 := cityDAO.GetCityByIataAndCountryCode(review.CityIata, review.CountryCode)
+		city, err1 := api.repositories.Cities.GetCityByIataAndCountryCode(review.CityIata, review.CountryCode)
 		if err1 != nil {
 			log.Println("Invalid iata and country code")
 			http.Error(w, "Invalid iata and country code", http.StatusBadRequest)
@@ -194,8 +168,7 @@ func createReview(w http.ResponseWriter, r *http.Request) {
 	review.DateTime = review.DateTime.UTC()
 
 	// insert review in db
-	reviewDAO := db.NewReviewDAO(db.GetDB())
-	err = reviewDAO.CreateReview(&review)
+	err = api.repositories.Reviews.CreateReview(&review)
 	if err != nil {
 		log.Println("Error while interacting with the database: ", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -212,12 +185,12 @@ func createReview(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func HandleModifyReviews(w http.ResponseWriter, r *http.Request) {
+func (api *API) HandleModifyReviews(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "PUT":
-		modifyReview(w, r)
+		api.modifyReview(w, r)
 	case "DELETE":
-		deleteReview(w, r)
+		api.deleteReview(w, r)
 	default:
 		log.Println("Method not supported")
 		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
@@ -225,28 +198,16 @@ func HandleModifyReviews(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func modifyReview(w http.ResponseWriter, r *http.Request) {
+func (api *API) modifyReview(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "PUT" {
 		log.Println("Method not supported")
 		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// get Firebase token
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-		log.Println("Missing or invalid auth header")
-		http.Error(w, "Missing or invalid auth header", http.StatusUnauthorized)
-		return
-	}
-	idToken := strings.TrimPrefix(authHeader, "Bearer ")
-
-	// verify Firebase token
-	ctx := context.Background()
-	firebaseUID, err := externals.VerifyFirebaseToken(ctx, idToken)
-	if err != nil {
-		log.Println("Unauthorized", err)
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	// get the user authenticated by the middleware
+	authUser, ok := requireUser(w, r)
+	if !ok {
 		return
 	}
 
@@ -281,19 +242,17 @@ func modifyReview(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
-	// get user
-	userDAO := db.NewUserDAO(db.GetDB())
-	user, err := userDAO.GetUserById(review.UserID)
+	// get the stored review, it must be owned by the user
+	existingReview, err := api.policy.CanModifyReview(authUser, reviewID)
 	if err != nil {
-		log.Println("Error getting user: ", err)
-		http.Error(w, "Error getting user", http.StatusNotFound)
+		writePolicyError(w, err)
 		return
 	}
-
-	// check matching firebaseUID
-	if user.FirebaseUID != firebaseUID {
-		log.Println("Unauthorized")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	// owner and city can't change, the review id is the one in the path
+	review.ReviewID = reviewID
+	if review.UserID != existingReview.UserID || review.CityID != existingReview.CityID {
+		log.Println("Review owner and city can't be changed")
+		http.Error(w, "Review owner and city can't be changed", http.StatusBadRequest)
 		return
 	}
 
@@ -317,8 +276,7 @@ func modifyReview(w http.ResponseWriter, r *http.Request) {
 	review.DateTime = review.DateTime.UTC()
 
 	// update review in db
-	reviewDAO := db.NewReviewDAO(db.GetDB())
-	err = reviewDAO.UpdateReview(review)
+	err = api.repositories.Reviews.UpdateReview(review)
 	if err != nil {
 		log.Println("Error while interacting with db: ", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -335,28 +293,16 @@ func modifyReview(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func deleteReview(w http.ResponseWriter, r *http.Request) {
+func (api *API) deleteReview(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "DELETE" {
 		log.Println("Method not supported")
 		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// get Firebase token
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-		log.Println("Missing or invalid auth header")
-		http.Error(w, "Missing or invalid auth header", http.StatusUnauthorized)
-		return
-	}
-	idToken := strings.TrimPrefix(authHeader, "Bearer ")
-
-	// verify Firebase token
-	ctx := context.Background()
-	firebaseUID, err := externals.VerifyFirebaseToken(ctx, idToken)
-	if err != nil {
-		log.Println("Unauthorized", err)
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	// get the user authenticated by the middleware
+	authUser, ok := requireUser(w, r)
+	if !ok {
 		return
 	}
 
@@ -376,33 +322,15 @@ func deleteReview(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// get review
-	reviewDAO := db.NewReviewDAO(db.GetDB())
-	review, err := reviewDAO.GetReviewById(reviewID)
-	if err != nil {
-		log.Println("Review not found: ", err)
-		http.Error(w, "Review not found", http.StatusBadRequest)
-		return
-	}
-
-	// get user
-	userDAO := db.NewUserDAO(db.GetDB())
-	user, err := userDAO.GetUserById(review.UserID)
+	// check the review is owned by the user
+	_, err = api.policy.CanModifyReview(authUser, reviewID)
 	if err != nil {
-		log.Println("Error getting user: ", err)
-		http.Error(w, "Error getting user", http.StatusNotFound)
-		return
-	}
-
-	// check matching firebaseUID
-	if user.FirebaseUID != firebaseUID {
-		log.Println("Unauthorized")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writePolicyError(w, err)
 		return
 	}
 
 	// delete review
-	err = reviewDAO.DeleteReview(reviewID)
+	err = api.repositories.Reviews.DeleteReview(reviewID)
 	if err != nil {
 		log.Println("Error while interacting with the db: ", err)
 		http.Error(w, "Error while deleting user", http.StatusBadRequest)
@@ -410,9 +338,9 @@ func deleteReview(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func HandleFirstReviews(w http.ResponseWriter, r *http.Request) {
+func (api *API) HandleFirstReviews(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
-		getFirstReviews(w, r)
+		api.getFirstReviews(w, r)
 	} else {
 		log.Println("Method not supported")
 		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
@@ -420,7 +348,7 @@ func HandleFirstReviews(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func getFirstReviews(w http.ResponseWriter, r *http.Request) {
+func (api *API) getFirstReviews(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
 		log.Println("Method not supported")
 		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
@@ -440,16 +368,14 @@ func getFirstReviews(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cityDAO := db.NewCityDAO(db.GetDB())
-	city, err := cityDAO.GetCityByIataAndCountryCode(cityIata, countryCode)
+	city, err := api.repositories.Cities.GetCityByIataAndCountryCode(cityIata, countryCode)
 	if err != nil {
 		log.Println("Error getting city: ", err)
 		http.Error(w, "Error getting city", http.StatusBadRequest)
 		return
 	}
 
-	reviewDAO := db.NewReviewDAO(db.GetDB())
-	cityReviewElement, err := reviewDAO.GetFirstReviewsByCityID(city.CityID)
+	cityReviewElement, err := api.repositories.Reviews.GetFirstReviewsByCityID(city.CityID)
 	if err != nil {
 		log.Println("Error getting city review element: ", err)
 		http.Error(w, "Error getting city review element", http.StatusNotFound)
@@ -469,9 +395,9 @@ func getFirstReviews(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func HandleLastReviews(w http.ResponseWriter, r *http.Request) {
+func (api *API) HandleLastReviews(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
-		getLastReviews(w, r)
+		api.getLastReviews(w, r)
 	} else {
 		log.Println("Method not supported")
 		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
@@ -479,7 +405,7 @@ func HandleLastReviews(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func getLastReviews(w http.ResponseWriter, r *http.Request) {
+func (api *API) getLastReviews(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
 		log.Println("Method not supported")
 		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
@@ -499,16 +425,14 @@ func getLastReviews(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cityDAO := db.NewCityDAO(db.GetDB())
-	city, err := cityDAO.GetCityByIataAndCountryCode(cityIata, countryCode)
+	city, err := api.repositories.Cities.GetCityByIataAndCountryCode(cityIata, countryCode)
 	if err != nil {
 		log.Println("Error getting city: ", err)
 		http.Error(w, "Error getting city", http.StatusBadRequest)
 		return
 	}
 
-	reviewDAO := db.NewReviewDAO(db.GetDB())
-	cityReviewElement, err := reviewDAO.GetLastReviewsByCityID(city.CityID)
+	cityReviewElement, err := api.repositories.Reviews.GetLastReviewsByCityID(city.CityID)
 	if err != nil {
 		log.Println("Error getting city review element: ", err)
 		http.Error(w, "Error getting city review element", http.StatusNotFound)
@@ -528,9 +452,9 @@ func getLastReviews(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func HandleBestReviews(w http.ResponseWriter, r *http.Request) {
+func (api *API) HandleBestReviews(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
-		getBestReviews(w, r)
+		api.getBestReviews(w, r)
 	} else {
 		log.Println("Method not supported")
 		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
@@ -538,7 +462,7 @@ func HandleBestReviews(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func getBestReviews(w http.ResponseWriter, r *http.Request) {
+func (api *API) getBestReviews(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
 		log.Println("Method not supported")
 		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
@@ -548,8 +472,7 @@ func getBestReviews(w http.ResponseWriter, r *http.Request) {
 	// no authentication needed
 
 	// get best reviews
-	reviewDAO := db.NewReviewDAO(db.GetDB())
-	bestReviews, err := reviewDAO.GetBestReviews()
+	bestReviews, err := api.repositories.Reviews.GetBestReviews()
 	if err != nil {
 		log.Println("Error getting best reviews: ", err)
 		http.Error(w, "Error getting best reviews", http.StatusBadRequest)
@@ -0,0 +1,749 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"green-journey-server/externals"
+	"green-journey-server/internals"
+	"green-journey-server/model"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type TravelOptions struct {
+	Options [][]model.Segment `json:"options"`
+	// the i-th summary refers to the i-th option
+	Summaries        []model.TravelOptionSummary `json:"summaries"`
+	SkippedProviders []string                    `json:"skipped_providers"`
+}
+
+type RoundTripOptions struct {
+	Options          []model.RoundTripOption `json:"options"`
+	SkippedProviders []string                `json:"skipped_providers"`
+}
+
+// ProviderStatus describes how a provider behaved during a search
+type ProviderStatus struct {
+	Provider   string `json:"provider"`
+	Status     string `json:"status"`
+	LatencyMs  int64  `json:"latency_ms"`
+	NumOptions int    `json:"num_options"`
+}
+
+// SearchSummary is the last event of a streamed search,
+// the i-th option summary refers to the i-th option event
+type SearchSummary struct {
+	Providers []ProviderStatus            `json:"providers"`
+	Options   []model.TravelOptionSummary `json:"options"`
+}
+
+// max number of people sharing a car
+const maxPassengers = 9
+
+// provider status values
+const (
+	providerStatusOk      = "ok"
+	providerStatusError   = "error"
+	providerStatusTimeout = "timeout"
+)
+
+// providerResult is the outcome of the search of a single provider
+type providerResult struct {
+	provider         string
+	options          [][]model.Segment
+	roundTripOptions []model.RoundTripOption
+	err              error
+	timedOut         bool
+	latency          time.Duration
+}
+
+func (result providerResult) status() ProviderStatus {
+	status := providerStatusOk
+	if result.timedOut {
+		status = providerStatusTimeout
+	} else if result.err != nil {
+		status = providerStatusError
+	}
+	return ProviderStatus{
+		Provider:   result.provider,
+		Status:     status,
+		LatencyMs:  result.latency.Milliseconds(),
+		NumOptions: len(result.options) + len(result.roundTripOptions),
+	}
+}
+
+func (api *API) HandleSearchTravel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		log.Println("Method not supported")
+		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	start := time.Now()
+
+	// get request parameters
+	request, ok := api.parseSearchRequest(w, r, false)
+	if !ok {
+		return
+	}
+	filter, ok := parseTravelOptionsFilter(w, r)
+	if !ok {
+		return
+	}
+	currency, ok := api.parseCurrency(w, r)
+	if !ok {
+		return
+	}
+	sortBy := r.URL.Query().Get("sort")
+	if sortBy != "" && !internals.IsValidSortKey(sortBy) {
+		log.Println("Invalid sort value")
+		http.Error(w, "Invalid sort value", http.StatusBadRequest)
+		return
+	}
+
+	// call all apis and return data
+	// always retrieve outward data
+	travelOptions, skippedProviders := computeApiData(r.Context(), request)
+
+	// if the client disconnected, nobody is waiting for the response
+	if r.Context().Err() != nil {
+		log.Println("Client disconnected during search: ", r.Context().Err())
+		return
+	}
+
+	// convert prices, then filter and sort options
+	travelOptions, err := api.convertTravelOptions(r.Context(), travelOptions, currency)
+	if err != nil {
+		log.Println("Error converting prices: ", err)
+		http.Error(w, "Error converting prices", http.StatusInternalServerError)
+		return
+	}
+	travelOptions = internals.FilterTravelOptions(travelOptions, filter)
+	if sortBy != "" {
+		internals.SortTravelOptions(travelOptions, sortBy)
+	}
+
+	// build response
+	response := TravelOptions{
+		Options:          travelOptions,
+		SkippedProviders: skippedProviders,
+	}
+
+	if response.Options == nil {
+		response.Options = [][]model.Segment{}
+	}
+	if response.SkippedProviders == nil {
+		response.SkippedProviders = []string{}
+	}
+
+	for i, _ := range response.Options {
+		if response.Options[i] == nil {
+			response.Options[i] = []model.Segment{}
+		}
+	}
+
+	// compare options
+	response.Summaries = internals.ComputeTravelOptionSummaries(response.Options)
+
+	elapsed := time.Since(start)
+	log.Println("TOTAL SEARCH TRAVEL call took:", elapsed)
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Println("Error encoding JSON: ", err)
+		http.Error(w, "Error encoding JSON", http.StatusInternalServerError)
+		return
+	}
+}
+
+// HandleSearchTravelStream is the Server-Sent Events variant of HandleSearchTravel:
+// every option is sent as soon as its provider returns, then a summary event is sent
+func (api *API) HandleSearchTravelStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		log.Println("Method not supported")
+		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// the response writer must support flushing, to send events one by one
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		log.Println("Streaming not supported")
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// get request parameters, options are sent as soon as they are found, so they can't be sorted
+	request, ok := api.parseSearchRequest(w, r, false)
+	if !ok {
+		return
+	}
+	filter, ok := parseTravelOptionsFilter(w, r)
+	if !ok {
+		return
+	}
+	currency, ok := api.parseCurrency(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	summary := SearchSummary{Providers: []ProviderStatus{}}
+	var sentOptions [][]model.Segment
+	for result := range searchProviders(r.Context(), request) {
+		options, err := api.convertTravelOptions(r.Context(), result.options, currency)
+		if err != nil {
+			// the options of the provider can't be compared with the others
+			log.Println("Error converting prices: ", err)
+			result.err = err
+			options = nil
+		}
+		result.options = internals.FilterTravelOptions(options, filter)
+		for _, option := range result.options {
+			err := writeEvent(w, "option", option)
+			if err != nil {
+				// the client is not reading anymore, the context cancels the providers
+				log.Println("Error writing event: ", err)
+				return
+			}
+		}
+		flusher.Flush()
+
+		sentOptions = append(sentOptions, result.options...)
+		summary.Providers = append(summary.Providers, result.status())
+	}
+	// options can be compared only when all of them are known
+	summary.Options = internals.ComputeTravelOptionSummaries(sentOptions)
+
+	if r.Context().Err() != nil {
+		log.Println("Client disconnected during search: ", r.Context().Err())
+		return
+	}
+
+	err := writeEvent(w, "summary", summary)
+	if err != nil {
+		log.Println("Error writing event: ", err)
+		return
+	}
+	flusher.Flush()
+}
+
+// HandleSearchRoundTrip searches outward and return options in a single call,
+// returning them paired with their combined totals
+func (api *API) HandleSearchRoundTrip(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		log.Println("Method not supported")
+		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	start := time.Now()
+
+	// get request parameters
+	request, ok := api.parseSearchRequest(w, r, true)
+	if !ok {
+		return
+	}
+	currency, ok := api.parseCurrency(w, r)
+	if !ok {
+		return
+	}
+
+	var options []model.RoundTripOption
+	skippedProviders := []string{}
+	for result := range searchProvidersRoundTrip(r.Context(), request) {
+		if result.timedOut {
+			skippedProviders = append(skippedProviders, result.provider)
+			continue
+		}
+		options = append(options, result.roundTripOptions...)
+	}
+
+	// if the client disconnected, nobody is waiting for the response
+	if r.Context().Err() != nil {
+		log.Println("Client disconnected during search: ", r.Context().Err())
+		return
+	}
+
+	options, err := api.convertRoundTripOptions(r.Context(), options, currency)
+	if err != nil {
+		log.Println("Error converting prices: ", err)
+		http.Error(w, "Error converting prices", http.StatusInternalServerError)
+		return
+	}
+	if options == nil {
+		options = []model.RoundTripOption{}
+	}
+
+	elapsed := time.Since(start)
+	log.Println("TOTAL SEARCH ROUND TRIP call took:", elapsed)
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(RoundTripOptions{
+		Options:          options,
+		SkippedProviders: skippedProviders,
+	})
+	if err != nil {
+		log.Println("Error encoding JSON: ", err)
+		http.Error(w, "Error encoding JSON", http.StatusInternalServerError)
+		return
+	}
+}
+
+// writeEvent writes a single Server-Sent Event with JSON data
+func writeEvent(w http.ResponseWriter, event string, data interface{}) error {
+	encodedData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, encodedData)
+	return err
+}
+
+// parseSearchRequest reads the search parameters from the query string, round trip searches
+// have return date and time instead of is_outward: if some parameter is not valid,
+// the error is written and false is returned
+func (api *API) parseSearchRequest(w http.ResponseWriter, r *http.Request, isRoundTrip bool) (externals.SearchRequest, bool) {
+	// departure
+	iataDeparture := r.URL.Query().Get("iata_departure")
+	if iataDeparture == "" {
+		log.Println("Missing departure city iata")
+		http.Error(w, "Missing departure city iata", http.StatusBadRequest)
+		return externals.SearchRequest{}, false
+	}
+	countryCodeDeparture := r.URL.Query().Get("country_code_departure")
+	if countryCodeDeparture == "" {
+		log.Println("Missing departure country code")
+		http.Error(w, "Missing departure country code", http.StatusBadRequest)
+		return externals.SearchRequest{}, false
+	}
+
+	// destination
+	iataDestination := r.URL.Query().Get("iata_destination")
+	if iataDestination == "" {
+		log.Println("Missing destination city iata")
+		http.Error(w, "Missing destination city iata", http.StatusBadRequest)
+		return externals.SearchRequest{}, false
+	}
+	countryCodeDestination := r.URL.Query().Get("country_code_destination")
+	if countryCodeDestination == "" {
+		log.Println("Missing destination country code")
+		http.Error(w, "Missing destination country code", http.StatusBadRequest)
+		return externals.SearchRequest{}, false
+	}
+
+	// date
+	departureDate, err := time.Parse("2006-01-02", r.URL.Query().Get("date"))
+	if err != nil {
+		log.Println("Wrong date format: ", err)
+		http.Error(w, "Wrong date format", http.StatusBadRequest)
+		return externals.SearchRequest{}, false
+	}
+	departureTime, err := time.Parse("15:04", r.URL.Query().Get("time"))
+	if err != nil {
+		log.Println("Wrong time format: ", err)
+		http.Error(w, "Wrong time format", http.StatusBadRequest)
+		return externals.SearchRequest{}, false
+	}
+
+	isOutward := true
+	var returnDate, returnTime time.Time
+	if isRoundTrip {
+		// return date
+		returnDate, err = time.Parse("2006-01-02", r.URL.Query().Get("return_date"))
+		if err != nil {
+			log.Println("Wrong return date format: ", err)
+			http.Error(w, "Wrong return date format", http.StatusBadRequest)
+			return externals.SearchRequest{}, false
+		}
+		returnTime, err = time.Parse("15:04", r.URL.Query().Get("return_time"))
+		if err != nil {
+			log.Println("Wrong return time format: ", err)
+			http.Error(w, "Wrong return time format", http.StatusBadRequest)
+			return externals.SearchRequest{}, false
+		}
+		// check return after departure
+		departureDateTime := time.Date(departureDate.Year(), departureDate.Month(), departureDate.Day(), departureTime.Hour(), departureTime.Minute(), 0, 0, time.UTC)
+		returnDateTime := time.Date(returnDate.Year(), returnDate.Month(), returnDate.Day(), returnTime.Hour(), returnTime.Minute(), 0, 0, time.UTC)
+		if returnDateTime.Before(departureDateTime) {
+			log.Println("Return before departure")
+			http.Error(w, "Return date must follow departure date", http.StatusBadRequest)
+			return externals.SearchRequest{}, false
+		}
+	} else {
+		// outward or not
+		isOutward, err = strconv.ParseBool(r.URL.Query().Get("is_outward"))
+		if err != nil {
+			log.Println("Wrong isOutward format: ", err)
+			http.Error(w, "Wrong isOutward format", http.StatusBadRequest)
+			return externals.SearchRequest{}, false
+		}
+	}
+
+	// get departure city
+	departureCity, err := api.repositories.Cities.GetCityByIataAndCountryCode(iataDeparture, countryCodeDeparture)
+	if err != nil || departureCity.CityIata == nil {
+		log.Println("Departure city not found: ", err)
+		http.Error(w, "Departure city not found", http.StatusBadRequest)
+		return externals.SearchRequest{}, false
+	}
+	// get destination city
+	destinationCity, err := api.repositories.Cities.GetCityByIataAndCountryCode(iataDestination, countryCodeDestination)
+	if err != nil || destinationCity.CityIata == nil {
+		log.Println("Destination city not found: ", err)
+		http.Error(w, "Destination city not found", http.StatusBadRequest)
+		return externals.SearchRequest{}, false
+	}
+
+	// car used by the search
+	vehicleProfile, passengers, ok := api.parseVehicleParams(w, r)
+	if !ok {
+		return externals.SearchRequest{}, false
+	}
+	flightOptions, ok := parseFlightOptions(w, r)
+	if !ok {
+		return externals.SearchRequest{}, false
+	}
+
+	// convert date and time to UTC
+	departureDate = departureDate.UTC()
+	departureTime = departureTime.UTC()
+	returnDate = returnDate.UTC()
+	returnTime = returnTime.UTC()
+
+	return externals.SearchRequest{
+		DepartureCity:   departureCity,
+		DestinationCity: destinationCity,
+		Date:            departureDate,
+		Time:            departureTime,
+		IsOutward:       isOutward,
+		VehicleProfile:  vehicleProfile,
+		Passengers:      passengers,
+		FlightOptions:   flightOptions,
+		ReturnDate:      returnDate,
+		ReturnTime:      returnTime,
+	}, true
+}
+
+// parseCurrency reads the optional currency of the prices, EUR by default:
+// if the currency is not supported, the error is written and false is returned
+func (api *API) parseCurrency(w http.ResponseWriter, r *http.Request) (string, bool) {
+	currency := strings.ToUpper(r.URL.Query().Get("currency"))
+	if currency == "" || currency == externals.DefaultCurrency {
+		return externals.DefaultCurrency, true
+	}
+	if len(currency) != 3 {
+		log.Println("Invalid currency")
+		http.Error(w, "Invalid currency", http.StatusBadRequest)
+		return "", false
+	}
+
+	rates, err := api.priceApis.GetExchangeRates(r.Context())
+	if err != nil {
+		log.Println("Error getting exchange rates: ", err)
+		http.Error(w, "Error getting exchange rates", http.StatusInternalServerError)
+		return "", false
+	}
+	if _, ok := rates[currency]; !ok {
+		log.Println("Currency not supported: ", currency)
+		http.Error(w, "Currency not supported", http.StatusBadRequest)
+		return "", false
+	}
+
+	return currency, true
+}
+
+// convertTravelOptions returns a copy of the options with prices in currency, options are not modified
+// because they can be cached. If an option is in a currency without exchange rate, an error is returned
+func (api *API) convertTravelOptions(ctx context.Context, options [][]model.Segment, currency string) ([][]model.Segment, error) {
+	needsConversion := false
+	for _, option := range options {
+		if internals.NeedsCurrencyConversion(option, currency) {
+			needsConversion = true
+			break
+		}
+	}
+	if !needsConversion {
+		return options, nil
+	}
+
+	rates, err := api.priceApis.GetExchangeRates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var convertedOptions [][]model.Segment
+	for _, option := range options {
+		convertedOption := make([]model.Segment, len(option))
+		copy(convertedOption, option)
+		err = internals.ConvertSegmentsCurrency(convertedOption, currency, rates)
+		if err != nil {
+			return nil, err
+		}
+		convertedOptions = append(convertedOptions, convertedOption)
+	}
+	return convertedOptions, nil
+}
+
+// convertRoundTripOptions is the round trip version of convertTravelOptions, totals are computed again
+func (api *API) convertRoundTripOptions(ctx context.Context, options []model.RoundTripOption, currency string) ([]model.RoundTripOption, error) {
+	var convertedOptions []model.RoundTripOption
+	for _, option := range options {
+		converted, err := api.convertTravelOptions(ctx, [][]model.Segment{option.Outward, option.Return}, currency)
+		if err != nil {
+			return nil, err
+		}
+		convertedOptions = append(convertedOptions, internals.ComputeRoundTripOption(converted[0], converted[1]))
+	}
+	return convertedOptions, nil
+}
+
+// parseFlightOptions reads the optional cabin class and radiative forcing of a search,
+// if some parameter is not valid, the error is written and false is returned
+func parseFlightOptions(w http.ResponseWriter, r *http.Request) (externals.FlightOptions, bool) {
+	var flightOptions externals.FlightOptions
+
+	// cabin class
+	if cabinClass := r.URL.Query().Get("cabin_class"); cabinClass != "" {
+		if !internals.IsValidCabinClass(cabinClass) {
+			log.Println("Invalid cabin class")
+			http.Error(w, "Invalid cabin class", http.StatusBadRequest)
+			return externals.FlightOptions{}, false
+		}
+		flightOptions.CabinClass = cabinClass
+	}
+	// radiative forcing
+	if radiativeForcingStr := r.URL.Query().Get("radiative_forcing"); radiativeForcingStr != "" {
+		radiativeForcing, err := strconv.ParseBool(radiativeForcingStr)
+		if err != nil {
+			log.Println("Wrong radiative forcing format: ", err)
+			http.Error(w, "Wrong radiative forcing format", http.StatusBadRequest)
+			return externals.FlightOptions{}, false
+		}
+		flightOptions.RadiativeForcing = radiativeForcing
+	}
+
+	return flightOptions, true
+}
+
+// parseVehicleParams reads the optional vehicle profile and number of passengers of a search: a vehicle profile
+// can be used only by its owner, so the request must carry a valid token. If some parameter is not valid,
+// the error is written and false is returned
+func (api *API) parseVehicleParams(w http.ResponseWriter, r *http.Request) (*model.VehicleProfile, int, bool) {
+	// passengers
+	passengers := 1
+	if passengersStr := r.URL.Query().Get("passengers"); passengersStr != "" {
+		var err error
+		passengers, err = strconv.Atoi(passengersStr)
+		if err != nil || passengers < 1 || passengers > maxPassengers {
+			log.Println("Invalid number of passengers")
+			http.Error(w, "Invalid number of passengers", http.StatusBadRequest)
+			return nil, 0, false
+		}
+	}
+
+	// vehicle profile
+	vehicleProfileIDStr := r.URL.Query().Get("vehicle_profile_id")
+	if vehicleProfileIDStr == "" {
+		return nil, passengers, true
+	}
+	vehicleProfileID, err := strconv.Atoi(vehicleProfileIDStr)
+	if err != nil || vehicleProfileID < 0 {
+		log.Println("Invalid vehicle profile id")
+		http.Error(w, "Invalid vehicle profile id", http.StatusBadRequest)
+		return nil, 0, false
+	}
+
+	// the search is public, the user is resolved by the middleware if a token is sent
+	user, ok := UserFromContext(r.Context())
+	if !ok {
+		log.Println("Missing or invalid auth header")
+		http.Error(w, "Vehicle profiles require authentication", http.StatusUnauthorized)
+		return nil, 0, false
+	}
+
+	// get vehicle profile, only the owner can use it
+	vehicleProfile, err := api.policy.CanUseVehicleProfile(user, vehicleProfileID)
+	if err != nil {
+		writePolicyError(w, err)
+		return nil, 0, false
+	}
+
+	if passengers > vehicleProfile.Seats {
+		log.Println("Too many passengers for the vehicle")
+		http.Error(w, "Too many passengers for the vehicle", http.StatusBadRequest)
+		return nil, 0, false
+	}
+
+	return &vehicleProfile, passengers, true
+}
+
+// parseTravelOptionsFilter reads the optional filter parameters from the query string,
+// if some parameter is not valid, the error is written and false is returned
+func parseTravelOptionsFilter(w http.ResponseWriter, r *http.Request) (internals.TravelOptionsFilter, bool) {
+	var filter internals.TravelOptionsFilter
+
+	// max price
+	if maxPriceStr := r.URL.Query().Get("max_price"); maxPriceStr != "" {
+		maxPrice, err := strconv.ParseFloat(maxPriceStr, 64)
+		if err != nil || maxPrice < 0 {
+			log.Println("Invalid max price")
+			http.Error(w, "Invalid max price", http.StatusBadRequest)
+			return internals.TravelOptionsFilter{}, false
+		}
+		filter.MaxPrice = &maxPrice
+	}
+	// max co2
+	if maxCO2Str := r.URL.Query().Get("max_co2"); maxCO2Str != "" {
+		maxCO2, err := strconv.ParseFloat(maxCO2Str, 64)
+		if err != nil || maxCO2 < 0 {
+			log.Println("Invalid max CO2")
+			http.Error(w, "Invalid max CO2", http.StatusBadRequest)
+			return internals.TravelOptionsFilter{}, false
+		}
+		filter.MaxCO2 = &maxCO2
+	}
+	// max duration, in minutes
+	if maxDurationStr := r.URL.Query().Get("max_duration"); maxDurationStr != "" {
+		maxDurationMinutes, err := strconv.Atoi(maxDurationStr)
+		if err != nil || maxDurationMinutes < 0 {
+			log.Println("Invalid max duration")
+			http.Error(w, "Invalid max duration", http.StatusBadRequest)
+			return internals.TravelOptionsFilter{}, false
+		}
+		maxDuration := time.Duration(maxDurationMinutes) * time.Minute
+		filter.MaxDuration = &maxDuration
+	}
+	// max transfers
+	if maxTransfersStr := r.URL.Query().Get("max_transfers"); maxTransfersStr != "" {
+		maxTransfers, err := strconv.Atoi(maxTransfersStr)
+		if err != nil || maxTransfers < 0 {
+			log.Println("Invalid max transfers")
+			http.Error(w, "Invalid max transfers", http.StatusBadRequest)
+			return internals.TravelOptionsFilter{}, false
+		}
+		filter.MaxTransfers = &maxTransfers
+	}
+	// allowed vehicles, comma separated
+	if vehiclesStr := r.URL.Query().Get("vehicles"); vehiclesStr != "" {
+		for _, vehicle := range strings.Split(vehiclesStr, ",") {
+			vehicle = strings.TrimSpace(vehicle)
+			if vehicle != "car" &&
+				vehicle != "bike" &&
+				vehicle != "plane" &&
+				vehicle != "train" &&
+				vehicle != "bus" {
+				log.Println("Invalid vehicle: ", vehicle)
+				http.Error(w, "Invalid vehicle type", http.StatusBadRequest)
+				return internals.TravelOptionsFilter{}, false
+			}
+			filter.Vehicles = append(filter.Vehicles, vehicle)
+		}
+	}
+
+	return filter, true
+}
+
+// computeApiData searches the options of all registered providers, each provider has its own deadline:
+// the providers that don't answer in time are skipped and their names are returned
+func computeApiData(ctx context.Context, request externals.SearchRequest) ([][]model.Segment, []string) {
+	var apiData [][]model.Segment
+	var skippedProviders []string
+
+	for result := range searchProviders(ctx, request) {
+		if result.timedOut {
+			skippedProviders = append(skippedProviders, result.provider)
+			continue
+		}
+		apiData = append(apiData, result.options...)
+	}
+
+	return apiData, skippedProviders
+}
+
+// searchProviders runs the search of every registered provider in its own go routine,
+// the results are sent on the returned channel as soon as each provider returns
+func searchProviders(ctx context.Context, request externals.SearchRequest) <-chan providerResult {
+	return runProviders(ctx, func(ctx context.Context, provider externals.TravelProvider) providerResult {
+		options, err := provider.Search(ctx, request)
+
+		var result providerResult
+		result.err = err
+		for i := range options {
+			if options[i] != nil {
+				result.options = append(result.options, options[i])
+			}
+		}
+		return result
+	})
+}
+
+// searchProvidersRoundTrip is the round trip version of searchProviders
+func searchProvidersRoundTrip(ctx context.Context, request externals.SearchRequest) <-chan providerResult {
+	return runProviders(ctx, func(ctx context.Context, provider externals.TravelProvider) providerResult {
+		roundTripOptions, err := externals.SearchRoundTrip(ctx, provider, request)
+		return providerResult{
+			roundTripOptions: roundTripOptions,
+			err:              err,
+		}
+	})
+}
+
+// runProviders calls search for every registered provider in its own go routine, with the provider deadline
+func runProviders(ctx context.Context, search func(context.Context, externals.TravelProvider) providerResult) <-chan providerResult {
+	var wg sync.WaitGroup
+
+	providers := externals.GetTravelProviders()
+	results := make(chan providerResult, len(providers))
+
+	for _, provider := range providers {
+		wg.Add(1)
+		go func(provider externals.TravelProvider) {
+			defer wg.Done()
+
+			// the provider context is cancelled if the client disconnects or the deadline expires
+			providerCtx, cancel := context.WithTimeout(ctx, externals.GetTravelProviderTimeout(provider.Name()))
+			defer cancel()
+
+			start := time.Now()
+			result := search(providerCtx, provider)
+			result.provider = provider.Name()
+			result.latency = time.Since(start)
+
+			if errors.Is(providerCtx.Err(), context.DeadlineExceeded) {
+				log.Println("Provider", provider.Name(), "timed out")
+				result = providerResult{
+					provider: provider.Name(),
+					err:      providerCtx.Err(),
+					timedOut: true,
+					latency:  result.latency,
+				}
+			} else if result.err != nil {
+				log.Println("Provider", provider.Name(), "returned an error: ", result.err)
+				result.options = nil
+				result.roundTripOptions = nil
+			}
+
+			results <- result
+		}(provider)
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
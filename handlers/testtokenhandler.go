@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"green-journey-server/externals"
+	"log"
+	"net/http"
+)
+
+type TestToken struct {
+	Token string `json:"token"`
+}
+
+func HandleTestToken(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "POST":
+		mintTestToken(w, r)
+	default:
+		log.Println("Method not supported")
+		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+// mintTestToken returns a token for the uid in the query string, only in test mode
+func mintTestToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		log.Println("Method not supported")
+		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// tokens can be minted only by the test issuer
+	testIssuer, ok := externals.GetAuthVerifier().(*externals.TestIssuer)
+	if !ok {
+		log.Println("Test tokens are only available in test mode")
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	uid := r.URL.Query().Get("uid")
+	if uid == "" {
+		log.Println("Missing uid")
+		http.Error(w, "Missing uid", http.StatusBadRequest)
+		return
+	}
+
+	token, err := testIssuer.MintToken(uid)
+	if err != nil {
+		log.Println("Error minting token: ", err)
+		http.Error(w, "Error minting token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(TestToken{Token: token})
+	if err != nil {
+		log.Println("Error encoding JSON: ", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+}
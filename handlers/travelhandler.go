@@ -1,200 +1,37 @@
 package handlers
 
 import (
-	"context"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
-	"gorm.io/gorm"
 	"green-journey-server/db"
 	"green-journey-server/externals"
 	"green-journey-server/internals"
 	"green-journey-server/model"
+	"green-journey-server/policy"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 )
 
-type TravelOptions struct {
-	Options [][]model.Segment `json:"options"`
-}
-
-func HandleSearchTravel(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "GET" {
-		log.Println("Method not supported")
-		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
-		return
-	}
-
-	start := time.Now()
-
-	// get request parameters
-
-	// departure
-	iataDeparture := r.URL.Query().Get("iata_departure")
-	if iataDeparture == "" {
-		log.Println("Missing departure city iata")
-		http.Error(w, "Missing departure city iata", http.StatusBadRequest)
-		return
-	}
-	countryCodeDeparture := r.URL.Query().Get("country_code_departure")
-	if countryCodeDeparture == "" {
-		log.Println("Missing departure country code")
-		http.Error(w, "Missing departure country code", http.StatusBadRequest)
-		return
-	}
-
-	// destination
-	iataDestination := r.URL.Query().Get("iata_destination")
-	if iataDestination == "" {
-		log.Println("Missing destination city iata")
-		http.Error(w, "Missing destination city iata", http.StatusBadRequest)
-		return
-	}
-	countryCodeDestination := r.URL.Query().Get("country_code_destination")
-	if countryCodeDestination == "" {
-		log.Println("Missing destination country code")
-		http.Error(w, "Missing destination country code", http.StatusBadRequest)
-		return
-	}
+// retries with the same idempotency key return the first response until the key expires
+const idempotencyKeyTTL = 24 * time.Hour
 
-	// date
-	departureDate, err := time.Parse("2006-01-02", r.URL.Query().Get("date"))
-	if err != nil {
-		log.Println("Wrong date format: ", err)
-		http.Error(w, "Wrong date format", http.StatusBadRequest)
-		return
-	}
-	departureTime, err := time.Parse("15:04", r.URL.Query().Get("time"))
-	if err != nil {
-		log.Println("Wrong time format: ", err)
-		http.Error(w, "Wrong time format", http.StatusBadRequest)
-		return
-	}
-
-	// outward or not
-	isOutward, err := strconv.ParseBool(r.URL.Query().Get("is_outward"))
-	if err != nil {
-		log.Println("Wrong isOutward format: ", err)
-		http.Error(w, "Wrong isOutward format", http.StatusBadRequest)
-		return
-	}
-
-	// get departure city
-	cityDAO := db.NewCityDAO(db.GetDB())
-	departureCity, err := cityDAO.GetCityByIataAndCountryCode(iataDeparture, countryCodeDeparture)
-	if err != nil || departureCity.CityIata == nil {
-		log.Println("Departure city not found: ", err)
-		http.Error(w, "Departure city not found", http.StatusBadRequest)
-		return
-	}
-	// get destination city
-	destinationCity, err := cityDAO.GetCityByIataAndCountryCode(iataDestination, countryCodeDestination)
-	if err != nil || destinationCity.CityIata == nil {
-		log.Println("Destination city not found: ", err)
-		http.Error(w, "Destination city not found", http.StatusBadRequest)
-		return
-	}
-
-	// convert date and time to UTC
-	departureDate = departureDate.UTC()
-	departureTime = departureTime.UTC()
-
-	// call all apis and return data
-	// always retrieve outward data
-	travelOptions := computeApiData(departureCity, destinationCity, departureDate, departureTime, isOutward)
-
-	// build response
-	response := TravelOptions{
-		Options: travelOptions,
-	}
+// max length of an idempotency key
+const maxIdempotencyKeyLength = 255
 
-	if response.Options == nil {
-		response.Options = [][]model.Segment{}
-	}
-
-	for i, _ := range response.Options {
-		if response.Options[i] == nil {
-			response.Options[i] = []model.Segment{}
-		}
-	}
-
-	elapsed := time.Since(start)
-	log.Println("TOTAL SEARCH TRAVEL call took:", elapsed)
-
-	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(response)
-	if err != nil {
-		log.Println("Error encoding JSON: ", err)
-		http.Error(w, "Error encoding JSON", http.StatusInternalServerError)
-		return
-	}
-}
-
-func computeApiData(departureCity, destinationCity model.City, date, t time.Time, isOutward bool) [][]model.Segment {
-	var apiData [][]model.Segment
-	var wg sync.WaitGroup
-	results := make(chan []model.Segment, 5)
-
-	amadeusCallAPI := func(fetchFunc func(model.City, model.City, time.Time, time.Time, bool) ([][]model.Segment, error)) {
-		defer wg.Done()
-		directions, err := fetchFunc(departureCity, destinationCity, date, t, isOutward)
-		if err == nil && directions != nil {
-			for i := range directions {
-				if directions[i] != nil {
-					results <- directions[i]
-				}
-			}
-		}
-	}
-
-	googleMapsCallAPI := func(fetchFunc func(model.City, model.City, time.Time, time.Time, bool) ([]model.Segment, error)) {
-		defer wg.Done()
-		directions, err := fetchFunc(departureCity, destinationCity, date, t, isOutward)
-		if err == nil && directions != nil {
-			results <- directions
-		}
-	}
-
-	wg.Add(1)
-	go amadeusCallAPI(func(dep, dest model.City, d, t time.Time, out bool) ([][]model.Segment, error) {
-		return externals.GetFlights(dep, dest, d, t, out)
-	})
-
-	wg.Add(1)
-	go googleMapsCallAPI(externals.GetDirectionsBike)
-
-	wg.Add(1)
-	go googleMapsCallAPI(externals.GetDirectionsCar)
-
-	wg.Add(1)
-	go googleMapsCallAPI(externals.GetDirectionsTrain)
-
-	wg.Add(1)
-	go googleMapsCallAPI(externals.GetDirectionsBus)
-
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
-
-	for res := range results {
-		apiData = append(apiData, res)
-	}
-
-	return apiData
-}
-
-func HandleTravelsUser(w http.ResponseWriter, r *http.Request) {
+func (api *API) HandleTravelsUser(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "GET":
-		getTravelsByUserId(w, r)
+		api.getTravelsByUserId(w, r)
 	case "POST":
-		createTravel(w, r)
+		api.createTravel(w, r)
 	case "PUT":
-		modifyTravel(w, r)
+		api.modifyTravel(w, r)
 	default:
 		log.Println("Method not supported")
 		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
@@ -202,44 +39,29 @@ func HandleTravelsUser(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func getTravelsByUserId(w http.ResponseWriter, r *http.Request) {
+func (api *API) getTravelsByUserId(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
 		log.Println("Method not supported")
 		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// get Firebase token
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-		log.Println("Missing or invalid auth header")
-		http.Error(w, "Missing or invalid auth header", http.StatusUnauthorized)
+	// get the user authenticated by the middleware
+	ctx := r.Context()
+	user, ok := requireUser(w, r)
+	if !ok {
 		return
 	}
-	idToken := strings.TrimPrefix(authHeader, "Bearer ")
 
-	// verify Firebase token
-	ctx := context.Background()
-	firebaseUID, err := externals.VerifyFirebaseToken(ctx, idToken)
-	if err != nil {
-		log.Println("Unauthorized", err)
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	// currency of the prices
+	currency, ok := api.parseCurrency(w, r)
+	if !ok {
 		return
 	}
 
-	userDAO := db.NewUserDAO(db.GetDB())
-	user, err := userDAO.GetUserByFirebaseUID(firebaseUID)
-	if err != nil {
-		log.Println("User not found: ", err)
-		http.Error(w, "User could not be found", http.StatusNotFound)
-		return
-	}
-
-	travelDAO := db.NewTravelDAO(db.GetDB())
-
-	travels, err := travelDAO.GetTravelRequestsByUserId(user.UserID)
+	travels, err := api.repositories.Travels.GetTravelRequestsByUserId(user.UserID)
 	if err != nil {
-		if !errors.Is(err, gorm.ErrRecordNotFound) {
+		if !errors.Is(err, db.ErrRecordNotFound) {
 			log.Println("Error getting travels: ", err)
 			http.Error(w, "Error getting travels", http.StatusNotFound)
 			return
@@ -255,6 +77,17 @@ func getTravelsByUserId(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// segments are stored in the currency they were found in
+	for i, _ := range travels {
+		segments, err := api.convertTravelOptions(ctx, [][]model.Segment{travels[i].Segments}, currency)
+		if err != nil {
+			log.Println("Error converting prices: ", err)
+			http.Error(w, "Error converting prices", http.StatusInternalServerError)
+			return
+		}
+		travels[i].Segments = segments[0]
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(travels)
 	if err != nil {
@@ -264,34 +97,23 @@ func getTravelsByUserId(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func createTravel(w http.ResponseWriter, r *http.Request) {
+func (api *API) createTravel(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		log.Println("Method not supported")
 		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// get Firebase token
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-		log.Println("Missing or invalid auth header")
-		http.Error(w, "Missing or invalid auth header", http.StatusUnauthorized)
-		return
-	}
-	idToken := strings.TrimPrefix(authHeader, "Bearer ")
-
-	// verify Firebase token
-	ctx := context.Background()
-	firebaseUID, err := externals.VerifyFirebaseToken(ctx, idToken)
-	if err != nil {
-		log.Println("Unauthorized", err)
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	// get the user authenticated by the middleware
+	ctx := r.Context()
+	user, ok := requireUser(w, r)
+	if !ok {
 		return
 	}
 
 	// decode json data
 	var travelDetails model.TravelDetails
-	err = json.NewDecoder(r.Body).Decode(&travelDetails)
+	err := json.NewDecoder(r.Body).Decode(&travelDetails)
 	if err != nil {
 		log.Println("Error decoding JSON: ", err)
 		http.Error(w, "Invalid data format", http.StatusBadRequest)
@@ -304,20 +126,75 @@ func createTravel(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
-	// check matching firebaseUID
-	userDAO := db.NewUserDAO(db.GetDB())
-	user, err := userDAO.GetUserById(travelDetails.Travel.UserID)
+	// hash of the decoded payload, formatting differences don't change it
+	encodedTravelDetails, err := json.Marshal(travelDetails)
 	if err != nil {
-		log.Println("User not found", err)
-		http.Error(w, "User not found", http.StatusBadRequest)
+		log.Println("Error encoding JSON: ", err)
+		http.Error(w, "Invalid data format", http.StatusBadRequest)
 		return
 	}
-	if user.FirebaseUID != firebaseUID {
-		log.Println("Unauthorized", err)
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	requestHashBytes := sha256.Sum256(encodedTravelDetails)
+	requestHash := hex.EncodeToString(requestHashBytes[:])
+
+	// check the travel is created for the authenticated user
+	err = policy.CanCreateFor(user, travelDetails.Travel.UserID)
+	if err != nil {
+		writePolicyError(w, err)
 		return
 	}
 
+	// retries of a request with idempotency key return the first response
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+	var reservedKey *model.IdempotencyKey
+	if idempotencyKey != "" {
+		if len(idempotencyKey) > maxIdempotencyKeyLength {
+			log.Println("Invalid idempotency key")
+			http.Error(w, "Invalid idempotency key", http.StatusBadRequest)
+			return
+		}
+		reservedKey = &model.IdempotencyKey{
+			UserID:      user.UserID,
+			Key:         idempotencyKey,
+			RequestHash: requestHash,
+			ExpiresAt:   time.Now().UTC().Add(idempotencyKeyTTL),
+		}
+		existingKey, err := api.repositories.IdempotencyKeys.ReserveIdempotencyKey(reservedKey)
+		if err != nil {
+			log.Println("Error while interacting with the database: ", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if existingKey != nil {
+			if existingKey.RequestHash != requestHash {
+				log.Println("Idempotency key reused with a different payload")
+				http.Error(w, "Idempotency key already used with a different payload", http.StatusConflict)
+				return
+			}
+			if existingKey.StatusCode == 0 {
+				log.Println("Request with idempotency key in progress")
+				http.Error(w, "A request with the same idempotency key is in progress", http.StatusConflict)
+				return
+			}
+			// send the original response
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(existingKey.StatusCode)
+			_, err = w.Write(existingKey.ResponseBody)
+			if err != nil {
+				log.Println("Error writing response: ", err)
+			}
+			return
+		}
+		// if the travel is not created, the key is released and the request can be retried
+		defer func() {
+			if reservedKey.StatusCode == 0 {
+				err := api.repositories.IdempotencyKeys.DeleteIdempotencyKey(reservedKey.IdempotencyKeyID)
+				if err != nil {
+					log.Println("Error releasing idempotency key: ", err)
+				}
+			}
+		}()
+	}
+
 	// check travel data
 	// check co2 compensated
 	if travelDetails.Travel.CO2Compensated != 0 {
@@ -333,17 +210,35 @@ func createTravel(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// check segments data
-	cityDAO := db.NewCityDAO(db.GetDB())
+	for i, _ := range travelDetails.Segments {
+		// segments without currency are in EUR
+		if travelDetails.Segments[i].Currency == "" {
+			travelDetails.Segments[i].Currency = externals.DefaultCurrency
+		}
+		if travelDetails.Segments[i].Currency != externals.DefaultCurrency {
+			rates, err1 := api.priceApis.GetExchangeRates(ctx)
+			if err1 != nil {
+				log.Println("Error getting exchange rates: ", err1)
+				http.Error(w, "Error getting exchange rates", http.StatusInternalServerError)
+				return
+			}
+			if _, ok := rates[travelDetails.Segments[i].Currency]; !ok {
+				log.Println("Invalid currency")
+				http.Error(w, "Invalid currency", http.StatusBadRequest)
+				return
+			}
+		}
+	}
 	for _, segment := range travelDetails.Segments {
 		if segment.Vehicle != "walk" {
 			// check existing departure and destination cities
-			_, err1 := cityDAO.GetCityById(segment.DepartureId)
+			_, err1 := api.repositories.Cities.GetCityById(segment.DepartureId)
 			if err1 != nil {
 				log.Println("Invalid departure city id")
 				http.Error(w, "Invalid departure city id", http.StatusBadRequest)
 				return
 			}
-			_, err1 = cityDAO.GetCityById(segment.DestinationId)
+			_, err1 = api.repositories.Cities.GetCityById(segment.DestinationId)
 			if err1 != nil {
 				log.Println("Invalid destination city id")
 				http.Error(w, "Invalid destination city id", http.StatusBadRequest)
@@ -387,29 +282,32 @@ func createTravel(w http.ResponseWriter, r *http.Request) {
 		// travel id is fake, will be set later
 	}
 
-	// check NumSegment (ordered outward segments, followed by ordered return segments)
-	numOutwardSegments := -1
+	// check leg index and NumSegment (segments ordered by leg, ordered segments in every leg):
+	// travels without leg indexes are outward and return travels, the return is the second leg
+	travelDetails.SetLegacyLegIndexes()
 	errorFound := false
+	numLegSegments := 0
 	for i := 0; i < len(travelDetails.Segments) && !errorFound; i++ {
 		segment := travelDetails.Segments[i]
 
-		if segment.IsOutward {
-			// check num segment
-			if segment.NumSegment != i+1 {
-				errorFound = true
-			}
-		} else {
-			// if first return segment, set numOutwardSegments
-			if numOutwardSegments == -1 {
-				numOutwardSegments = i
-			}
-			// check num segment
-			if segment.NumSegment != i+1-numOutwardSegments {
+		if i == 0 {
+			if segment.LegIndex != 0 {
 				errorFound = true
 			}
+		} else if segment.LegIndex == travelDetails.Segments[i-1].LegIndex+1 {
+			// first segment of a new leg
+			numLegSegments = 0
+		} else if segment.LegIndex != travelDetails.Segments[i-1].LegIndex {
+			errorFound = true
+		}
+		numLegSegments++
+
+		// check num segment
+		if segment.NumSegment != numLegSegments {
+			errorFound = true
 		}
 	}
-	// update NumSegment for return segments
+	// update NumSegment, unique in the travel
 	for i, _ := range travelDetails.Segments {
 		travelDetails.Segments[i].NumSegment = i + 1
 	}
@@ -426,8 +324,7 @@ func createTravel(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// insert travel
-	travelDAO := db.NewTravelDAO(db.GetDB())
-	travelDetails, err = travelDAO.CreateTravel(travelDetails)
+	travelDetails, err = api.repositories.Travels.CreateTravel(travelDetails)
 	if err != nil {
 		log.Println("Error while interacting with the database: ", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -438,44 +335,48 @@ func createTravel(w http.ResponseWriter, r *http.Request) {
 		travelDetails.Segments = []model.Segment{}
 	}
 
-	// send response
-	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(travelDetails)
+	// encode response, the same body is stored for the retries
+	var response bytes.Buffer
+	err = json.NewEncoder(&response).Encode(travelDetails)
 	if err != nil {
 		log.Println("Error encoding JSON: ", err)
 		http.Error(w, "Error encoding", http.StatusInternalServerError)
 		return
 	}
+	if reservedKey != nil {
+		reservedKey.StatusCode = http.StatusOK
+		err = api.repositories.IdempotencyKeys.SetIdempotencyKeyResponse(reservedKey.IdempotencyKeyID, reservedKey.StatusCode, response.Bytes())
+		if err != nil {
+			// the travel is created, only retries are affected
+			log.Println("Error storing idempotency key response: ", err)
+		}
+	}
+
+	// send response
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(response.Bytes())
+	if err != nil {
+		log.Println("Error writing response: ", err)
+		return
+	}
 }
 
-func modifyTravel(w http.ResponseWriter, r *http.Request) {
+func (api *API) modifyTravel(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "PUT" {
 		log.Println("Method not supported")
 		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// get Firebase token
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-		log.Println("Missing or invalid auth header")
-		http.Error(w, "Missing or invalid auth header", http.StatusUnauthorized)
-		return
-	}
-	idToken := strings.TrimPrefix(authHeader, "Bearer ")
-
-	// verify Firebase token
-	ctx := context.Background()
-	firebaseUID, err := externals.VerifyFirebaseToken(ctx, idToken)
-	if err != nil {
-		log.Println("Unauthorized", err)
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	// get the user authenticated by the middleware
+	user, ok := requireUser(w, r)
+	if !ok {
 		return
 	}
 
 	// extract travel
 	var newTravel model.Travel
-	err = json.NewDecoder(r.Body).Decode(&newTravel)
+	err := json.NewDecoder(r.Body).Decode(&newTravel)
 	if err != nil {
 		log.Println("Error while decoding JSON: ", err)
 		http.Error(w, "Wrong data provided", http.StatusBadRequest)
@@ -488,28 +389,15 @@ func modifyTravel(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
-	// get travel
-	travelDAO := db.NewTravelDAO(db.GetDB())
-	existingTravel, err := travelDAO.GetTravelById(newTravel.TravelID)
-	if err != nil {
-		log.Println("Travel not found: ", err)
-		http.Error(w, "Travel not found", http.StatusNotFound)
-		return
-	}
-
-	// get user
-	userDAO := db.NewUserDAO(db.GetDB())
-	user, err := userDAO.GetUserById(newTravel.UserID)
+	// get travel, it must be owned by the user
+	existingTravel, err := api.policy.CanModifyTravel(user, newTravel.TravelID)
 	if err != nil {
-		log.Println("User not found: ", err)
-		http.Error(w, "User not found", http.StatusNotFound)
+		writePolicyError(w, err)
 		return
 	}
-
-	// check matching firebaseUID
-	if user.FirebaseUID != firebaseUID {
-		log.Println("Unauthorized", err)
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	if newTravel.UserID != existingTravel.UserID {
+		log.Println("Travel owner can't be changed")
+		http.Error(w, "Travel owner can't be changed", http.StatusBadRequest)
 		return
 	}
 
@@ -534,8 +422,11 @@ func modifyTravel(w http.ResponseWriter, r *http.Request) {
 	if newTravel.UserReview != nil {
 		newTravel.UserReview.DateTime = newTravel.UserReview.DateTime.UTC()
 	}
+	for i := range newTravel.UserReviews {
+		newTravel.UserReviews[i].DateTime = newTravel.UserReviews[i].DateTime.UTC()
+	}
 
-	travelDetails, err := travelDAO.GetTravelDetailsByTravelID(existingTravel.TravelID)
+	travelDetails, err := api.repositories.Travels.GetTravelDetailsByTravelID(existingTravel.TravelID)
 	if err != nil {
 		log.Println("Error retrieving travel details: ", err)
 		http.Error(w, "Error retrieving travel details", http.StatusBadRequest)
@@ -550,7 +441,7 @@ func modifyTravel(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// update travel in db
-	err = travelDAO.UpdateTravel(newTravel, deltaScore, isShortDistance)
+	err = api.repositories.Travels.UpdateTravel(newTravel, deltaScore, isShortDistance)
 	if err != nil {
 		log.Println("Error interacting with the db: ", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -566,10 +457,10 @@ func modifyTravel(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func HandleDeleteTravel(w http.ResponseWriter, r *http.Request) {
+func (api *API) HandleDeleteTravel(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "DELETE":
-		deleteTravel(w, r)
+		api.deleteTravel(w, r)
 	default:
 		log.Println("Method not supported")
 		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
@@ -577,28 +468,16 @@ func HandleDeleteTravel(w http.ResponseWriter, r *http.Request) {
 }
 
 // deleting travel from db automatically deletes segments (cascade)
-func deleteTravel(w http.ResponseWriter, r *http.Request) {
+func (api *API) deleteTravel(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "DELETE" {
 		log.Println("Method not supported")
 		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// get Firebase token
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-		log.Println("Missing or invalid auth header")
-		http.Error(w, "Missing or invalid auth header", http.StatusUnauthorized)
-		return
-	}
-	idToken := strings.TrimPrefix(authHeader, "Bearer ")
-
-	// verify Firebase token
-	ctx := context.Background()
-	firebaseUID, err := externals.VerifyFirebaseToken(ctx, idToken)
-	if err != nil {
-		log.Println("Unauthorized", err)
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	// get the user authenticated by the middleware
+	user, ok := requireUser(w, r)
+	if !ok {
 		return
 	}
 
@@ -618,27 +497,17 @@ func deleteTravel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	travelDAO := db.NewTravelDAO(db.GetDB())
-	travelDetails, err := travelDAO.GetTravelDetailsByTravelID(travelID)
+	// check the travel is owned by the user
+	_, err = api.policy.CanModifyTravel(user, travelID)
 	if err != nil {
-		log.Println("Invalid travel id")
-		http.Error(w, "Invalid travel ID", http.StatusBadRequest)
+		writePolicyError(w, err)
 		return
 	}
 
-	// get user
-	userDAO := db.NewUserDAO(db.GetDB())
-	user, err := userDAO.GetUserById(travelDetails.Travel.UserID)
+	travelDetails, err := api.repositories.Travels.GetTravelDetailsByTravelID(travelID)
 	if err != nil {
-		log.Println("User not found: ", err)
-		http.Error(w, "User not found", http.StatusNotFound)
-		return
-	}
-
-	// check matching firebaseUID
-	if user.FirebaseUID != firebaseUID {
-		log.Println("Unauthorized", err)
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		log.Println("Invalid travel id")
+		http.Error(w, "Invalid travel ID", http.StatusBadRequest)
 		return
 	}
 
@@ -649,7 +518,7 @@ func deleteTravel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = travelDAO.DeleteTravel(travelID, deltaScore, isShortDistance)
+	err = api.repositories.Travels.DeleteTravel(travelID, deltaScore, isShortDistance)
 	if err != nil {
 		log.Println("Error interacting with the db: ", err)
 		http.Error(w, "Error interacting with the db", http.StatusBadRequest)
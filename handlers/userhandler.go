@@ -1,60 +1,44 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
-	"green-journey-server/db"
-	"green-journey-server/externals"
 	"green-journey-server/model"
+	"green-journey-server/policy"
 	"log"
 	"net/http"
-	"strings"
 	"time"
 )
 
-func HandleUsers(w http.ResponseWriter, r *http.Request) {
+func (api *API) HandleUsers(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "GET":
-		getUserByFirebaseUID(w, r)
+		api.getUserByFirebaseUID(w, r)
 	case "POST":
-		addUser(w, r)
+		api.addUser(w, r)
 	default:
 		log.Println("HandleUsers received an unsupported method")
 		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
 	}
 }
 
-func getUserByFirebaseUID(w http.ResponseWriter, r *http.Request) {
+func (api *API) getUserByFirebaseUID(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
 		log.Println("Method not supported")
 		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// get Firebase token
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-		log.Println("Missing or invalid auth header")
-		http.Error(w, "Missing or invalid auth header", http.StatusUnauthorized)
+	// get the user authenticated by the middleware, without badges
+	user, ok := requireUser(w, r)
+	if !ok {
 		return
 	}
-	idToken := strings.TrimPrefix(authHeader, "Bearer ")
 
-	// verify Firebase token
-	ctx := context.Background()
-	firebaseUID, err := externals.VerifyFirebaseToken(ctx, idToken)
+	// inject badges, computed from the travels of the user
+	err := api.repositories.Users.InjectBadges(&user)
 	if err != nil {
-		log.Println("Unauthorized", err)
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	// get user associated to firebaseUID
-	userDAO := db.NewUserDAO(db.GetDB())
-	user, err := userDAO.GetUserByFirebaseUID(firebaseUID)
-	if err != nil {
-		log.Println("User not found: ", err)
-		http.Error(w, "User could not be found", http.StatusNotFound)
+		log.Println("Error while interacting with the database: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
@@ -67,33 +51,21 @@ func getUserByFirebaseUID(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func addUser(w http.ResponseWriter, r *http.Request) {
+func (api *API) addUser(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		log.Println("Method not supported")
 		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// get Firebase token
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-		log.Println("Missing or invalid auth header")
-		http.Error(w, "Missing or invalid auth header", http.StatusUnauthorized)
-		return
-	}
-	idToken := strings.TrimPrefix(authHeader, "Bearer ")
-
-	// verify Firebase token
-	ctx := context.Background()
-	firebaseUID, err := externals.VerifyFirebaseToken(ctx, idToken)
-	if err != nil {
-		log.Println("Unauthorized", err)
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	// get the firebase uid verified by the middleware, the user doesn't exist yet
+	firebaseUID, ok := requireFirebaseUID(w, r)
+	if !ok {
 		return
 	}
 
 	var user model.User
-	err = json.NewDecoder(r.Body).Decode(&user)
+	err := json.NewDecoder(r.Body).Decode(&user)
 	if err != nil {
 		log.Println("Error while decoding JSON: ", err)
 		http.Error(w, "Wrong data provided", http.StatusBadRequest)
@@ -146,8 +118,7 @@ func addUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// insert user
-	userDAO := db.NewUserDAO(db.GetDB())
-	user, err = userDAO.AddUser(user)
+	user, err = api.repositories.Users.AddUser(user)
 	if err != nil {
 		log.Println("Error while interacting with the database: ", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -164,46 +135,34 @@ func addUser(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func HandleModifyUser(w http.ResponseWriter, r *http.Request) {
+func (api *API) HandleModifyUser(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "PUT":
-		modifyUser(w, r)
+		api.modifyUser(w, r)
 	case "DELETE":
-		deleteUser(w, r)
+		api.deleteUser(w, r)
 	default:
 		log.Println("Method not supported")
 		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
 	}
 }
 
-func modifyUser(w http.ResponseWriter, r *http.Request) {
+func (api *API) modifyUser(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "PUT" {
 		log.Println("Method not supported")
 		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// get Firebase token
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-		log.Println("Missing or invalid auth header")
-		http.Error(w, "Missing or invalid auth header", http.StatusUnauthorized)
-		return
-	}
-	idToken := strings.TrimPrefix(authHeader, "Bearer ")
-
-	// verify Firebase token
-	ctx := context.Background()
-	firebaseUID, err := externals.VerifyFirebaseToken(ctx, idToken)
-	if err != nil {
-		log.Println("Unauthorized", err)
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	// get the user authenticated by the middleware
+	existingUser, ok := requireUser(w, r)
+	if !ok {
 		return
 	}
 
 	// get the user from the body
 	var user model.User
-	err = json.NewDecoder(r.Body).Decode(&user)
+	err := json.NewDecoder(r.Body).Decode(&user)
 	if err != nil {
 		log.Println("Error while decoding JSON: ", err)
 		http.Error(w, "Wrong data provided", http.StatusBadRequest)
@@ -217,18 +176,16 @@ func modifyUser(w http.ResponseWriter, r *http.Request) {
 	}()
 
 	// check matching firebaseUID
-	if user.FirebaseUID != firebaseUID {
+	if user.FirebaseUID != existingUser.FirebaseUID {
 		log.Println("Unauthorized")
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	// get existing user
-	userDAO := db.NewUserDAO(db.GetDB())
-	existingUser, err := userDAO.GetUserByFirebaseUID(firebaseUID)
+	// check the user modifies their own row, the update is keyed by user_id
+	err = policy.CanModifyUser(existingUser, user.UserID)
 	if err != nil {
-		log.Println("User not found: ", err)
-		http.Error(w, "User not found", http.StatusNotFound)
+		writePolicyError(w, err)
 		return
 	}
 
@@ -271,7 +228,7 @@ func modifyUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// update user in db
-	err = userDAO.UpdateUser(user)
+	err = api.repositories.Users.UpdateUser(user)
 	if err != nil {
 		log.Println("Error while interacting with db: ", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -288,40 +245,21 @@ func modifyUser(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func deleteUser(w http.ResponseWriter, r *http.Request) {
+func (api *API) deleteUser(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "DELETE" {
 		log.Println("Method not supported")
 		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// get Firebase token
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-		log.Println("Missing or invalid auth header")
-		http.Error(w, "Missing or invalid auth header", http.StatusUnauthorized)
-		return
-	}
-	idToken := strings.TrimPrefix(authHeader, "Bearer ")
-
-	// verify Firebase token
-	ctx := context.Background()
-	firebaseUID, err := externals.VerifyFirebaseToken(ctx, idToken)
-	if err != nil {
-		log.Println("Unauthorized", err)
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	// get the user authenticated by the middleware
+	user, ok := requireUser(w, r)
+	if !ok {
 		return
 	}
 
 	// delete user
-	userDAO := db.NewUserDAO(db.GetDB())
-	user, err := userDAO.GetUserByFirebaseUID(firebaseUID)
-	if err != nil {
-		log.Println("User not found: ", err)
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
-		return
-	}
-	err = userDAO.DeleteUser(user.UserID)
+	err := api.repositories.Users.DeleteUser(user.UserID)
 	if err != nil {
 		log.Println("Error while interacting with the db: ", err)
 		http.Error(w, "Error while deleting user", http.StatusBadRequest)
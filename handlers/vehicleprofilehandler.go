@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"encoding/json"
+	"green-journey-server/internals"
+	"green-journey-server/model"
+	"green-journey-server/policy"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func (api *API) HandleVehicleProfiles(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		api.getVehicleProfiles(w, r)
+	case "POST":
+		api.createVehicleProfile(w, r)
+	default:
+		log.Println("Method not supported")
+		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+func (api *API) HandleModifyVehicleProfiles(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "PUT":
+		api.modifyVehicleProfile(w, r)
+	case "DELETE":
+		api.deleteVehicleProfile(w, r)
+	default:
+		log.Println("Method not supported")
+		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+func (api *API) getVehicleProfiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		log.Println("Method not supported")
+		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// get the user authenticated by the middleware
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	vehicleProfiles, err := api.repositories.VehicleProfiles.GetVehicleProfilesByUserId(user.UserID)
+	if err != nil {
+		log.Println("Error getting vehicle profiles: ", err)
+		http.Error(w, "Error getting vehicle profiles", http.StatusInternalServerError)
+		return
+	}
+	if vehicleProfiles == nil {
+		vehicleProfiles = []model.VehicleProfile{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(vehicleProfiles)
+	if err != nil {
+		log.Println("Error encoding JSON: ", err)
+		http.Error(w, "Error encoding", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (api *API) createVehicleProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		log.Println("Method not supported")
+		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// get the user authenticated by the middleware
+	authUser, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	// decode json data
+	var vehicleProfile model.VehicleProfile
+	err := json.NewDecoder(r.Body).Decode(&vehicleProfile)
+	if err != nil {
+		log.Println("Error decoding JSON: ", err)
+		http.Error(w, "Invalid data format", http.StatusBadRequest)
+		return
+	}
+	defer func() {
+		err = r.Body.Close()
+		if err != nil {
+			log.Println("Error closing request body:", err)
+		}
+	}()
+
+	// check the vehicle profile is created for the authenticated user
+	err = policy.CanCreateFor(authUser, vehicleProfile.UserID)
+	if err != nil {
+		writePolicyError(w, err)
+		return
+	}
+
+	// check vehicle profile data
+	if !checkVehicleProfile(w, vehicleProfile) {
+		return
+	}
+
+	// id is autogenerated
+	vehicleProfile.VehicleProfileID = 0
+	err = api.repositories.VehicleProfiles.CreateVehicleProfile(&vehicleProfile)
+	if err != nil {
+		log.Println("Error while interacting with the database: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(vehicleProfile)
+	if err != nil {
+		log.Println("Error encoding JSON: ", err)
+		http.Error(w, "Error encoding", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (api *API) modifyVehicleProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "PUT" {
+		log.Println("Method not supported")
+		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// get the user authenticated by the middleware
+	authUser, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	// extract vehicle profile id from URI
+	vehicleProfileID, ok := parseVehicleProfileID(w, r)
+	if !ok {
+		return
+	}
+
+	// get the vehicle profile from the body
+	var vehicleProfile model.VehicleProfile
+	err := json.NewDecoder(r.Body).Decode(&vehicleProfile)
+	if err != nil {
+		log.Println("Error while decoding JSON: ", err)
+		http.Error(w, "Wrong data provided", http.StatusBadRequest)
+		return
+	}
+	defer func() {
+		err = r.Body.Close()
+		if err != nil {
+			log.Println("Error closing request body:", err)
+		}
+	}()
+	vehicleProfile.VehicleProfileID = vehicleProfileID
+
+	// get existing vehicle profile, it must be owned by the user and the owner can't change
+	existingVehicleProfile, err := api.policy.CanModifyVehicleProfile(authUser, vehicleProfileID)
+	if err != nil {
+		writePolicyError(w, err)
+		return
+	}
+	if vehicleProfile.UserID != existingVehicleProfile.UserID {
+		log.Println("Vehicle profile owner can't be changed")
+		http.Error(w, "Vehicle profile owner can't be changed", http.StatusBadRequest)
+		return
+	}
+
+	// check vehicle profile data
+	if !checkVehicleProfile(w, vehicleProfile) {
+		return
+	}
+
+	err = api.repositories.VehicleProfiles.UpdateVehicleProfile(vehicleProfile)
+	if err != nil {
+		log.Println("Error while interacting with db: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(vehicleProfile)
+	if err != nil {
+		log.Println("Error encoding JSON: ", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (api *API) deleteVehicleProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		log.Println("Method not supported")
+		http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// get the user authenticated by the middleware
+	authUser, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	// extract vehicle profile id from URI
+	vehicleProfileID, ok := parseVehicleProfileID(w, r)
+	if !ok {
+		return
+	}
+
+	// check the vehicle profile is owned by the user
+	_, err := api.policy.CanModifyVehicleProfile(authUser, vehicleProfileID)
+	if err != nil {
+		writePolicyError(w, err)
+		return
+	}
+
+	err = api.repositories.VehicleProfiles.DeleteVehicleProfile(vehicleProfileID)
+	if err != nil {
+		log.Println("Error while interacting with the db: ", err)
+		http.Error(w, "Error while deleting vehicle profile", http.StatusBadRequest)
+		return
+	}
+}
+
+// parseVehicleProfileID extracts the vehicle profile id from /users/vehicles/{id},
+// if it is not valid, the error is written and false is returned
+func parseVehicleProfileID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) < 4 || parts[3] == "" {
+		log.Println("Invalid path")
+		http.Error(w, "Vehicle profile ID not provided", http.StatusBadRequest)
+		return 0, false
+	}
+	vehicleProfileID, err := strconv.Atoi(parts[3])
+	if err != nil || vehicleProfileID < 0 {
+		log.Println("Invalid vehicle profile ID")
+		http.Error(w, "Invalid vehicle profile ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return vehicleProfileID, true
+}
+
+// checkVehicleProfile validates the data of a vehicle profile,
+// if it is not valid, the error is written and false is returned
+func checkVehicleProfile(w http.ResponseWriter, vehicleProfile model.VehicleProfile) bool {
+	if vehicleProfile.Name == "" {
+		log.Println("Missing vehicle name")
+		http.Error(w, "Missing vehicle name", http.StatusBadRequest)
+		return false
+	}
+	if !internals.IsValidFuelType(vehicleProfile.FuelType) {
+		log.Println("Invalid fuel type")
+		http.Error(w, "Invalid fuel type", http.StatusBadRequest)
+		return false
+	}
+	if vehicleProfile.Consumption <= 0 {
+		log.Println("Invalid consumption")
+		http.Error(w, "Invalid consumption", http.StatusBadRequest)
+		return false
+	}
+	if vehicleProfile.Seats < 1 || vehicleProfile.Seats > maxPassengers {
+		log.Println("Invalid number of seats")
+		http.Error(w, "Invalid number of seats", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"green-journey-server/config"
+	"green-journey-server/db"
+	"green-journey-server/internals"
+	"log"
+	"os"
+)
+
+// runImportCommand runs the import subcommand, which upserts cities and airports from local datasets:
+// `import [-config=config.yaml] [-test_mode=real|test] [-dry_run] -cities cities.csv -airports airports.csv`
+func runImportCommand(args []string) {
+	flagSet := flag.NewFlagSet("import", flag.ExitOnError)
+	configPathArg := flagSet.String("config", "", "Config file, "+config.DefaultPath+" if present")
+	testModeArg := flagSet.String("test_mode", "real", "Test mode")
+	citiesArg := flagSet.String("cities", "", "Cities dataset, csv or tsv")
+	airportsArg := flagSet.String("airports", "", "Airports dataset, csv or tsv")
+	dryRunArg := flagSet.Bool("dry_run", false, "Report the changes without applying them")
+	err := flagSet.Parse(args)
+	if err != nil {
+		log.Fatalf("Invalid arguments: %v", err)
+	}
+	if *citiesArg == "" && *airportsArg == "" {
+		log.Fatal("No dataset to import, set cities and/or airports")
+	}
+
+	// read datasets before connecting, so that invalid files don't touch the db
+	var cities []internals.DatasetCity
+	if *citiesArg != "" {
+		file, err := os.Open(*citiesArg)
+		if err != nil {
+			log.Fatalf("Error opening cities dataset: %v", err)
+		}
+		var skipped int
+		cities, skipped, err = internals.ParseCitiesDataset(file)
+		_ = file.Close()
+		if err != nil {
+			log.Fatalf("Error reading cities dataset: %v", err)
+		}
+		log.Printf("Read %d cities, skipped %d rows without iata", len(cities), skipped)
+	}
+	var airports []internals.DatasetAirport
+	if *airportsArg != "" {
+		file, err := os.Open(*airportsArg)
+		if err != nil {
+			log.Fatalf("Error opening airports dataset: %v", err)
+		}
+		var skipped int
+		airports, skipped, err = internals.ParseAirportsDataset(file)
+		_ = file.Close()
+		if err != nil {
+			log.Fatalf("Error reading airports dataset: %v", err)
+		}
+		log.Printf("Read %d airports, skipped %d rows without iata or closed", len(airports), skipped)
+	}
+
+	// only the database config is needed
+	commandConfig, err := config.Load(*configPathArg)
+	if err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
+	err = commandConfig.Database.Validate()
+	if err != nil {
+		log.Fatalf("Invalid database config: %v", err)
+	}
+
+	database, err := db.InitDB(commandConfig.Database, *testModeArg)
+	if err != nil || database == nil {
+		log.Fatalf("Error initializing database: %v", err)
+	}
+	defer func() {
+		db.CloseDBConnection()
+	}()
+
+	cityDAO := db.NewCityDAO(database)
+	report, err := cityDAO.ImportDataset(cities, airports, *dryRunArg)
+	if err != nil {
+		log.Fatalf("Error importing datasets: %v", err)
+	}
+
+	for _, change := range report.Changes {
+		log.Println(change)
+	}
+	for _, conflict := range report.Conflicts {
+		log.Println("conflict:", conflict)
+	}
+	if *dryRunArg {
+		log.Println("Dry run, no changes applied")
+	}
+	log.Printf("Cities: %d created, %d updated, %d unchanged", report.CitiesCreated, report.CitiesUpdated, report.CitiesUnchanged)
+	log.Printf("Airports: %d created, %d updated, %d unchanged", report.AirportsCreated, report.AirportsUpdated, report.AirportsUnchanged)
+	log.Printf("Conflicts: %d", len(report.Conflicts))
+}
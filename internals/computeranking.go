@@ -0,0 +1,41 @@
+package internals
+
+import (
+	"green-journey-server/model"
+	"time"
+)
+
+// ComputeRankingElement computes the ranking element of a user from their travels, only confirmed travels count
+func ComputeRankingElement(user model.User, travels []model.TravelDetails) model.RankingElement {
+	// compute values based on user travels
+	totalDistance := 0.0
+	totalDuration := time.Duration(0)
+	totalCO2Emitted := 0.0
+	totalCO2Compensated := 0.0
+
+	for _, travelDetails := range travels {
+		if travelDetails.Travel.Confirmed {
+			totalCO2Compensated += travelDetails.Travel.CO2Compensated
+
+			for _, segment := range travelDetails.Segments {
+				totalDistance += segment.Distance
+				totalDuration += segment.Duration
+				totalCO2Emitted += segment.CO2Emitted
+			}
+		}
+	}
+
+	// create and return ranking element
+	return model.RankingElement{
+		UserID:              user.UserID,
+		FirstName:           user.FirstName,
+		LastName:            user.LastName,
+		ScoreShortDistance:  user.ScoreShortDistance,
+		ScoreLongDistance:   user.ScoreLongDistance,
+		TotalDistance:       totalDistance,
+		TotalDuration:       totalDuration,
+		TotalCO2Emitted:     totalCO2Emitted,
+		TotalCO2Compensated: totalCO2Compensated,
+		Badges:              user.Badges,
+	}
+}
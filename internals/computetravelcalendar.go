@@ -0,0 +1,70 @@
+package internals
+
+import (
+	"green-journey-server/model"
+	"sort"
+)
+
+// ComputeMainVehicle returns the vehicle covering the longest distance of an option,
+// walking is considered only if the option has no other vehicle
+func ComputeMainVehicle(option []model.Segment) string {
+	mainVehicle := ""
+	distances := map[string]float64{}
+	for _, segment := range option {
+		distances[segment.Vehicle] += segment.Distance
+	}
+	for vehicle, distance := range distances {
+		if vehicle == "walk" {
+			continue
+		}
+		if mainVehicle == "" || distance > distances[mainVehicle] ||
+			(distance == distances[mainVehicle] && vehicle < mainVehicle) {
+			mainVehicle = vehicle
+		}
+	}
+	if mainVehicle == "" && len(option) > 0 {
+		return "walk"
+	}
+	return mainVehicle
+}
+
+// ComputeCalendarEntries groups the options of a day by main vehicle,
+// returning the cheapest price and lowest co2 of each vehicle, sorted by vehicle
+func ComputeCalendarEntries(options [][]model.Segment) []model.CalendarEntry {
+	entries := map[string]*model.CalendarEntry{}
+	for _, option := range options {
+		if len(option) == 0 {
+			continue
+		}
+		vehicle := ComputeMainVehicle(option)
+		price, co2Emitted, _ := ComputeOptionTotals(option)
+
+		entry, ok := entries[vehicle]
+		if !ok {
+			entries[vehicle] = &model.CalendarEntry{
+				Vehicle:    vehicle,
+				MinPrice:   price,
+				Currency:   option[0].Currency,
+				MinCO2:     co2Emitted,
+				NumOptions: 1,
+			}
+			continue
+		}
+		if price < entry.MinPrice {
+			entry.MinPrice = price
+		}
+		if co2Emitted < entry.MinCO2 {
+			entry.MinCO2 = co2Emitted
+		}
+		entry.NumOptions++
+	}
+
+	calendarEntries := []model.CalendarEntry{}
+	for _, entry := range entries {
+		calendarEntries = append(calendarEntries, *entry)
+	}
+	sort.Slice(calendarEntries, func(i, j int) bool {
+		return calendarEntries[i].Vehicle < calendarEntries[j].Vehicle
+	})
+	return calendarEntries
+}
@@ -19,3 +19,28 @@ func ComputeHaversineDistance(lat1, lon1, lat2, lon2 float64) float64 {
 
 	return R * c
 }
+
+// ComputeBoundingBox returns the min and max latitude and longitude of a box containing
+// all the points within radius [km] from a point: it is used to prefilter radius searches,
+// near the poles or the antimeridian the box covers all longitudes
+func ComputeBoundingBox(lat, lon, radius float64) (float64, float64, float64, float64) {
+	// heart radius [km]
+	const R = 6371
+
+	deltaLat := radius / R * 180 / math.Pi
+	minLat := math.Max(lat-deltaLat, -90)
+	maxLat := math.Min(lat+deltaLat, 90)
+	if minLat == -90 || maxLat == 90 {
+		return minLat, maxLat, -180, 180
+	}
+
+	// degrees of longitude get shorter moving away from the equator
+	deltaLon := deltaLat / math.Cos(math.Max(math.Abs(minLat), math.Abs(maxLat))*math.Pi/180)
+	minLon := lon - deltaLon
+	maxLon := lon + deltaLon
+	if minLon < -180 || maxLon > 180 {
+		return minLat, maxLat, -180, 180
+	}
+
+	return minLat, maxLat, minLon, maxLon
+}
@@ -1,22 +1,296 @@
 package internals
 
-import "math"
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"green-journey-server/model"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
 
-func ComputeCarEmission(distance int) float64 {
-	return 0.2 * float64(distance)
+// EmissionModel computes the co2 emitted by the segments of a travel option, in kg per person:
+// the version identifies the methodology and the factors, it is stored in every segment
+type EmissionModel interface {
+	Version() string
+	// CarEmission assumes an average car with a single person
+	CarEmission(distance float64) float64
+	// CarEmissionWithProfile divides the emissions of the profile car among the passengers
+	CarEmissionWithProfile(distance float64, vehicleProfile model.VehicleProfile, passengers int) float64
+	// TrainEmission uses the factor of every country crossed, countryDistances contains
+	// the distance [km] traveled in each country, by country code
+	TrainEmission(countryDistances map[string]float64) float64
+	BusEmission(distance float64) float64
+	// FlightEmission uses the great circle distance [km] and explains how the result is computed
+	FlightEmission(distance float64, cabinClass string, radiativeForcing bool) model.EmissionBreakdown
 }
 
-func ComputeAircraftEmission(hours, minutes int) float64 {
-	durationMin := float64(hours*60 + minutes)
+//go:embed emissionfactors.json
+var defaultEmissionFactors []byte
 
-	// y = 0,000000002163511 x4 - 0,000003861958034 x3 + 0,001920067332020 x2 + 0,410217102378141 x + 20,868891633418000
-	return 0.000000002163511*math.Pow(durationMin, 4.0) - 0.000003861958034*math.Pow(durationMin, 3.0) + 0.001920067332020*math.Pow(durationMin, 2.0) + 0.410217102378141*durationMin + 20.868891633418000
+//go:embed railfactors.csv
+var defaultRailFactors []byte
+
+// EmissionFactors is the content of a factors file
+type EmissionFactors struct {
+	Version     string `json:"version"`
+	Description string `json:"description"`
+	// kg of co2 per km, for every vehicle
+	Vehicles map[string]float64 `json:"vehicles"`
+	// flight bands sorted by distance, the last one has no max distance
+	FlightDistanceBands        []FlightDistanceBand `json:"flight_distance_bands"`
+	FlightDistanceUplift       float64              `json:"flight_distance_uplift"`
+	RadiativeForcingMultiplier float64              `json:"radiative_forcing_multiplier"`
+	// kg of co2 per liter of fuel, or per kWh for electric vehicles
+	Fuels map[string]float64 `json:"fuels"`
+}
+
+// FlightDistanceBand contains the flight factors up to a distance [km]
+type FlightDistanceBand struct {
+	Name        string  `json:"name"`
+	MaxDistance float64 `json:"max_distance"`
+	// kg of co2 per km of an economy seat
+	Factor float64 `json:"factor"`
+	// multiplier of the factor for every cabin class
+	CabinMultipliers map[string]float64 `json:"cabin_multipliers"`
+}
+
+// factorEmissionModel is the default EmissionModel, based on per km factors
+type factorEmissionModel struct {
+	// version of the factors file and of the rail factors
+	version string
+	factors EmissionFactors
+	// kg of co2 per km of trains, for every country code
+	railFactors map[string]float64
+}
+
+var (
+	emissionModel      EmissionModel
+	emissionModelMutex sync.RWMutex
+)
+
+// GetEmissionModel returns the emission model used by the searches,
+// the model of the bundled factors file if no other model was set
+func GetEmissionModel() EmissionModel {
+	emissionModelMutex.RLock()
+	currentModel := emissionModel
+	emissionModelMutex.RUnlock()
+	if currentModel != nil {
+		return currentModel
+	}
+
+	emissionModelMutex.Lock()
+	defer emissionModelMutex.Unlock()
+	if emissionModel == nil {
+		defaultModel, err := NewFactorEmissionModel(defaultEmissionFactors, defaultRailFactors)
+		if err != nil {
+			// the bundled file is checked at development time
+			panic(err)
+		}
+		emissionModel = defaultModel
+	}
+	return emissionModel
+}
+
+// SetEmissionModel replaces the emission model used by the searches
+func SetEmissionModel(newEmissionModel EmissionModel) {
+	emissionModelMutex.Lock()
+	defer emissionModelMutex.Unlock()
+
+	emissionModel = newEmissionModel
+}
+
+// LoadEmissionModel reads a factors file, e.g. a newer version of the bundled one, and a rail factors file:
+// if a path is empty, the bundled file is used instead
+func LoadEmissionModel(path, railPath string) (EmissionModel, error) {
+	var err error
+	data := defaultEmissionFactors
+	if path != "" {
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	railData := defaultRailFactors
+	if railPath != "" {
+		railData, err = os.ReadFile(railPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return NewFactorEmissionModel(data, railData)
+}
+
+// NewFactorEmissionModel decodes a factors file and a rail factors csv file,
+// checking that all vehicles and fuels have a factor
+func NewFactorEmissionModel(data, railData []byte) (EmissionModel, error) {
+	var factors EmissionFactors
+	err := json.Unmarshal(data, &factors)
+	if err != nil {
+		return nil, err
+	}
+	railFactors, err := decodeRailFactors(railData)
+	if err != nil {
+		return nil, err
+	}
+
+	if factors.Version == "" {
+		return nil, fmt.Errorf("missing emission factors version")
+	}
+	for _, vehicle := range []string{"car", "train", "bus", "bike", "walk"} {
+		if _, ok := factors.Vehicles[vehicle]; !ok {
+			return nil, fmt.Errorf("missing emission factor for vehicle %s", vehicle)
+		}
+	}
+	for _, fuelType := range []string{model.FuelTypePetrol, model.FuelTypeDiesel, model.FuelTypeHybrid, model.FuelTypeElectric, model.FuelTypeLPG} {
+		if _, ok := factors.Fuels[fuelType]; !ok {
+			return nil, fmt.Errorf("missing emission factor for fuel %s", fuelType)
+		}
+	}
+	if len(factors.FlightDistanceBands) == 0 {
+		return nil, fmt.Errorf("missing flight distance bands")
+	}
+	for i, band := range factors.FlightDistanceBands {
+		isLast := i == len(factors.FlightDistanceBands)-1
+		if (isLast && band.MaxDistance != 0) ||
+			(!isLast && band.MaxDistance <= 0) ||
+			(i > 0 && !isLast && band.MaxDistance <= factors.FlightDistanceBands[i-1].MaxDistance) {
+			return nil, fmt.Errorf("invalid max distance of flight distance band %s", band.Name)
+		}
+		for _, cabinClass := range []string{model.CabinClassEconomy, model.CabinClassPremiumEconomy, model.CabinClassBusiness, model.CabinClassFirst} {
+			if _, ok := band.CabinMultipliers[cabinClass]; !ok {
+				return nil, fmt.Errorf("missing cabin multiplier %s in flight distance band %s", cabinClass, band.Name)
+			}
+		}
+	}
+	if factors.FlightDistanceUplift < 1 || factors.RadiativeForcingMultiplier < 1 {
+		return nil, fmt.Errorf("flight multipliers must be at least 1")
+	}
+
+	// the rail csv has no version, a hash of its content identifies it
+	railVersion := fmt.Sprintf("%x", sha256.Sum256(railData))[:8]
+	version := factors.Version + "+rail." + railVersion
+
+	return factorEmissionModel{version: version, factors: factors, railFactors: railFactors}, nil
+}
+
+// decodeRailFactors reads the rail csv file, with header country_code, kg_co2_per_km and note
+func decodeRailFactors(data []byte) (map[string]float64, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 || len(records[0]) < 2 || records[0][0] != "country_code" || records[0][1] != "kg_co2_per_km" {
+		return nil, fmt.Errorf("invalid rail factors header")
+	}
+
+	railFactors := map[string]float64{}
+	for _, record := range records[1:] {
+		countryCode := strings.ToUpper(strings.TrimSpace(record[0]))
+		factor, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if err != nil || factor < 0 || countryCode == "" {
+			return nil, fmt.Errorf("invalid rail factor for country %s", record[0])
+		}
+		railFactors[countryCode] = factor
+	}
+	return railFactors, nil
+}
+
+func (emissionModel factorEmissionModel) Version() string {
+	return emissionModel.version
+}
+
+func (emissionModel factorEmissionModel) CarEmission(distance float64) float64 {
+	return emissionModel.factors.Vehicles["car"] * distance
+}
+
+func (emissionModel factorEmissionModel) CarEmissionWithProfile(distance float64, vehicleProfile model.VehicleProfile, passengers int) float64 {
+	if passengers < 1 {
+		passengers = 1
+	}
+	consumed := distance * vehicleProfile.Consumption / 100
+	return consumed * emissionModel.factors.Fuels[vehicleProfile.FuelType] / float64(passengers)
+}
+
+func (emissionModel factorEmissionModel) TrainEmission(countryDistances map[string]float64) float64 {
+	co2Emitted := 0.0
+	for countryCode, distance := range countryDistances {
+		co2Emitted += emissionModel.railFactor(countryCode) * distance
+	}
+	return co2Emitted
+}
+
+// railFactor returns the train factor of a country, the average one if the country has no factor
+func (emissionModel factorEmissionModel) railFactor(countryCode string) float64 {
+	factor, ok := emissionModel.railFactors[strings.ToUpper(countryCode)]
+	if !ok {
+		return emissionModel.factors.Vehicles["train"]
+	}
+	return factor
+}
+
+func (emissionModel factorEmissionModel) BusEmission(distance float64) float64 {
+	return emissionModel.factors.Vehicles["bus"] * distance
+}
+
+func (emissionModel factorEmissionModel) FlightEmission(distance float64, cabinClass string, radiativeForcing bool) model.EmissionBreakdown {
+	factors := emissionModel.factors
+
+	// find the distance band, the last one if distance exceeds all others
+	band := factors.FlightDistanceBands[len(factors.FlightDistanceBands)-1]
+	for _, candidate := range factors.FlightDistanceBands {
+		if candidate.MaxDistance > 0 && distance <= candidate.MaxDistance {
+			band = candidate
+			break
+		}
+	}
+
+	// unknown cabin classes are considered economy
+	cabinMultiplier, ok := band.CabinMultipliers[cabinClass]
+	if !ok {
+		cabinClass = model.CabinClassEconomy
+		cabinMultiplier = band.CabinMultipliers[cabinClass]
+	}
+
+	radiativeForcingMultiplier := 1.0
+	if radiativeForcing {
+		radiativeForcingMultiplier = factors.RadiativeForcingMultiplier
+	}
+
+	return model.EmissionBreakdown{
+		DistanceBand:               band.Name,
+		Distance:                   distance,
+		DistanceUplift:             factors.FlightDistanceUplift,
+		BaseFactor:                 band.Factor,
+		CabinClass:                 cabinClass,
+		CabinMultiplier:            cabinMultiplier,
+		RadiativeForcingMultiplier: radiativeForcingMultiplier,
+		CO2Emitted:                 distance * factors.FlightDistanceUplift * band.Factor * cabinMultiplier * radiativeForcingMultiplier,
+	}
 }
 
-func ComputeTrainEmission(distance int) float64 {
-	return 0.035 * float64(distance)
+// IsValidCabinClass checks that a cabin class is known
+func IsValidCabinClass(cabinClass string) bool {
+	switch cabinClass {
+	case model.CabinClassEconomy, model.CabinClassPremiumEconomy, model.CabinClassBusiness, model.CabinClassFirst:
+		return true
+	default:
+		return false
+	}
 }
 
-func ComputeBusEmission(distance int) float64 {
-	return 0.03 * float64(distance)
+// IsValidFuelType checks that a fuel type is known
+func IsValidFuelType(fuelType string) bool {
+	switch fuelType {
+	case model.FuelTypePetrol, model.FuelTypeDiesel, model.FuelTypeHybrid, model.FuelTypeElectric, model.FuelTypeLPG:
+		return true
+	default:
+		return false
+	}
 }
@@ -0,0 +1,151 @@
+package internals
+
+import (
+	"green-journey-server/model"
+	"time"
+)
+
+// ComputeOptionTotals returns total price, co2 emitted and duration of a travel option
+func ComputeOptionTotals(option []model.Segment) (float64, float64, time.Duration) {
+	totalPrice := 0.0
+	totalCO2Emitted := 0.0
+	totalDuration := time.Duration(0)
+	for _, segment := range option {
+		totalPrice += segment.Price
+		totalCO2Emitted += segment.CO2Emitted
+		totalDuration += segment.Duration
+	}
+	return totalPrice, totalCO2Emitted, totalDuration
+}
+
+// ComputeRoundTripOption pairs an outward and a return option, computing the combined totals
+func ComputeRoundTripOption(outward, ret []model.Segment) model.RoundTripOption {
+	outwardPrice, outwardCO2Emitted, outwardDuration := ComputeOptionTotals(outward)
+	returnPrice, returnCO2Emitted, returnDuration := ComputeOptionTotals(ret)
+
+	// segments of an option share the same currency
+	currency := ""
+	if len(outward) > 0 {
+		currency = outward[0].Currency
+	} else if len(ret) > 0 {
+		currency = ret[0].Currency
+	}
+
+	return model.RoundTripOption{
+		Outward:    outward,
+		Return:     ret,
+		Price:      outwardPrice + returnPrice,
+		Currency:   currency,
+		CO2Emitted: outwardCO2Emitted + returnCO2Emitted,
+		Duration:   outwardDuration + returnDuration,
+	}
+}
+
+// labels of travel options
+const (
+	LabelGreenest     = "greenest"
+	LabelCheapest     = "cheapest"
+	LabelFastest      = "fastest"
+	LabelBestTradeOff = "best_trade_off"
+)
+
+// ComputeTravelOptionSummaries compares the options of a search, the i-th summary refers to the i-th option:
+// an option is Pareto-optimal if no other option is better or equal on price, duration and co2,
+// and strictly better on at least one of them
+func ComputeTravelOptionSummaries(options [][]model.Segment) []model.TravelOptionSummary {
+	summaries := make([]model.TravelOptionSummary, len(options))
+	if len(options) == 0 {
+		return summaries
+	}
+
+	prices := make([]float64, len(options))
+	co2Emissions := make([]float64, len(options))
+	durations := make([]float64, len(options))
+	for i, option := range options {
+		price, co2Emitted, duration := ComputeOptionTotals(option)
+		prices[i] = price
+		co2Emissions[i] = co2Emitted
+		durations[i] = duration.Minutes()
+	}
+
+	// baselines, the least emitting plane and car options: intermodal options are not baselines
+	var planeBaseline, carBaseline *float64
+	for i, option := range options {
+		if usesOnlyVehicle(option, "plane") && (planeBaseline == nil || co2Emissions[i] < *planeBaseline) {
+			planeBaseline = &co2Emissions[i]
+		}
+		if usesOnlyVehicle(option, "car") && (carBaseline == nil || co2Emissions[i] < *carBaseline) {
+			carBaseline = &co2Emissions[i]
+		}
+	}
+
+	// the best trade-off is the Pareto-optimal option with the lowest sum of normalized values
+	normalizedPrices := normalize(prices)
+	normalizedCO2Emissions := normalize(co2Emissions)
+	normalizedDurations := normalize(durations)
+	bestTradeOff := -1
+	bestTradeOffScore := 0.0
+
+	greenest, cheapest, fastest := 0, 0, 0
+	for i := range options {
+		summaries[i].Labels = []string{}
+		summaries[i].ParetoOptimal = true
+		for j := range options {
+			if i != j &&
+				prices[j] <= prices[i] && co2Emissions[j] <= co2Emissions[i] && durations[j] <= durations[i] &&
+				(prices[j] < prices[i] || co2Emissions[j] < co2Emissions[i] || durations[j] < durations[i]) {
+				summaries[i].ParetoOptimal = false
+				break
+			}
+		}
+
+		if summaries[i].ParetoOptimal {
+			score := normalizedPrices[i] + normalizedCO2Emissions[i] + normalizedDurations[i]
+			if bestTradeOff == -1 || score < bestTradeOffScore {
+				bestTradeOff = i
+				bestTradeOffScore = score
+			}
+		}
+
+		if co2Emissions[i] < co2Emissions[greenest] {
+			greenest = i
+		}
+		if prices[i] < prices[cheapest] {
+			cheapest = i
+		}
+		if durations[i] < durations[fastest] {
+			fastest = i
+		}
+
+		if planeBaseline != nil {
+			saved := *planeBaseline - co2Emissions[i]
+			summaries[i].CO2SavedVsPlane = &saved
+		}
+		if carBaseline != nil {
+			saved := *carBaseline - co2Emissions[i]
+			summaries[i].CO2SavedVsCar = &saved
+		}
+	}
+
+	summaries[greenest].Labels = append(summaries[greenest].Labels, LabelGreenest)
+	summaries[cheapest].Labels = append(summaries[cheapest].Labels, LabelCheapest)
+	summaries[fastest].Labels = append(summaries[fastest].Labels, LabelFastest)
+	if bestTradeOff != -1 {
+		summaries[bestTradeOff].Labels = append(summaries[bestTradeOff].Labels, LabelBestTradeOff)
+	}
+
+	return summaries
+}
+
+// usesOnlyVehicle reports whether every segment of the option uses the vehicle
+func usesOnlyVehicle(option []model.Segment, vehicle string) bool {
+	if len(option) == 0 {
+		return false
+	}
+	for _, segment := range option {
+		if segment.Vehicle != vehicle {
+			return false
+		}
+	}
+	return true
+}
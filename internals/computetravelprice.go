@@ -1,8 +1,16 @@
 package internals
 
+import (
+	"fmt"
+	"green-journey-server/model"
+)
+
 // 15 km/l average fuel efficiency
 const fuelEfficiency = 15
 
+// currency of the prices computed by the server, e.g. fuel, toll and transit costs
+const DefaultCurrency = "EUR"
+
 func ComputeCarPrice(fuelCostPerLiter, distance, tollCost float64) float64 {
 	// fuel cost
 	fuelCost := (distance / fuelEfficiency) * fuelCostPerLiter
@@ -10,3 +18,60 @@ func ComputeCarPrice(fuelCostPerLiter, distance, tollCost float64) float64 {
 	// return the sum
 	return fuelCost + tollCost
 }
+
+// ComputeCarPriceWithProfile returns fuel and toll cost of the car of a vehicle profile, divided among the passengers:
+// fuelCost is the cost of a liter of fuel, or of a kWh for electric vehicles
+func ComputeCarPriceWithProfile(fuelCost, distance, tollCost float64, vehicleProfile model.VehicleProfile, passengers int) float64 {
+	if passengers < 1 {
+		passengers = 1
+	}
+	consumed := distance * vehicleProfile.Consumption / 100
+	return (consumed*fuelCost + tollCost) / float64(passengers)
+}
+
+// ConvertPrice converts a price between currencies, rates contain the value of 1 DefaultCurrency in every currency
+func ConvertPrice(price float64, from, to string, rates map[string]float64) (float64, error) {
+	if from == to {
+		return price, nil
+	}
+	fromRate, ok := rates[from]
+	if !ok || fromRate <= 0 {
+		return 0, fmt.Errorf("unknown currency %s", from)
+	}
+	toRate, ok := rates[to]
+	if !ok || toRate <= 0 {
+		return 0, fmt.Errorf("unknown currency %s", to)
+	}
+	return price / fromRate * toRate, nil
+}
+
+// ConvertSegmentsCurrency converts the prices of the segments to currency,
+// segments without currency are in DefaultCurrency
+func ConvertSegmentsCurrency(segments []model.Segment, currency string, rates map[string]float64) error {
+	for i := range segments {
+		if segments[i].Currency == "" {
+			segments[i].Currency = DefaultCurrency
+		}
+		price, err := ConvertPrice(segments[i].Price, segments[i].Currency, currency, rates)
+		if err != nil {
+			return err
+		}
+		segments[i].Price = price
+		segments[i].Currency = currency
+	}
+	return nil
+}
+
+// NeedsCurrencyConversion checks if some segment is not in currency
+func NeedsCurrencyConversion(segments []model.Segment, currency string) bool {
+	for _, segment := range segments {
+		segmentCurrency := segment.Currency
+		if segmentCurrency == "" {
+			segmentCurrency = DefaultCurrency
+		}
+		if segmentCurrency != currency {
+			return true
+		}
+	}
+	return false
+}
@@ -90,3 +90,46 @@ func ComputeTravelsNumberCoefficient(numTravels int) (model.Badge, error) {
 	}
 	return model.BadgeTravelsNumberLow, fmt.Errorf("no badge")
 }
+
+// ComputeUserBadges computes the badges of a user from their travels, only confirmed travels count
+func ComputeUserBadges(travels []model.TravelDetails) []model.Badge {
+	// empty slice if no badge
+	badges := []model.Badge{}
+
+	// compute data
+	totalDistance := 0.0
+	totalCO2Emitted := 0.0
+	totalCO2Compensated := 0.0
+	numTravels := 0
+	for _, travelDetails := range travels {
+		if travelDetails.Travel.Confirmed {
+			numTravels++
+			totalCO2Compensated += travelDetails.Travel.CO2Compensated
+
+			for _, segment := range travelDetails.Segments {
+				totalDistance += segment.Distance
+				totalCO2Emitted += segment.CO2Emitted
+			}
+		}
+	}
+
+	// compute badges
+	distanceBadge, err := ComputeDistanceBadge(totalDistance)
+	if err == nil {
+		badges = append(badges, distanceBadge)
+	}
+	ecologicalChoiceBadge, err := ComputeEcologicalChoiceBadge(totalDistance, totalCO2Emitted)
+	if err == nil {
+		badges = append(badges, ecologicalChoiceBadge)
+	}
+	compensationBadge, err := ComputeCompensationBadge(totalCO2Compensated, totalCO2Emitted)
+	if err == nil {
+		badges = append(badges, compensationBadge)
+	}
+	numTravelsBadge, err := ComputeTravelsNumberCoefficient(numTravels)
+	if err == nil {
+		badges = append(badges, numTravelsBadge)
+	}
+
+	return badges
+}
@@ -0,0 +1,162 @@
+package internals
+
+import (
+	"green-journey-server/model"
+	"math"
+	"sort"
+	"time"
+)
+
+// sort criteria of travel options
+const (
+	SortGreenest = "greenest"
+	SortCheapest = "cheapest"
+	SortFastest  = "fastest"
+	SortBalanced = "balanced"
+)
+
+// TravelOptionsFilter contains the constraints an option must satisfy, nil values are not checked
+type TravelOptionsFilter struct {
+	MaxPrice     *float64
+	MaxCO2       *float64
+	MaxDuration  *time.Duration
+	MaxTransfers *int
+	// if not empty, all the vehicles of an option must be in the list (walking is always allowed)
+	Vehicles []string
+}
+
+// FilterTravelOptions returns the options satisfying the filter, preserving their order
+func FilterTravelOptions(options [][]model.Segment, filter TravelOptionsFilter) [][]model.Segment {
+	filteredOptions := [][]model.Segment{}
+	for _, option := range options {
+		if satisfiesFilter(option, filter) {
+			filteredOptions = append(filteredOptions, option)
+		}
+	}
+	return filteredOptions
+}
+
+func satisfiesFilter(option []model.Segment, filter TravelOptionsFilter) bool {
+	price, co2Emitted, duration := ComputeOptionTotals(option)
+
+	if filter.MaxPrice != nil && price > *filter.MaxPrice {
+		return false
+	}
+	if filter.MaxCO2 != nil && co2Emitted > *filter.MaxCO2 {
+		return false
+	}
+	if filter.MaxDuration != nil && duration > *filter.MaxDuration {
+		return false
+	}
+	if filter.MaxTransfers != nil && ComputeTransfers(option) > *filter.MaxTransfers {
+		return false
+	}
+	if len(filter.Vehicles) > 0 {
+		for _, segment := range option {
+			if segment.Vehicle == "walk" {
+				continue
+			}
+			allowed := false
+			for _, vehicle := range filter.Vehicles {
+				if segment.Vehicle == vehicle {
+					allowed = true
+					break
+				}
+			}
+			if !allowed {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ComputeTransfers returns the number of changes of vehicle in an option, walking segments excluded
+func ComputeTransfers(option []model.Segment) int {
+	numVehicles := 0
+	for _, segment := range option {
+		if segment.Vehicle != "walk" {
+			numVehicles++
+		}
+	}
+	if numVehicles == 0 {
+		return 0
+	}
+	return numVehicles - 1
+}
+
+// IsValidSortKey reports whether sortBy is one of the sort criteria
+func IsValidSortKey(sortBy string) bool {
+	switch sortBy {
+	case SortGreenest, SortCheapest, SortFastest, SortBalanced:
+		return true
+	}
+	return false
+}
+
+// SortTravelOptions sorts the options in place according to the sort criterion,
+// which must be checked with IsValidSortKey: with any other value the order is unchanged
+func SortTravelOptions(options [][]model.Segment, sortBy string) {
+	prices := make([]float64, len(options))
+	co2Emissions := make([]float64, len(options))
+	durations := make([]float64, len(options))
+	for i, option := range options {
+		price, co2Emitted, duration := ComputeOptionTotals(option)
+		prices[i] = price
+		co2Emissions[i] = co2Emitted
+		durations[i] = duration.Minutes()
+	}
+
+	var keys []float64
+	switch sortBy {
+	case SortGreenest:
+		keys = co2Emissions
+	case SortCheapest:
+		keys = prices
+	case SortFastest:
+		keys = durations
+	case SortBalanced:
+		// every value is normalized in [0, 1], the key is the sum of the normalized values
+		normalizedPrices := normalize(prices)
+		normalizedCO2Emissions := normalize(co2Emissions)
+		normalizedDurations := normalize(durations)
+		keys = make([]float64, len(options))
+		for i := range options {
+			keys[i] = normalizedPrices[i] + normalizedCO2Emissions[i] + normalizedDurations[i]
+		}
+	default:
+		return
+	}
+
+	// sort indexes, then reorder options
+	indexes := make([]int, len(options))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return keys[indexes[i]] < keys[indexes[j]]
+	})
+	sortedOptions := make([][]model.Segment, len(options))
+	for i, index := range indexes {
+		sortedOptions[i] = options[index]
+	}
+	copy(options, sortedOptions)
+}
+
+// normalize maps the values in [0, 1], using min and max values
+func normalize(values []float64) []float64 {
+	minValue := math.Inf(1)
+	maxValue := math.Inf(-1)
+	for _, value := range values {
+		minValue = math.Min(minValue, value)
+		maxValue = math.Max(maxValue, value)
+	}
+
+	normalizedValues := make([]float64, len(values))
+	for i, value := range values {
+		if maxValue > minValue {
+			normalizedValues[i] = (value - minValue) / (maxValue - minValue)
+		}
+	}
+	return normalizedValues
+}
@@ -0,0 +1,247 @@
+package internals
+
+import (
+	"encoding/csv"
+	"fmt"
+	"green-journey-server/model"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DatasetCity is a city read from a cities dataset, with its line for the import report
+type DatasetCity struct {
+	Line int
+	City model.City
+}
+
+// DatasetAirport is an airport read from an airports dataset, the city of the airport is found
+// by city iata and country code or, if the city iata is missing, by municipality and country code
+type DatasetAirport struct {
+	Line         int
+	Airport      model.Airport
+	CityIata     string
+	CountryCode  string
+	Municipality string
+}
+
+// accepted names of the dataset columns, common alternatives such as the OurAirports names are accepted as well
+var (
+	cityNameColumns     = []string{"city_name", "name", "asciiname"}
+	cityIataColumns     = []string{"city_iata", "iata", "iata_code"}
+	countryCodeColumns  = []string{"country_code", "country code", "iso_country"}
+	countryNameColumns  = []string{"country_name", "country"}
+	continentColumns    = []string{"continent"}
+	latitudeColumns     = []string{"latitude", "latitude_deg"}
+	longitudeColumns    = []string{"longitude", "longitude_deg"}
+	airportNameColumns  = []string{"airport_name", "name"}
+	airportIataColumns  = []string{"airport_iata", "iata_code"}
+	airportTypeColumns  = []string{"type"}
+	municipalityColumns = []string{"municipality"}
+)
+
+// ParseCitiesDataset reads a csv or tsv cities file with a header: city name, iata and country code
+// are required, country name, continent and coordinates are optional. Raw GeoNames dumps have
+// neither a header nor an iata column, so they must be converted first.
+// Rows without iata can't be matched and are skipped, their number is returned
+func ParseCitiesDataset(reader io.Reader) ([]DatasetCity, int, error) {
+	records, header, err := readDataset(reader)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	nameColumn := header.find(cityNameColumns)
+	iataColumn := header.find(cityIataColumns)
+	countryCodeColumn := header.find(countryCodeColumns)
+	if nameColumn < 0 || iataColumn < 0 || countryCodeColumn < 0 {
+		return nil, 0, fmt.Errorf("cities dataset needs city name, iata and country code columns")
+	}
+	countryNameColumn := header.find(countryNameColumns)
+	continentColumn := header.find(continentColumns)
+	latitudeColumn := header.find(latitudeColumns)
+	longitudeColumn := header.find(longitudeColumns)
+
+	var cities []DatasetCity
+	skipped := 0
+	for i, record := range records {
+		// the header is line 1
+		line := i + 2
+		cityIata := strings.ToUpper(field(record, iataColumn))
+		countryCode := strings.ToUpper(field(record, countryCodeColumn))
+		cityName := field(record, nameColumn)
+		if cityIata == "" || countryCode == "" {
+			skipped++
+			continue
+		}
+		if cityName == "" {
+			return nil, 0, fmt.Errorf("line %d: missing city name", line)
+		}
+
+		city := model.City{
+			CityIata:    &cityIata,
+			CityName:    cityName,
+			CountryCode: &countryCode,
+			CountryName: optionalField(record, countryNameColumn),
+			Continent:   optionalField(record, continentColumn),
+		}
+		latitude, longitude, err := parseCoordinates(record, latitudeColumn, longitudeColumn)
+		if err != nil {
+			return nil, 0, fmt.Errorf("line %d: %w", line, err)
+		}
+		if latitude != nil && longitude != nil {
+			city.Latitude = latitude
+			city.Longitude = longitude
+		}
+		cities = append(cities, DatasetCity{Line: line, City: city})
+	}
+
+	return cities, skipped, nil
+}
+
+// ParseAirportsDataset reads a csv or tsv airports file with a header, e.g. the OurAirports airports.csv:
+// airport name, iata, coordinates and country code are required, the city is given by a city_iata
+// column or by the municipality. Rows without iata and closed airports are skipped, their number is returned
+func ParseAirportsDataset(reader io.Reader) ([]DatasetAirport, int, error) {
+	records, header, err := readDataset(reader)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	nameColumn := header.find(airportNameColumns)
+	iataColumn := header.find(airportIataColumns)
+	countryCodeColumn := header.find(countryCodeColumns)
+	latitudeColumn := header.find(latitudeColumns)
+	longitudeColumn := header.find(longitudeColumns)
+	if nameColumn < 0 || iataColumn < 0 || countryCodeColumn < 0 || latitudeColumn < 0 || longitudeColumn < 0 {
+		return nil, 0, fmt.Errorf("airports dataset needs airport name, iata, country code and coordinates columns")
+	}
+	cityIataColumn := header.find([]string{"city_iata"})
+	municipalityColumn := header.find(municipalityColumns)
+	if cityIataColumn < 0 && municipalityColumn < 0 {
+		return nil, 0, fmt.Errorf("airports dataset needs a city iata or a municipality column")
+	}
+	typeColumn := header.find(airportTypeColumns)
+
+	var airports []DatasetAirport
+	skipped := 0
+	for i, record := range records {
+		line := i + 2
+		airportIata := strings.ToUpper(field(record, iataColumn))
+		countryCode := strings.ToUpper(field(record, countryCodeColumn))
+		if airportIata == "" || countryCode == "" || field(record, typeColumn) == "closed" {
+			skipped++
+			continue
+		}
+		airportName := field(record, nameColumn)
+		if airportName == "" {
+			return nil, 0, fmt.Errorf("line %d: missing airport name", line)
+		}
+
+		latitude, longitude, err := parseCoordinates(record, latitudeColumn, longitudeColumn)
+		if err != nil {
+			return nil, 0, fmt.Errorf("line %d: %w", line, err)
+		}
+		if latitude == nil || longitude == nil {
+			return nil, 0, fmt.Errorf("line %d: missing airport coordinates", line)
+		}
+
+		airport := DatasetAirport{
+			Line: line,
+			Airport: model.Airport{
+				AirportName: airportName,
+				AirportIata: airportIata,
+				Latitude:    *latitude,
+				Longitude:   *longitude,
+			},
+			CityIata:     strings.ToUpper(field(record, cityIataColumn)),
+			CountryCode:  countryCode,
+			Municipality: field(record, municipalityColumn),
+		}
+		if airport.CityIata == "" && airport.Municipality == "" {
+			return nil, 0, fmt.Errorf("line %d: missing airport city", line)
+		}
+		airports = append(airports, airport)
+	}
+
+	return airports, skipped, nil
+}
+
+// datasetHeader maps the lower case column names to their index
+type datasetHeader map[string]int
+
+// find returns the index of the first of the names in the header, -1 if none is present
+func (header datasetHeader) find(names []string) int {
+	for _, name := range names {
+		if index, ok := header[name]; ok {
+			return index
+		}
+	}
+	return -1
+}
+
+// readDataset reads the records of a dataset, comma or tab separated as detected from the header
+func readDataset(reader io.Reader) ([][]string, datasetHeader, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	content := strings.TrimPrefix(string(data), "\ufeff")
+	firstLine, _, _ := strings.Cut(content, "\n")
+
+	csvReader := csv.NewReader(strings.NewReader(content))
+	if strings.Contains(firstLine, "\t") && !strings.Contains(firstLine, ",") {
+		// tab separated files usually don't escape quotes
+		csvReader.Comma = '\t'
+		csvReader.LazyQuotes = true
+	}
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("empty dataset")
+	}
+
+	header := datasetHeader{}
+	for i, column := range records[0] {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if _, ok := header[column]; !ok {
+			header[column] = i
+		}
+	}
+	return records[1:], header, nil
+}
+
+// field returns the trimmed value of a column, empty if the column is missing
+func field(record []string, column int) string {
+	if column < 0 || column >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[column])
+}
+
+func optionalField(record []string, column int) *string {
+	value := field(record, column)
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// parseCoordinates returns the coordinates of a row, nil if they are missing
+func parseCoordinates(record []string, latitudeColumn, longitudeColumn int) (*float64, *float64, error) {
+	latitudeStr := field(record, latitudeColumn)
+	longitudeStr := field(record, longitudeColumn)
+	if latitudeStr == "" || longitudeStr == "" {
+		return nil, nil, nil
+	}
+	latitude, err := strconv.ParseFloat(latitudeStr, 64)
+	if err != nil || latitude < -90 || latitude > 90 {
+		return nil, nil, fmt.Errorf("invalid latitude %s", latitudeStr)
+	}
+	longitude, err := strconv.ParseFloat(longitudeStr, 64)
+	if err != nil || longitude < -180 || longitude > 180 {
+		return nil, nil, fmt.Errorf("invalid longitude %s", longitudeStr)
+	}
+	return &latitude, &longitude, nil
+}
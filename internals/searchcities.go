@@ -0,0 +1,133 @@
+package internals
+
+import (
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+	"green-journey-server/model"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// match kinds, lower is better
+const (
+	matchExactName = iota
+	matchNamePrefix
+	matchNameWordPrefix
+	matchCountryPrefix
+	matchFuzzyName
+	noMatch
+)
+
+// FoldText lowers the case and removes the accents of a text, e.g. "Zürich" becomes "zurich"
+func FoldText(text string) string {
+	folder := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(folder, text)
+	if err != nil {
+		folded = text
+	}
+	return strings.ToLower(strings.TrimSpace(folded))
+}
+
+// SearchCities returns the cities matching the query, best matches first: the city name is matched
+// by prefix, also on single words, with some typos for long queries, the country name by prefix.
+// Among equal matches, cities with an iata code and more airports come first
+func SearchCities(cities []model.City, numAirports map[int]int, query string) []model.City {
+	foldedQuery := FoldText(query)
+	if foldedQuery == "" {
+		return []model.City{}
+	}
+
+	matches := make(map[int]int)
+	var results []model.City
+	for _, city := range cities {
+		match := matchCity(city, foldedQuery)
+		if match == noMatch {
+			continue
+		}
+		matches[city.CityID] = match
+		results = append(results, city)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		first, second := results[i], results[j]
+		if matches[first.CityID] != matches[second.CityID] {
+			return matches[first.CityID] < matches[second.CityID]
+		}
+		if (first.CityIata != nil) != (second.CityIata != nil) {
+			return first.CityIata != nil
+		}
+		if numAirports[first.CityID] != numAirports[second.CityID] {
+			return numAirports[first.CityID] > numAirports[second.CityID]
+		}
+		return first.CityName < second.CityName
+	})
+
+	if results == nil {
+		results = []model.City{}
+	}
+	return results
+}
+
+func matchCity(city model.City, foldedQuery string) int {
+	name := FoldText(city.CityName)
+	if name == foldedQuery {
+		return matchExactName
+	}
+	if strings.HasPrefix(name, foldedQuery) {
+		return matchNamePrefix
+	}
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool {
+		return r == ' ' || r == '-' || r == '\''
+	}) {
+		if strings.HasPrefix(word, foldedQuery) {
+			return matchNameWordPrefix
+		}
+	}
+	if city.CountryName != nil && strings.HasPrefix(FoldText(*city.CountryName), foldedQuery) {
+		return matchCountryPrefix
+	}
+
+	// typos are allowed only for long queries, otherwise almost everything would match
+	maxTypos := 0
+	if len([]rune(foldedQuery)) >= 8 {
+		maxTypos = 2
+	} else if len([]rune(foldedQuery)) >= 4 {
+		maxTypos = 1
+	}
+	if maxTypos > 0 {
+		nameRunes := []rune(name)
+		queryRunes := []rune(foldedQuery)
+		// compare the query with the name prefix of the same length
+		if len(nameRunes) > len(queryRunes) {
+			nameRunes = nameRunes[:len(queryRunes)]
+		}
+		if levenshteinDistance(nameRunes, queryRunes) <= maxTypos {
+			return matchFuzzyName
+		}
+	}
+
+	return noMatch
+}
+
+// levenshteinDistance returns the number of insertions, deletions and substitutions turning a into b
+func levenshteinDistance(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
@@ -3,8 +3,10 @@ package main
 import (
 	"context"
 	"flag"
+	"green-journey-server/config"
 	"green-journey-server/db"
 	"green-journey-server/externals"
+	"green-journey-server/internals"
 	"green-journey-server/mockservers"
 	"io"
 	"log"
@@ -15,6 +17,7 @@ import (
 )
 
 var shutdownTimeout = 10 * time.Second
+var configPath string
 var port string
 var testMode string
 var mockOptions bool
@@ -22,13 +25,15 @@ var logger bool
 
 func readCommandLineArguments() {
 	// read arguments
-	portArg := flag.String("port", "443", "Port on which the server listens")
+	configPathArg := flag.String("config", "", "Config file, "+config.DefaultPath+" if present")
+	portArg := flag.String("port", "", "Port on which the server listens, overrides the config")
 	testModeArg := flag.String("test_mode", "default", "Test mode")
 	mockOptionsArg := flag.Bool("mock_options", false, "Mock options")
 	loggerArg := flag.Bool("logger", false, "Enable logger")
 
 	flag.Parse()
 
+	configPath = *configPathArg
 	port = *portArg
 	testMode = *testModeArg
 	mockOptions = *mockOptionsArg
@@ -42,11 +47,36 @@ func readCommandLineArguments() {
 }
 
 func main() {
+	// subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			runMigrateCommand(os.Args[2:])
+			return
+		case "import":
+			runImportCommand(os.Args[2:])
+			return
+		}
+	}
+
 	// read command line arguments
 	readCommandLineArguments()
 
+	// load and validate the config, the server doesn't start with an invalid one
+	serverConfig, err := config.Load(configPath)
+	if err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
+	if port != "" {
+		serverConfig.Server.Port = port
+	}
+	err = serverConfig.Validate()
+	if err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+
 	// init db
-	database, err := db.InitDB(testMode)
+	database, err := db.InitDB(serverConfig.Database, testMode)
 	if err != nil || database == nil {
 		log.Fatalf("Error initializing database: %v", err)
 	}
@@ -55,33 +85,72 @@ func main() {
 		db.CloseDBConnection()
 	}()
 
+	// bring the schema up to date
+	appliedMigrations, err := db.MigrateUp(database)
+	if err != nil {
+		log.Fatalf("Error applying migrations: %v", err)
+	}
+	for _, migration := range appliedMigrations {
+		log.Printf("Applied migration %04d_%s", migration.Version, migration.Name)
+	}
+
+	// repositories used by the apis and the handlers
+	repositories := db.NewRepositories(database)
+
 	// init apis
-	externals.InitGoogleMapsApi()
-	externals.InitAmadeusApi(mockOptions)
+	priceApis := externals.NewPriceApis(serverConfig.MockApis)
+	googleMapsApi := externals.NewGoogleMapsApi(serverConfig.GoogleMaps, repositories.Cities, priceApis)
+	amadeusApi := externals.NewAmadeusApi(serverConfig.Amadeus, repositories.Cities, mockOptions)
+
+	// load emission factors, the bundled ones are used for the files that are not configured
+	if serverConfig.Emission.FactorsFile != "" || serverConfig.Emission.RailFactorsFile != "" {
+		emissionModel, err := internals.LoadEmissionModel(serverConfig.Emission.FactorsFile, serverConfig.Emission.RailFactorsFile)
+		if err != nil {
+			log.Fatalf("Error loading emission factors: %v", err)
+		}
+		internals.SetEmissionModel(emissionModel)
+	}
+
+	// register travel providers used by the search, with their deadlines
+	externals.RegisterDefaultTravelProviders(repositories.Cities, googleMapsApi, amadeusApi)
+	externals.SetTravelProviderTimeouts(serverConfig.Timeouts)
 
-	// start mock servers in new go routines
-	go mockservers.StartTollApiServer()
-	go mockservers.StartTransitCostApiServer()
-	go mockservers.StartFuelCostApiServer()
+	// start mock servers in new go routines, unless the apis are served elsewhere
+	mockApis := serverConfig.MockApis
+	if mockApis.Toll.ListenAddr != "" {
+		go mockservers.StartTollApiServer(mockApis.Toll.ListenAddr)
+	}
+	if mockApis.TransitCost.ListenAddr != "" {
+		go mockservers.StartTransitCostApiServer(mockApis.TransitCost.ListenAddr)
+	}
+	if mockApis.FuelCost.ListenAddr != "" {
+		go mockservers.StartFuelCostApiServer(mockApis.FuelCost.ListenAddr)
+	}
+	if mockApis.ExchangeRate.ListenAddr != "" {
+		go mockservers.StartExchangeRateApiServer(mockApis.ExchangeRate.ListenAddr)
+	}
 
 	// get access token amadeus api
-	err = externals.GetAccessToken()
+	err = amadeusApi.GetAccessToken(context.Background())
 	if err != nil {
 		log.Fatalf("Failed to get amadeus api access token: %v", err)
 		return
 	}
 
-	// initialize firebase
-	externals.InitializeFirebase(testMode)
+	// initialize authentication, test mode uses a local token issuer
+	err = externals.InitAuthVerifier(testMode, serverConfig.Auth)
+	if err != nil {
+		log.Fatalf("Failed to initialize authentication: %v", err)
+	}
 
 	// setup routes and handlers
-	server := SetupServer(port)
+	server := SetupServer(serverConfig.Server, serverConfig.Auth, repositories, priceApis)
 
 	// start server
 	go func() {
-		log.Printf("Server starting on port %s", port)
+		log.Printf("Server starting on port %s", serverConfig.Server.Port)
 
-		err = server.ListenAndServeTLS("GreenJourneyServerCertificate.crt", "GreenJourneyServerKey.key")
+		err = server.ListenAndServeTLS(serverConfig.Server.TLSCertFile, serverConfig.Server.TLSKeyFile)
 		if err != nil {
 			// fatal condition
 			log.Fatalf("Failed to start the server")
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"green-journey-server/config"
+	"green-journey-server/db"
+	"log"
+	"strconv"
+)
+
+// runMigrateCommand runs the migrate subcommand:
+// `migrate [-config=config.yaml] [-test_mode=real|test] up`, `migrate down [steps]` or `migrate status`
+func runMigrateCommand(args []string) {
+	flagSet := flag.NewFlagSet("migrate", flag.ExitOnError)
+	configPathArg := flagSet.String("config", "", "Config file, "+config.DefaultPath+" if present")
+	testModeArg := flagSet.String("test_mode", "real", "Test mode")
+	err := flagSet.Parse(args)
+	if err != nil {
+		log.Fatalf("Invalid arguments: %v", err)
+	}
+
+	// only the database config is needed
+	commandConfig, err := config.Load(*configPathArg)
+	if err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
+	err = commandConfig.Database.Validate()
+	if err != nil {
+		log.Fatalf("Invalid database config: %v", err)
+	}
+
+	database, err := db.InitDB(commandConfig.Database, *testModeArg)
+	if err != nil || database == nil {
+		log.Fatalf("Error initializing database: %v", err)
+	}
+	defer func() {
+		db.CloseDBConnection()
+	}()
+
+	action := flagSet.Arg(0)
+	switch action {
+	case "", "up":
+		appliedMigrations, err := db.MigrateUp(database)
+		for _, migration := range appliedMigrations {
+			log.Printf("Applied migration %04d_%s", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatalf("Error applying migrations: %v", err)
+		}
+		if len(appliedMigrations) == 0 {
+			log.Println("No pending migrations")
+		}
+	case "down":
+		// revert the last migration, unless a number of steps is given
+		steps := 1
+		if flagSet.Arg(1) != "" {
+			steps, err = strconv.Atoi(flagSet.Arg(1))
+			if err != nil || steps < 1 {
+				log.Fatalf("Invalid number of steps: %s", flagSet.Arg(1))
+			}
+		}
+		revertedMigrations, err := db.MigrateDown(database, steps)
+		for _, migration := range revertedMigrations {
+			log.Printf("Reverted migration %04d_%s", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatalf("Error reverting migrations: %v", err)
+		}
+	case "status":
+		migrations, err := db.LoadMigrations()
+		if err != nil {
+			log.Fatalf("Error loading migrations: %v", err)
+		}
+		appliedMigrations, err := db.GetAppliedMigrations(database)
+		if err != nil {
+			log.Fatalf("Error reading applied migrations: %v", err)
+		}
+		applied := map[int]bool{}
+		for _, appliedMigration := range appliedMigrations {
+			applied[appliedMigration.Version] = true
+		}
+		for _, migration := range migrations {
+			status := "pending"
+			if applied[migration.Version] {
+				status = "applied"
+			}
+			log.Printf("%04d_%s: %s", migration.Version, migration.Name, status)
+		}
+	default:
+		log.Fatalf("Unknown migrate action: %s", action)
+	}
+}
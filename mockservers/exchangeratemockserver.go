@@ -0,0 +1,51 @@
+package mockservers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// value of 1 EUR in every currency
+var exchangeRates = map[string]float64{
+	"EUR": 1,
+	"USD": 1.08,
+	"GBP": 0.85,
+	"CHF": 0.95,
+	"JPY": 160,
+	"PLN": 4.3,
+	"CZK": 25.2,
+	"HUF": 395,
+	"SEK": 11.3,
+	"NOK": 11.6,
+	"DKK": 7.46,
+}
+
+func StartExchangeRateApiServer(listenAddr string) {
+	http.HandleFunc("/exchangerateapi", ExchangeRateApiHandler)
+
+	log.Println("Exchange rate API server starting on", listenAddr)
+
+	err := http.ListenAndServe(listenAddr, nil)
+	if err != nil {
+		// fatal condition
+		log.Fatal("Failed to start Exchange rate API server")
+	}
+}
+
+func ExchangeRateApiHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"base":  "EUR",
+		"rates": exchangeRates,
+	})
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "error while writing the response", http.StatusInternalServerError)
+	}
+}
@@ -6,12 +6,12 @@ import (
 	"strconv"
 )
 
-func StartFuelCostApiServer() {
+func StartFuelCostApiServer(listenAddr string) {
 	http.HandleFunc("/fuelcostapi", FuelCostApiHandler)
 
-	log.Println("Fuel cost API server starting on port 8083")
+	log.Println("Fuel cost API server starting on", listenAddr)
 
-	err := http.ListenAndServe(":8083", nil)
+	err := http.ListenAndServe(listenAddr, nil)
 	if err != nil {
 		// fatal condition
 		log.Fatal("Failed to start Fuel cost API server")
@@ -24,10 +24,19 @@ func FuelCostApiHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// cost of a liter, or of a kWh for electric vehicles
 	fuelCost := 1.8
+	switch r.URL.Query().Get("fuel_type") {
+	case "diesel":
+		fuelCost = 1.7
+	case "lpg":
+		fuelCost = 0.75
+	case "ev":
+		fuelCost = 0.3
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	_, err := w.Write([]byte(`{"toll-cost": ` + strconv.FormatFloat(fuelCost, 'f', 2, 64) + `}`))
+	_, err := w.Write([]byte(`{"fuel-cost": ` + strconv.FormatFloat(fuelCost, 'f', 2, 64) + `}`))
 	if err != nil {
 		log.Println(err)
 		http.Error(w, "error while writing the response", http.StatusInternalServerError)
@@ -8,12 +8,12 @@ import (
 
 var tollCostPerKm = 0.09
 
-func StartTollApiServer() {
+func StartTollApiServer(listenAddr string) {
 	http.HandleFunc("/tollapi", TollApiHandler)
 
-	log.Println("Toll API server starting on port 8081")
+	log.Println("Toll API server starting on", listenAddr)
 
-	err := http.ListenAndServe(":8081", nil)
+	err := http.ListenAndServe(listenAddr, nil)
 	if err != nil {
 		// fatal condition
 		log.Fatal("Failed to start Toll API server")
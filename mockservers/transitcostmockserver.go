@@ -9,12 +9,12 @@ import (
 var trainCostPerKm = 0.11
 var busCostPerKm = 0.07
 
-func StartTransitCostApiServer() {
+func StartTransitCostApiServer(listenAddr string) {
 	http.HandleFunc("/transitcostapi", TransitCostApiHandler)
 
-	log.Println("Transit cost API server starting on port 8082")
+	log.Println("Transit cost API server starting on", listenAddr)
 
-	err := http.ListenAndServe(":8082", nil)
+	err := http.ListenAndServe(listenAddr, nil)
 	if err != nil {
 		// fatal condition
 		log.Fatal("Failed to start Transit Cost API server")
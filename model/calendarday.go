@@ -0,0 +1,18 @@
+package model
+
+// CalendarDay contains the best values found for each vehicle on a departure day
+type CalendarDay struct {
+	Date             string          `json:"date"`
+	Vehicles         []CalendarEntry `json:"vehicles"`
+	SkippedProviders []string        `json:"skipped_providers"`
+}
+
+// CalendarEntry contains the cheapest price and the lowest co2 among the options of a vehicle,
+// the two values can come from different options
+type CalendarEntry struct {
+	Vehicle    string  `json:"vehicle"`
+	MinPrice   float64 `json:"min_price"`
+	Currency   string  `json:"currency"`
+	MinCO2     float64 `json:"min_co2"`
+	NumOptions int     `json:"num_options"`
+}
@@ -0,0 +1,27 @@
+package model
+
+// cabin classes of a flight
+const (
+	CabinClassEconomy        = "economy"
+	CabinClassPremiumEconomy = "premium_economy"
+	CabinClassBusiness       = "business"
+	CabinClassFirst          = "first"
+)
+
+// EmissionBreakdown explains the co2 of a flight segment:
+// co2 = distance * distance uplift * base factor * cabin multiplier * radiative forcing multiplier
+type EmissionBreakdown struct {
+	DistanceBand string `json:"distance_band"`
+	// great circle distance [km]
+	Distance float64 `json:"distance"`
+	// correction for the actual route, longer than the great circle
+	DistanceUplift float64 `json:"distance_uplift"`
+	// kg of co2 per km of an economy seat in the distance band
+	BaseFactor float64 `json:"base_factor"`
+	CabinClass string  `json:"cabin_class"`
+	// share of the aircraft taken by a seat of the cabin class, with respect to economy
+	CabinMultiplier float64 `json:"cabin_multiplier"`
+	// effect of non co2 emissions at altitude, 1 if not requested
+	RadiativeForcingMultiplier float64 `json:"radiative_forcing_multiplier"`
+	CO2Emitted                 float64 `json:"co2_emitted"`
+}
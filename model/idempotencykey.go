@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// IdempotencyKey stores the response of a request sent with an Idempotency-Key header,
+// so that retries of the same request return it again instead of repeating the request
+type IdempotencyKey struct {
+	IdempotencyKeyID int    `gorm:"column:id_idempotency_key;primaryKey;autoIncrement" json:"idempotency_key_id"`
+	UserID           int    `gorm:"column:id_user;type:integer;not null;uniqueIndex:idx_idempotency_key_user_key;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"user_id"`
+	Key              string `gorm:"column:key;type:text;not null;uniqueIndex:idx_idempotency_key_user_key" json:"key"`
+	// hash of the request payload, a retry must have the same payload
+	RequestHash string `gorm:"column:request_hash;type:text;not null" json:"request_hash"`
+	// response status code and body, 0 and empty while the request is in progress
+	StatusCode   int       `gorm:"column:status_code;type:integer;not null;default:0" json:"status_code"`
+	ResponseBody []byte    `gorm:"column:response_body;type:bytea" json:"response_body"`
+	ExpiresAt    time.Time `gorm:"column:expires_at;type:timestamptz;not null" json:"expires_at"`
+}
+
+func (IdempotencyKey) TableName() string {
+	return "idempotency_key"
+}
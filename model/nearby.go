@@ -0,0 +1,13 @@
+package model
+
+// NearbyCity is a city found by a radius search, with its distance from the searched point [km]
+type NearbyCity struct {
+	City
+	Distance float64 `json:"distance"`
+}
+
+// NearbyAirport is an airport found by a radius search, with its distance from the searched point [km]
+type NearbyAirport struct {
+	Airport
+	Distance float64 `json:"distance"`
+}
@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// RoundTripOption pairs an outward option with a return option,
+// totals refer to both of them
+type RoundTripOption struct {
+	Outward    []Segment     `json:"outward"`
+	Return     []Segment     `json:"return"`
+	Price      float64       `json:"price"`
+	Currency   string        `json:"currency"`
+	CO2Emitted float64       `json:"co2_emitted"`
+	Duration   time.Duration `json:"duration"`
+}
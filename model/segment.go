@@ -15,11 +15,17 @@ type Segment struct {
 	Vehicle            string        `gorm:"column:vehicle;type:text;not null" json:"vehicle"`
 	Description        string        `gorm:"column:description;type:text" json:"description"`
 	Price              float64       `gorm:"column:price;type:numeric;not null" json:"price"`
+	Currency           string        `gorm:"column:currency;type:text;not null;default:'EUR'" json:"currency"`
 	CO2Emitted         float64       `gorm:"column:co2_emitted;type:numeric;not null" json:"co2_emitted"`
 	Distance           float64       `gorm:"column:distance;type:numeric;not null" json:"distance"`
 	NumSegment         int           `gorm:"column:num_segment;type:integer;not null" json:"num_segment"`
 	IsOutward          bool          `gorm:"column:is_outward;type:boolean;not null" json:"is_outward"`
-	TravelID           int           `gorm:"column:id_travel;type:integer;not null;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"travel_id"`
+	LegIndex           int           `gorm:"column:leg_index;type:integer;not null;default:0" json:"leg_index"`
+	// version of the emission model used to compute CO2Emitted
+	EmissionMethodology string `gorm:"column:emission_methodology;type:text" json:"emission_methodology"`
+	// explanation of the co2 of flight segments, not stored
+	EmissionBreakdown *EmissionBreakdown `gorm:"-" json:"emission_breakdown"`
+	TravelID          int                `gorm:"column:id_travel;type:integer;not null;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"travel_id"`
 }
 
 func (Segment) TableName() string {
@@ -6,6 +6,8 @@ type Travel struct {
 	Confirmed      bool    `gorm:"column:confirmed;type:bool;not null" json:"confirmed"`
 	UserID         int     `gorm:"column:id_user;not null;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"user_id"`
 	UserReview     *Review `gorm:"-" json:"user_review"`
+	// reviews of all the cities visited by the travel, UserReview is the one of the first destination
+	UserReviews []Review `gorm:"-" json:"user_reviews"`
 }
 
 func (Travel) TableName() string {
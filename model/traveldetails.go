@@ -5,20 +5,59 @@ type TravelDetails struct {
 	Segments []Segment `json:"segments"`
 }
 
-func (td *TravelDetails) GetDestinationSegment() *Segment {
-	// find the last outward segment
-	maxOutwardSegment := -1
-	destinationSegment := Segment{}
-	for i := range td.Segments {
-		if td.Segments[i].IsOutward && td.Segments[i].NumSegment > maxOutwardSegment {
-			maxOutwardSegment = td.Segments[i].NumSegment
-			destinationSegment = td.Segments[i]
+// GetVisitedCityIDs returns the destination of every leg, in leg order and without duplicates:
+// the departure city of the travel is not visited, e.g. the return of a round trip
+func (td *TravelDetails) GetVisitedCityIDs() []int {
+	// find first and last segment of every leg
+	firstSegments := map[int]Segment{}
+	lastSegments := map[int]Segment{}
+	maxLegIndex := -1
+	for _, segment := range td.Segments {
+		first, ok := firstSegments[segment.LegIndex]
+		if !ok || segment.NumSegment < first.NumSegment {
+			firstSegments[segment.LegIndex] = segment
+		}
+		last, ok := lastSegments[segment.LegIndex]
+		if !ok || segment.NumSegment > last.NumSegment {
+			lastSegments[segment.LegIndex] = segment
+		}
+		if segment.LegIndex > maxLegIndex {
+			maxLegIndex = segment.LegIndex
+		}
+	}
+
+	var visitedCityIDs []int
+	departureCityID := -1
+	found := map[int]bool{}
+	for legIndex := 0; legIndex <= maxLegIndex; legIndex++ {
+		last, ok := lastSegments[legIndex]
+		if !ok {
+			continue
+		}
+		if departureCityID == -1 {
+			departureCityID = firstSegments[legIndex].DepartureId
+		}
+		if last.DestinationId == departureCityID || found[last.DestinationId] {
+			continue
 		}
+		found[last.DestinationId] = true
+		visitedCityIDs = append(visitedCityIDs, last.DestinationId)
 	}
 
-	if maxOutwardSegment == -1 {
-		return nil
-	} else {
-		return &destinationSegment
+	return visitedCityIDs
+}
+
+// SetLegacyLegIndexes handles travels created before multi-city support, whose segments have
+// leg index 0 also on the return: if no segment has a leg index, return segments are moved to leg 1
+func (td *TravelDetails) SetLegacyLegIndexes() {
+	for i := range td.Segments {
+		if td.Segments[i].LegIndex != 0 {
+			return
+		}
+	}
+	for i := range td.Segments {
+		if !td.Segments[i].IsOutward {
+			td.Segments[i].LegIndex = 1
+		}
 	}
 }
@@ -0,0 +1,11 @@
+package model
+
+// TravelOptionSummary describes how a travel option compares with the other options of the same search
+type TravelOptionSummary struct {
+	Labels        []string `json:"labels"`
+	ParetoOptimal bool     `json:"pareto_optimal"`
+	// kg of co2 saved with respect to the least emitting plane and car options,
+	// nil if the search has no such option
+	CO2SavedVsPlane *float64 `json:"co2_saved_vs_plane"`
+	CO2SavedVsCar   *float64 `json:"co2_saved_vs_car"`
+}
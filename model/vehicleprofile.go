@@ -0,0 +1,24 @@
+package model
+
+// fuel types of a vehicle profile
+const (
+	FuelTypePetrol   = "petrol"
+	FuelTypeDiesel   = "diesel"
+	FuelTypeHybrid   = "hybrid"
+	FuelTypeElectric = "ev"
+	FuelTypeLPG      = "lpg"
+)
+
+// VehicleProfile describes a car of a user: consumption is in l/100 km, or kWh/100 km for electric vehicles
+type VehicleProfile struct {
+	VehicleProfileID int     `gorm:"column:id_vehicle_profile;primaryKey;autoIncrement" json:"vehicle_profile_id"`
+	UserID           int     `gorm:"column:id_user;type:integer;not null;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"user_id"`
+	Name             string  `gorm:"column:name;type:text;not null" json:"name"`
+	FuelType         string  `gorm:"column:fuel_type;type:text;not null" json:"fuel_type"`
+	Consumption      float64 `gorm:"column:consumption;type:numeric;not null" json:"consumption"`
+	Seats            int     `gorm:"column:seats;type:integer;not null" json:"seats"`
+}
+
+func (VehicleProfile) TableName() string {
+	return "vehicle_profile"
+}
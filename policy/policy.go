@@ -0,0 +1,93 @@
+package policy
+
+import (
+	"errors"
+	"green-journey-server/db"
+	"green-journey-server/model"
+)
+
+// errors returned by the checks, the handlers map them to http status codes
+var (
+	ErrNotFound  = errors.New("resource not found")
+	ErrForbidden = errors.New("resource owned by another user")
+)
+
+// Policy checks the ownership of the stored resources, read from the repositories
+type Policy struct {
+	travels         db.TravelRepository
+	reviews         db.ReviewRepository
+	vehicleProfiles db.VehicleProfileRepository
+}
+
+func NewPolicy(travels db.TravelRepository, reviews db.ReviewRepository, vehicleProfiles db.VehicleProfileRepository) *Policy {
+	return &Policy{
+		travels:         travels,
+		reviews:         reviews,
+		vehicleProfiles: vehicleProfiles,
+	}
+}
+
+// CanCreateFor checks that the user creates a resource owned by themselves
+func CanCreateFor(user model.User, ownerID int) error {
+	if ownerID != user.UserID {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// CanModifyUser checks that the user modifies their own account, the stored row is the one of userID
+func CanModifyUser(user model.User, userID int) error {
+	if userID != user.UserID {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// CanModifyTravel checks that the user owns the stored travel, which is returned:
+// the owner is read from the db, never from the request
+func (policy *Policy) CanModifyTravel(user model.User, travelID int) (model.Travel, error) {
+	travel, err := policy.travels.GetTravelById(travelID)
+	if err != nil {
+		return model.Travel{}, notFoundOrError(err)
+	}
+	if travel.UserID != user.UserID {
+		return model.Travel{}, ErrForbidden
+	}
+	return travel, nil
+}
+
+// CanModifyReview checks that the user owns the stored review, which is returned
+func (policy *Policy) CanModifyReview(user model.User, reviewID int) (model.Review, error) {
+	review, err := policy.reviews.GetReviewById(reviewID)
+	if err != nil {
+		return model.Review{}, notFoundOrError(err)
+	}
+	if review.UserID != user.UserID {
+		return model.Review{}, ErrForbidden
+	}
+	return review, nil
+}
+
+// CanModifyVehicleProfile checks that the user owns the stored vehicle profile, which is returned
+func (policy *Policy) CanModifyVehicleProfile(user model.User, vehicleProfileID int) (model.VehicleProfile, error) {
+	vehicleProfile, err := policy.vehicleProfiles.GetVehicleProfileById(vehicleProfileID)
+	if err != nil {
+		return model.VehicleProfile{}, notFoundOrError(err)
+	}
+	if vehicleProfile.UserID != user.UserID {
+		return model.VehicleProfile{}, ErrForbidden
+	}
+	return vehicleProfile, nil
+}
+
+// CanUseVehicleProfile checks that the user can search with the vehicle profile, only the owner can
+func (policy *Policy) CanUseVehicleProfile(user model.User, vehicleProfileID int) (model.VehicleProfile, error) {
+	return policy.CanModifyVehicleProfile(user, vehicleProfileID)
+}
+
+func notFoundOrError(err error) error {
+	if errors.Is(err, db.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
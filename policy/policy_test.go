@@ -0,0 +1,204 @@
+package policy_test
+
+import (
+	"errors"
+	"green-journey-server/db"
+	"green-journey-server/db/memory"
+	"green-journey-server/model"
+	"green-journey-server/policy"
+	"testing"
+	"time"
+)
+
+// id of no stored resource
+const missingID = 1000
+
+// testResources are the resources of owner, other is a user owning nothing
+type testResources struct {
+	policy           *policy.Policy
+	owner            model.User
+	other            model.User
+	travelID         int
+	reviewID         int
+	vehicleProfileID int
+}
+
+func newTestResources(t *testing.T) testResources {
+	repositories := memory.NewRepositories(memory.NewStore())
+	owner, err := repositories.Users.AddUser(model.User{FirstName: "Owner", FirebaseUID: "owner"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := repositories.Users.AddUser(model.User{FirstName: "Other", FirebaseUID: "other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	countryCode := "IT"
+	departureIata, destinationIata := "MIL", "ROM"
+	departureCity := model.City{CityIata: &departureIata, CityName: "Milan", CountryCode: &countryCode}
+	destinationCity := model.City{CityIata: &destinationIata, CityName: "Rome", CountryCode: &countryCode}
+	for _, city := range []*model.City{&departureCity, &destinationCity} {
+		err = repositories.Cities.CreateCity(city)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	travelDetails, err := repositories.Travels.CreateTravel(model.TravelDetails{
+		Travel: model.Travel{UserID: owner.UserID},
+		Segments: []model.Segment{{
+			DepartureId:   departureCity.CityID,
+			DestinationId: destinationCity.CityID,
+			DateTime:      time.Date(2026, 5, 4, 8, 0, 0, 0, time.UTC),
+			Vehicle:       "train",
+			Distance:      500,
+			NumSegment:    1,
+			IsOutward:     true,
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	review := model.Review{CityID: destinationCity.CityID, UserID: owner.UserID, LocalTransportRating: 3, GreenSpacesRating: 3, WasteBinsRating: 3}
+	err = repositories.Reviews.CreateReview(&review)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vehicleProfile := model.VehicleProfile{UserID: owner.UserID, Name: "Car", FuelType: model.FuelTypePetrol, Consumption: 6, Seats: 5}
+	err = repositories.VehicleProfiles.CreateVehicleProfile(&vehicleProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return testResources{
+		policy:           policy.NewPolicy(repositories.Travels, repositories.Reviews, repositories.VehicleProfiles),
+		owner:            owner,
+		other:            other,
+		travelID:         travelDetails.Travel.TravelID,
+		reviewID:         review.ReviewID,
+		vehicleProfileID: vehicleProfile.VehicleProfileID,
+	}
+}
+
+// policyCase is a check of a user on a resource id, with the expected error
+type policyCase struct {
+	name    string
+	user    model.User
+	id      int
+	wantErr error
+}
+
+func policyCases(resources testResources, id int) []policyCase {
+	return []policyCase{
+		{name: "owner", user: resources.owner, id: id, wantErr: nil},
+		{name: "other user", user: resources.other, id: id, wantErr: policy.ErrForbidden},
+		{name: "missing id", user: resources.owner, id: missingID, wantErr: policy.ErrNotFound},
+	}
+}
+
+func TestCanCreateFor(t *testing.T) {
+	resources := newTestResources(t)
+	testCases := []policyCase{
+		{name: "owner", user: resources.owner, id: resources.owner.UserID, wantErr: nil},
+		{name: "other user", user: resources.other, id: resources.owner.UserID, wantErr: policy.ErrForbidden},
+		{name: "missing id", user: resources.owner, id: 0, wantErr: policy.ErrForbidden},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := policy.CanCreateFor(testCase.user, testCase.id)
+			if !errors.Is(err, testCase.wantErr) {
+				t.Fatalf("CanCreateFor = %v, want %v", err, testCase.wantErr)
+			}
+		})
+	}
+}
+
+func TestCanModifyUser(t *testing.T) {
+	resources := newTestResources(t)
+	testCases := []policyCase{
+		{name: "owner", user: resources.owner, id: resources.owner.UserID, wantErr: nil},
+		{name: "other user", user: resources.other, id: resources.owner.UserID, wantErr: policy.ErrForbidden},
+		{name: "missing id", user: resources.owner, id: missingID, wantErr: policy.ErrForbidden},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := policy.CanModifyUser(testCase.user, testCase.id)
+			if !errors.Is(err, testCase.wantErr) {
+				t.Fatalf("CanModifyUser = %v, want %v", err, testCase.wantErr)
+			}
+		})
+	}
+}
+
+func TestCanModifyTravel(t *testing.T) {
+	resources := newTestResources(t)
+	for _, testCase := range policyCases(resources, resources.travelID) {
+		t.Run(testCase.name, func(t *testing.T) {
+			travel, err := resources.policy.CanModifyTravel(testCase.user, testCase.id)
+			if !errors.Is(err, testCase.wantErr) {
+				t.Fatalf("CanModifyTravel = %v, want %v", err, testCase.wantErr)
+			}
+			if err == nil && (travel.TravelID != resources.travelID || travel.UserID != resources.owner.UserID) {
+				t.Fatalf("CanModifyTravel returned %+v", travel)
+			}
+		})
+	}
+}
+
+func TestCanModifyReview(t *testing.T) {
+	resources := newTestResources(t)
+	for _, testCase := range policyCases(resources, resources.reviewID) {
+		t.Run(testCase.name, func(t *testing.T) {
+			review, err := resources.policy.CanModifyReview(testCase.user, testCase.id)
+			if !errors.Is(err, testCase.wantErr) {
+				t.Fatalf("CanModifyReview = %v, want %v", err, testCase.wantErr)
+			}
+			if err == nil && (review.ReviewID != resources.reviewID || review.UserID != resources.owner.UserID) {
+				t.Fatalf("CanModifyReview returned %+v", review)
+			}
+		})
+	}
+}
+
+func TestCanModifyVehicleProfile(t *testing.T) {
+	resources := newTestResources(t)
+	for _, testCase := range policyCases(resources, resources.vehicleProfileID) {
+		t.Run(testCase.name, func(t *testing.T) {
+			vehicleProfile, err := resources.policy.CanModifyVehicleProfile(testCase.user, testCase.id)
+			if !errors.Is(err, testCase.wantErr) {
+				t.Fatalf("CanModifyVehicleProfile = %v, want %v", err, testCase.wantErr)
+			}
+			if err == nil && (vehicleProfile.VehicleProfileID != resources.vehicleProfileID || vehicleProfile.UserID != resources.owner.UserID) {
+				t.Fatalf("CanModifyVehicleProfile returned %+v", vehicleProfile)
+			}
+
+			// searches use the same check
+			_, err = resources.policy.CanUseVehicleProfile(testCase.user, testCase.id)
+			if !errors.Is(err, testCase.wantErr) {
+				t.Fatalf("CanUseVehicleProfile = %v, want %v", err, testCase.wantErr)
+			}
+		})
+	}
+}
+
+// errors of the repositories other than a missing record are not hidden
+func TestRepositoryError(t *testing.T) {
+	errDatabase := errors.New("database unavailable")
+	checks := policy.NewPolicy(failingTravels{err: errDatabase}, nil, nil)
+	_, err := checks.CanModifyTravel(model.User{UserID: 1}, 1)
+	if !errors.Is(err, errDatabase) || errors.Is(err, policy.ErrNotFound) {
+		t.Fatalf("CanModifyTravel = %v, want %v", err, errDatabase)
+	}
+}
+
+type failingTravels struct {
+	db.TravelRepository
+	err error
+}
+
+func (travels failingTravels) GetTravelById(travelID int) (model.Travel, error) {
+	return model.Travel{}, travels.err
+}
@@ -1,34 +1,115 @@
 package main
 
 import (
+	"green-journey-server/config"
+	"green-journey-server/db"
+	"green-journey-server/externals"
 	"green-journey-server/handlers"
 	"net/http"
 )
 
-func SetupServer(port string) *http.Server {
-	mux := http.NewServeMux()
+// SetupServer registers the routes, the handlers read and store data with repositories
+// and convert prices with priceApis
+func SetupServer(serverConfig config.ServerConfig, authConfig config.AuthConfig, repositories db.Repositories, priceApis *externals.PriceApis) *http.Server {
+	api := handlers.NewAPI(repositories, priceApis)
+	router := handlers.NewRouter(authConfig.AdminUIDs, repositories.Users)
 
-	// setup routes
-	mux.HandleFunc("/users/user", handlers.HandleUsers)
-	mux.HandleFunc("/users", handlers.HandleModifyUser)
+	public := func(handler http.HandlerFunc) handlers.Endpoint {
+		return handlers.Endpoint{Access: handlers.AccessPublic, Handler: handler}
+	}
+	identified := func(handler http.HandlerFunc) handlers.Endpoint {
+		return handlers.Endpoint{Access: handlers.AccessIdentified, Handler: handler}
+	}
+	authenticated := func(handler http.HandlerFunc) handlers.Endpoint {
+		return handlers.Endpoint{Access: handlers.AccessAuthenticated, Handler: handler}
+	}
+
+	// setup routes, every method declares its access level
+	router.Handle("/users/user", map[string]handlers.Endpoint{
+		"GET":  authenticated(api.HandleUsers),
+		"POST": identified(api.HandleUsers),
+	})
+	router.Handle("/users", map[string]handlers.Endpoint{
+		"PUT":    authenticated(api.HandleModifyUser),
+		"DELETE": authenticated(api.HandleModifyUser),
+	})
+	router.Handle("/users/vehicles", map[string]handlers.Endpoint{
+		"GET":  authenticated(api.HandleVehicleProfiles),
+		"POST": authenticated(api.HandleVehicleProfiles),
+	})
+	router.Handle("/users/vehicles/", map[string]handlers.Endpoint{
+		"PUT":    authenticated(api.HandleModifyVehicleProfiles),
+		"DELETE": authenticated(api.HandleModifyVehicleProfiles),
+	})
+
+	router.Handle("/travels/search", map[string]handlers.Endpoint{
+		"GET": public(api.HandleSearchTravel),
+	})
+	router.Handle("/travels/search/stream", map[string]handlers.Endpoint{
+		"GET": public(api.HandleSearchTravelStream),
+	})
+	router.Handle("/travels/search/roundtrip", map[string]handlers.Endpoint{
+		"GET": public(api.HandleSearchRoundTrip),
+	})
+	router.Handle("/travels/search/calendar", map[string]handlers.Endpoint{
+		"GET": public(api.HandleSearchCalendar),
+	})
+	router.Handle("/travels/search/itinerary", map[string]handlers.Endpoint{
+		"GET": public(api.HandleSearchItinerary),
+	})
+	router.Handle("/travels/user", map[string]handlers.Endpoint{
+		"GET":  authenticated(api.HandleTravelsUser),
+		"POST": authenticated(api.HandleTravelsUser),
+		"PUT":  authenticated(api.HandleTravelsUser),
+	})
+	router.Handle("/travels/user/", map[string]handlers.Endpoint{
+		"DELETE": authenticated(api.HandleDeleteTravel),
+	})
 
-	mux.HandleFunc("/travels/search", handlers.HandleSearchTravel)
-	mux.HandleFunc("/travels/user", handlers.HandleTravelsUser)
-	mux.HandleFunc("/travels/user/", handlers.HandleDeleteTravel)
+	router.Handle("/reviews/first", map[string]handlers.Endpoint{
+		"GET": public(api.HandleFirstReviews),
+	})
+	router.Handle("/reviews/last", map[string]handlers.Endpoint{
+		"GET": public(api.HandleLastReviews),
+	})
+	router.Handle("/reviews/best", map[string]handlers.Endpoint{
+		"GET": public(api.HandleBestReviews),
+	})
+	router.Handle("/reviews", map[string]handlers.Endpoint{
+		"GET":  public(api.HandleReviews),
+		"POST": authenticated(api.HandleReviews),
+	})
+	router.Handle("/reviews/", map[string]handlers.Endpoint{
+		"PUT":    authenticated(api.HandleModifyReviews),
+		"DELETE": authenticated(api.HandleModifyReviews),
+	})
 
-	mux.HandleFunc("/reviews/first", handlers.HandleFirstReviews)
-	mux.HandleFunc("/reviews/last", handlers.HandleLastReviews)
-	mux.HandleFunc("/reviews/best", handlers.HandleBestReviews)
-	mux.HandleFunc("/reviews", handlers.HandleReviews)
-	mux.HandleFunc("/reviews/", handlers.HandleModifyReviews)
+	router.Handle("/cities/search", map[string]handlers.Endpoint{
+		"GET": public(api.HandleSearchCities),
+	})
+	router.Handle("/cities/nearby", map[string]handlers.Endpoint{
+		"GET": public(api.HandleNearbyCities),
+	})
+	router.Handle("/airports/nearby", map[string]handlers.Endpoint{
+		"GET": public(api.HandleNearbyAirports),
+	})
 
-	mux.HandleFunc("/ranking", handlers.HandleRanking)
+	router.Handle("/ranking", map[string]handlers.Endpoint{
+		"GET": public(api.HandleRanking),
+	})
 
-	mux.HandleFunc("/resetTestDatabase", handlers.HandleResetTestDatabase)
+	// only works in test mode, checked by db.ResetTestDatabase
+	router.Handle("/resetTestDatabase", map[string]handlers.Endpoint{
+		"POST": public(handlers.HandleResetTestDatabase),
+	})
+	// only works in test mode, mints tokens for arbitrary uids
+	router.Handle("/testToken", map[string]handlers.Endpoint{
+		"POST": public(handlers.HandleTestToken),
+	})
 
 	server := &http.Server{
-		Addr:    ":" + port,
-		Handler: mux,
+		Addr:    ":" + serverConfig.Port,
+		Handler: router,
 	}
 
 	return server
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"green-journey-server/config"
+	"green-journey-server/db"
+	"green-journey-server/db/memory"
+	"green-journey-server/externals"
+	"green-journey-server/handlers"
+	"green-journey-server/model"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// testIssuer mints the tokens of the requests, as in test mode
+var testIssuer *externals.TestIssuer
+
+func TestMain(m *testing.M) {
+	issuer, err := externals.NewTestIssuer()
+	if err != nil {
+		log.Fatal(err)
+	}
+	testIssuer = issuer
+	externals.SetAuthVerifier(issuer)
+
+	// errors are checked through the responses
+	log.SetOutput(io.Discard)
+
+	os.Exit(m.Run())
+}
+
+// testServer serves the routes of the server with the in-memory repositories
+type testServer struct {
+	t            *testing.T
+	handler      http.Handler
+	repositories db.Repositories
+}
+
+func newTestServer(t *testing.T) *testServer {
+	repositories := memory.NewRepositories(memory.NewStore())
+	defaultConfig := config.Default()
+	server := SetupServer(defaultConfig.Server, defaultConfig.Auth, repositories, externals.NewPriceApis(defaultConfig.MockApis))
+	return &testServer{t: t, handler: server.Handler, repositories: repositories}
+}
+
+// do sends a request with the token of firebaseUID, or without token if empty: body is encoded as json
+func (server *testServer) do(method, target, firebaseUID string, body interface{}, header http.Header) *httptest.ResponseRecorder {
+	var requestBody bytes.Buffer
+	if body != nil {
+		err := json.NewEncoder(&requestBody).Encode(body)
+		if err != nil {
+			server.t.Fatal(err)
+		}
+	}
+
+	r := httptest.NewRequest(method, target, &requestBody)
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	if firebaseUID != "" {
+		token, err := testIssuer.MintToken(firebaseUID)
+		if err != nil {
+			server.t.Fatal(err)
+		}
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	server.handler.ServeHTTP(w, r)
+	return w
+}
+
+// expect checks the status code of the response and decodes its body in out, if not nil
+func (server *testServer) expect(w *httptest.ResponseRecorder, statusCode int, out interface{}) {
+	server.t.Helper()
+	if w.Code != statusCode {
+		server.t.Fatalf("status code = %d, want %d: %s", w.Code, statusCode, w.Body.String())
+	}
+	if out != nil {
+		err := json.Unmarshal(w.Body.Bytes(), out)
+		if err != nil {
+			server.t.Fatal(err)
+		}
+	}
+}
+
+func (server *testServer) createUser(firebaseUID string) model.User {
+	user, err := server.repositories.Users.AddUser(model.User{FirstName: "Test", LastName: firebaseUID, FirebaseUID: firebaseUID})
+	if err != nil {
+		server.t.Fatal(err)
+	}
+	return user
+}
+
+func (server *testServer) createCities() (model.City, model.City) {
+	countryCode := "IT"
+	countryName := "Italy"
+	departureIata, destinationIata := "MIL", "ROM"
+	cities := []model.City{
+		{CityIata: &departureIata, CityName: "Milan", CountryCode: &countryCode, CountryName: &countryName},
+		{CityIata: &destinationIata, CityName: "Rome", CountryCode: &countryCode, CountryName: &countryName},
+	}
+	for i := range cities {
+		err := server.repositories.Cities.CreateCity(&cities[i])
+		if err != nil {
+			server.t.Fatal(err)
+		}
+	}
+	return cities[0], cities[1]
+}
+
+// newTestTravel returns a round trip by train of 400 km and 20 kg of co2, the return is the second leg
+func newTestTravel(user model.User, departureCity, destinationCity model.City) model.TravelDetails {
+	date := time.Date(2026, 5, 4, 8, 0, 0, 0, time.UTC)
+	segment := model.Segment{
+		DepartureId:   departureCity.CityID,
+		DestinationId: destinationCity.CityID,
+		DateTime:      date,
+		Duration:      3 * time.Hour,
+		Vehicle:       "train",
+		Price:         40,
+		CO2Emitted:    10,
+		Distance:      200,
+		NumSegment:    1,
+		IsOutward:     true,
+		LegIndex:      0,
+	}
+	returnSegment := segment
+	returnSegment.DepartureId, returnSegment.DestinationId = segment.DestinationId, segment.DepartureId
+	returnSegment.DateTime = date.Add(48 * time.Hour)
+	returnSegment.IsOutward = false
+	returnSegment.LegIndex = 1
+
+	return model.TravelDetails{
+		Travel:   model.Travel{UserID: user.UserID},
+		Segments: []model.Segment{segment, returnSegment},
+	}
+}
+
+func (server *testServer) createTravel(user model.User, departureCity, destinationCity model.City) model.TravelDetails {
+	var travelDetails model.TravelDetails
+	w := server.do("POST", "/travels/user", user.FirebaseUID, newTestTravel(user, departureCity, destinationCity), nil)
+	server.expect(w, http.StatusOK, &travelDetails)
+	return travelDetails
+}
+
+func TestTravelScore(t *testing.T) {
+	server := newTestServer(t)
+	user := server.createUser("user-a")
+	departureCity, destinationCity := server.createCities()
+	travel := server.createTravel(user, departureCity, destinationCity).Travel
+
+	expectScore := func(want float64) {
+		t.Helper()
+		var storedUser model.User
+		server.expect(server.do("GET", "/users/user", user.FirebaseUID, nil, nil), http.StatusOK, &storedUser)
+		if math.Abs(storedUser.ScoreShortDistance-want) > 1e-9 || storedUser.ScoreLongDistance != 0 {
+			t.Fatalf("score = %v short, %v long, want %v short", storedUser.ScoreShortDistance, storedUser.ScoreLongDistance, want)
+		}
+	}
+	expectScore(0)
+
+	// confirming adds transit coefficient * distance / co2: 0.44 * 400 / 20
+	travel.Confirmed = true
+	server.expect(server.do("PUT", "/travels/user", user.FirebaseUID, travel, nil), http.StatusOK, nil)
+	expectScore(8.8)
+
+	// compensating all the co2 adds compensation coefficient * co2 and the bonus: 0.12 * 20 + 2
+	travel.CO2Compensated = 20
+	server.expect(server.do("PUT", "/travels/user", user.FirebaseUID, travel, nil), http.StatusOK, nil)
+	expectScore(13.2)
+
+	var ranking handlers.RankingResponse
+	server.expect(server.do("GET", "/ranking?id="+strconv.Itoa(user.UserID), "", nil, nil), http.StatusOK, &ranking)
+	if len(ranking.ShortDistanceRanking) != 1 || ranking.ShortDistanceRanking[0].UserID != user.UserID ||
+		math.Abs(ranking.ShortDistanceRanking[0].ScoreShortDistance-13.2) > 1e-9 || ranking.ShortDistanceRanking[0].TotalDistance != 400 {
+		t.Fatalf("short distance ranking = %+v", ranking.ShortDistanceRanking)
+	}
+
+	// deleting removes the whole score of the travel
+	server.expect(server.do("DELETE", "/travels/user/"+strconv.Itoa(travel.TravelID), user.FirebaseUID, nil, nil), http.StatusOK, nil)
+	expectScore(0)
+
+	var travels []model.TravelDetails
+	server.expect(server.do("GET", "/travels/user", user.FirebaseUID, nil, nil), http.StatusOK, &travels)
+	if len(travels) != 0 {
+		t.Fatalf("travels after delete = %+v", travels)
+	}
+}
+
+func TestTravelsUser(t *testing.T) {
+	server := newTestServer(t)
+	user := server.createUser("user-a")
+	departureCity, destinationCity := server.createCities()
+	travelDetails := server.createTravel(user, departureCity, destinationCity)
+
+	var travels []model.TravelDetails
+	server.expect(server.do("GET", "/travels/user", user.FirebaseUID, nil, nil), http.StatusOK, &travels)
+	if len(travels) != 1 || travels[0].Travel.TravelID != travelDetails.Travel.TravelID || len(travels[0].Segments) != 2 {
+		t.Fatalf("travels = %+v", travels)
+	}
+	segment := travels[0].Segments[0]
+	if segment.DepartureCity != "Milan" || segment.DestinationCity != "Rome" || segment.Currency != "EUR" || segment.Price != 40 {
+		t.Fatalf("first segment = %+v", segment)
+	}
+
+	// requests without token or of users not registered are rejected
+	server.expect(server.do("GET", "/travels/user", "", nil, nil), http.StatusUnauthorized, nil)
+	server.expect(server.do("GET", "/travels/user", "not-registered", nil, nil), http.StatusNotFound, nil)
+	server.expect(server.do("PATCH", "/travels/user", user.FirebaseUID, nil, nil), http.StatusMethodNotAllowed, nil)
+}
+
+func TestCreateTravelIdempotencyKey(t *testing.T) {
+	server := newTestServer(t)
+	user := server.createUser("user-a")
+	departureCity, destinationCity := server.createCities()
+	travelDetails := newTestTravel(user, departureCity, destinationCity)
+	header := http.Header{"Idempotency-Key": {"key-1"}}
+
+	// a retry returns the first travel
+	var first, retry model.TravelDetails
+	server.expect(server.do("POST", "/travels/user", user.FirebaseUID, travelDetails, header), http.StatusOK, &first)
+	server.expect(server.do("POST", "/travels/user", user.FirebaseUID, travelDetails, header), http.StatusOK, &retry)
+	if retry.Travel.TravelID != first.Travel.TravelID {
+		t.Fatalf("retry created travel %d, first %d", retry.Travel.TravelID, first.Travel.TravelID)
+	}
+
+	// the same key with a different payload is rejected
+	travelDetails.Segments[0].Price = 50
+	server.expect(server.do("POST", "/travels/user", user.FirebaseUID, travelDetails, header), http.StatusConflict, nil)
+
+	travels, err := server.repositories.Travels.GetTravelRequestsByUserId(user.UserID)
+	if err != nil || len(travels) != 1 {
+		t.Fatalf("stored travels = %+v, %v", travels, err)
+	}
+}
+
+// TestOwnership checks that resources of other users are forbidden and missing resources are not found
+func TestOwnership(t *testing.T) {
+	server := newTestServer(t)
+	owner := server.createUser("owner")
+	other := server.createUser("other")
+	departureCity, destinationCity := server.createCities()
+	travel := server.createTravel(owner, departureCity, destinationCity).Travel
+
+	review := model.Review{CityID: destinationCity.CityID, UserID: owner.UserID, ReviewText: "Review", LocalTransportRating: 3, GreenSpacesRating: 3, WasteBinsRating: 3}
+	err := server.repositories.Reviews.CreateReview(&review)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vehicleProfile := model.VehicleProfile{UserID: owner.UserID, Name: "Car", FuelType: model.FuelTypePetrol, Consumption: 6, Seats: 5}
+	err = server.repositories.VehicleProfiles.CreateVehicleProfile(&vehicleProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// id of no stored resource
+	missingID := 1000
+	searchTarget := func(vehicleProfileID int) string {
+		return "/travels/search?iata_departure=MIL&country_code_departure=IT&iata_destination=ROM&country_code_destination=IT" +
+			"&date=2026-05-04&time=08:00&is_outward=true&vehicle_profile_id=" + strconv.Itoa(vehicleProfileID)
+	}
+
+	testCases := []struct {
+		name   string
+		method string
+		target func(id int) string
+		body   func(id int) interface{}
+		id     int
+	}{
+		{
+			name:   "modify travel",
+			method: "PUT",
+			target: func(int) string { return "/travels/user" },
+			body: func(id int) interface{} {
+				return model.Travel{TravelID: id, UserID: owner.UserID, Confirmed: true}
+			},
+			id: travel.TravelID,
+		},
+		{
+			name:   "delete travel",
+			method: "DELETE",
+			target: func(id int) string { return "/travels/user/" + strconv.Itoa(id) },
+			id:     travel.TravelID,
+		},
+		{
+			name:   "modify review",
+			method: "PUT",
+			target: func(id int) string { return "/reviews/" + strconv.Itoa(id) },
+			body: func(int) interface{} {
+				modifiedReview := review
+				modifiedReview.LocalTransportRating = 1
+				return modifiedReview
+			},
+			id: review.ReviewID,
+		},
+		{
+			name:   "delete review",
+			method: "DELETE",
+			target: func(id int) string { return "/reviews/" + strconv.Itoa(id) },
+			id:     review.ReviewID,
+		},
+		{
+			name:   "modify vehicle profile",
+			method: "PUT",
+			target: func(id int) string { return "/users/vehicles/" + strconv.Itoa(id) },
+			body: func(int) interface{} {
+				modifiedVehicleProfile := vehicleProfile
+				modifiedVehicleProfile.Seats = 2
+				return modifiedVehicleProfile
+			},
+			id: vehicleProfile.VehicleProfileID,
+		},
+		{
+			name:   "delete vehicle profile",
+			method: "DELETE",
+			target: func(id int) string { return "/users/vehicles/" + strconv.Itoa(id) },
+			id:     vehicleProfile.VehicleProfileID,
+		},
+		{
+			name:   "search with vehicle profile",
+			method: "GET",
+			target: searchTarget,
+			id:     vehicleProfile.VehicleProfileID,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			server.t = t
+			var body, missingBody interface{}
+			if testCase.body != nil {
+				body, missingBody = testCase.body(testCase.id), testCase.body(missingID)
+			}
+			server.expect(server.do(testCase.method, testCase.target(testCase.id), other.FirebaseUID, body, nil), http.StatusForbidden, nil)
+			server.expect(server.do(testCase.method, testCase.target(missingID), owner.FirebaseUID, missingBody, nil), http.StatusNotFound, nil)
+		})
+	}
+	server.t = t
+
+	// resources created or modified for the owner by another user are forbidden, there is no id to miss
+	otherTravel := newTestTravel(owner, departureCity, destinationCity)
+	otherReview := model.Review{CityID: departureCity.CityID, UserID: owner.UserID, ReviewText: "Review", LocalTransportRating: 1, GreenSpacesRating: 1, WasteBinsRating: 1}
+	otherUser := other
+	otherUser.UserID = owner.UserID
+	otherUser.LastName = "Modified"
+	forbiddenCases := []struct {
+		name   string
+		method string
+		target string
+		body   interface{}
+	}{
+		{name: "create travel", method: "POST", target: "/travels/user", body: otherTravel},
+		{name: "create review", method: "POST", target: "/reviews", body: otherReview},
+		{name: "modify user", method: "PUT", target: "/users", body: otherUser},
+	}
+	for _, testCase := range forbiddenCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			server.t = t
+			server.expect(server.do(testCase.method, testCase.target, other.FirebaseUID, testCase.body, nil), http.StatusForbidden, nil)
+		})
+	}
+	server.t = t
+
+	// the resources of the owner are unchanged
+	storedTravel, err := server.repositories.Travels.GetTravelById(travel.TravelID)
+	if err != nil || storedTravel.Confirmed {
+		t.Fatalf("travel = %+v, %v", storedTravel, err)
+	}
+	storedReview, err := server.repositories.Reviews.GetReviewById(review.ReviewID)
+	if err != nil || storedReview.LocalTransportRating != 3 {
+		t.Fatalf("review = %+v, %v", storedReview, err)
+	}
+	storedVehicleProfile, err := server.repositories.VehicleProfiles.GetVehicleProfileById(vehicleProfile.VehicleProfileID)
+	if err != nil || storedVehicleProfile.Seats != 5 {
+		t.Fatalf("vehicle profile = %+v, %v", storedVehicleProfile, err)
+	}
+	storedTravels, err := server.repositories.Travels.GetTravelRequestsByUserId(owner.UserID)
+	if err != nil || len(storedTravels) != 1 {
+		t.Fatalf("travels = %+v, %v", storedTravels, err)
+	}
+	storedUser, err := server.repositories.Users.GetUserByIdNoBadges(owner.UserID)
+	if err != nil || storedUser.FirebaseUID != owner.FirebaseUID || storedUser.LastName != owner.LastName {
+		t.Fatalf("user = %+v, %v", storedUser, err)
+	}
+
+	// and the owner can modify and delete them
+	modifiedReview := review
+	modifiedReview.LocalTransportRating = 1
+	server.expect(server.do("PUT", "/reviews/"+strconv.Itoa(review.ReviewID), owner.FirebaseUID, modifiedReview, nil), http.StatusOK, nil)
+	server.expect(server.do("DELETE", "/reviews/"+strconv.Itoa(review.ReviewID), owner.FirebaseUID, nil, nil), http.StatusOK, nil)
+	server.expect(server.do("DELETE", "/users/vehicles/"+strconv.Itoa(vehicleProfile.VehicleProfileID), owner.FirebaseUID, nil, nil), http.StatusOK, nil)
+	server.expect(server.do("DELETE", "/travels/user/"+strconv.Itoa(travel.TravelID), owner.FirebaseUID, nil, nil), http.StatusOK, nil)
+}